@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"watchdog.onebusaway.org/internal/alerts"
+	"watchdog.onebusaway.org/internal/notify"
+)
+
+// alertsHandler writes the state of every pending, firing and recently resolved alert.
+func (app *application) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	list := []alerts.Alert{}
+	if app.alertEngine != nil {
+		list = app.alertEngine.Alerts()
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"alerts": list}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write alerts response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// handleAlert logs alerts that start firing or are resolved and sends them to their receivers.
+func (app *application) handleAlert(alert alerts.Alert) {
+	app.logAlert(alert)
+	if app.notifier != nil {
+		app.notifier.Dispatch(notify.Message{
+			ServerName: app.serverName(alert.ServerID),
+			Alert:      alert,
+		})
+	}
+}
+
+// serverName returns the configured name of the server, or its ID if it is unknown.
+func (app *application) serverName(id int) string {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	for _, server := range app.config.Servers {
+		if server.ID == id {
+			return server.Name
+		}
+	}
+	return fmt.Sprintf("server %d", id)
+}
+
+// logAlert logs alerts that start firing or are resolved.
+func (app *application) logAlert(alert alerts.Alert) {
+	if alert.State == alerts.StateResolved {
+		app.logger.Info("Alert resolved", "rule", alert.Rule, "server_id", alert.ServerID, "severity", alert.Severity)
+		return
+	}
+	app.logger.Warn("Alert firing",
+		"rule", alert.Rule,
+		"server_id", alert.ServerID,
+		"severity", alert.Severity,
+		"message", alert.Message,
+	)
+}
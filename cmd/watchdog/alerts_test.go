@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"watchdog.onebusaway.org/internal/alerts"
+	"watchdog.onebusaway.org/internal/metrics"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/notify"
+	"watchdog.onebusaway.org/internal/scheduler"
+)
+
+func TestAlertsHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.alertEngine = alerts.NewEngine([]alerts.Rule{{Name: "ping-down", Check: "ping"}}, nil, app.logAlert)
+
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "ping", Err: errors.New("connection refused")})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/alerts", nil)
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var body struct {
+		Alerts []alerts.Alert `json:"alerts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(body.Alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(body.Alerts))
+	}
+	if body.Alerts[0].Rule != "ping-down" || body.Alerts[0].State != alerts.StateFiring {
+		t.Errorf("Unexpected alert: %+v", body.Alerts[0])
+	}
+}
+
+func TestUpdateConfigDropsRemovedServers(t *testing.T) {
+	app := newTestApplication(t)
+	app.alertEngine = alerts.NewEngine([]alerts.Rule{{Name: "ping-down", Check: "ping"}}, nil, app.logAlert)
+	servers := append(app.config.Servers, models.ObaServer{ID: 2, Name: "Removed"})
+	app.updateConfig(servers)
+
+	app.handleCheckResult(scheduler.Result{ServerID: 2, Check: "ping", Err: errors.New("connection refused")})
+	metrics.VehicleMatchScore.WithLabelValues("2").Set(0.5)
+	series := testutil.CollectAndCount(metrics.VehicleMatchScore)
+
+	app.updateConfig(servers[:1])
+
+	if got := app.alertEngine.Alerts(); len(got) != 0 {
+		t.Errorf("Expected the alerts of the removed server to be dropped, got %+v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.VehicleMatchScore); got != series-1 {
+		t.Errorf("Expected the series of the removed server to be deleted, got %d of %d series", got, series)
+	}
+}
+
+func TestHandleAlertNotifiesReceivers(t *testing.T) {
+	var payload map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+
+	app := newTestApplication(t)
+	dispatcher, err := notify.NewDispatcher([]notify.Config{{Name: "ops", Type: notify.TypeSlack, URL: ts.URL}}, app.logger)
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	app.notifier = dispatcher
+
+	app.handleAlert(alerts.Alert{Rule: "ping-down", ServerID: 1, Check: "ping", State: alerts.StateFiring, Receivers: []string{"ops"}})
+	dispatcher.Wait()
+
+	if payload["text"] == "" || !strings.Contains(payload["text"], "Test Server") {
+		t.Errorf("Expected the notification to name the server, got %q", payload["text"])
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// Names of the bundle state of a server in the history database.
+const (
+	bundleSummaryState = "bundle_summary"
+	bundleDiffsState   = "bundle_diffs"
+)
+
+// storedBundleSummary is the summary of the latest bundle of a server as kept in the
+// history database. The route IDs are needed for the next diff but are not part of the
+// JSON of a bundle.Summary.
+type storedBundleSummary struct {
+	bundle.Summary
+	RouteIDs []string `json:"route_ids"`
+}
+
+// loadBundleSummary returns the stored summary of the latest bundle of the server.
+func loadBundleSummary(store *history.Store, serverID int, logger *slog.Logger) (bundle.Summary, bool) {
+	var stored storedBundleSummary
+	ok, err := store.State(serverID, bundleSummaryState, &stored)
+	if err != nil {
+		logger.Error("Failed to load the previous GTFS bundle summary", "server_id", serverID, "error", err)
+	}
+	if !ok {
+		return bundle.Summary{}, false
+	}
+
+	summary := stored.Summary
+	summary.RouteIDs = stored.RouteIDs
+	return summary, true
+}
+
+// saveBundleSummary stores the summary of the latest bundle of the server. A nil store does nothing.
+func saveBundleSummary(store *history.Store, serverID int, summary bundle.Summary, logger *slog.Logger) {
+	if store == nil {
+		return
+	}
+	if err := store.PutState(serverID, bundleSummaryState, storedBundleSummary{Summary: summary, RouteIDs: summary.RouteIDs}); err != nil {
+		logger.Error("Failed to store the GTFS bundle summary", "server_id", serverID, "error", err)
+	}
+}
+
+// saveBundleDiffs stores the recorded diffs of the server. A nil store does nothing.
+func saveBundleDiffs(store *history.Store, serverID int, diffs *bundle.DiffStore, logger *slog.Logger) {
+	if store == nil {
+		return
+	}
+	if err := store.PutState(serverID, bundleDiffsState, diffs.List(serverID)); err != nil {
+		logger.Error("Failed to store GTFS bundle diffs", "server_id", serverID, "error", err)
+	}
+}
+
+// restoreBundleDiffs loads the stored diffs of the servers into diffs.
+func restoreBundleDiffs(store *history.Store, servers []models.ObaServer, diffs *bundle.DiffStore, logger *slog.Logger) {
+	for _, server := range servers {
+		var list []bundle.Diff
+		ok, err := store.State(server.ID, bundleDiffsState, &list)
+		if err != nil {
+			logger.Error("Failed to restore GTFS bundle diffs", "server_id", server.ID, "error", err)
+			continue
+		}
+		if ok {
+			diffs.Restore(server.ID, list)
+		}
+	}
+}
+
+// serverBundleDiffsHandler writes the recorded GTFS bundle changes of the server
+// identified by the :id parameter, newest first.
+func (app *application) serverBundleDiffsHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	if _, ok := app.findServer(id); !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"server_id": id, "diffs": app.bundleDiffs.List(id)}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write bundle diffs response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
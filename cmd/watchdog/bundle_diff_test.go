@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/metrics"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// buildGTFSZip returns a minimal static GTFS bundle with the given routes.txt rows.
+func buildGTFSZip(t *testing.T, routes string) []byte {
+	t.Helper()
+
+	files := []struct{ name, content string }{
+		{"agency.txt", "agency_id,agency_name,agency_url,agency_timezone\nA1,Agency One,https://agency.example.com,America/Los_Angeles\n"},
+		{"routes.txt", "route_id,agency_id,route_short_name,route_type\n" + routes},
+		{"stops.txt", "stop_id,stop_name,stop_lat,stop_lon\nS1,First,47.6,-122.3\nS2,Second,47.61,-122.31\n"},
+		{"calendar.txt", "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nWK,1,1,1,1,1,0,0,20250101,20251231\n"},
+		{"trips.txt", "route_id,service_id,trip_id\nR1,WK,T1\n"},
+		{"stop_times.txt", "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nT1,08:00:00,08:00:00,S1,1\nT1,08:10:00,08:10:00,S2,2\n"},
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", file.name, err)
+		}
+		w.Write([]byte(file.content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to build GTFS zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBundleDiffs(t *testing.T) {
+	bundles := [][]byte{
+		buildGTFSZip(t, "R1,A1,1,3\nR2,A1,2,3\n"),
+		buildGTFSZip(t, "R1,A1,1,3\n"),
+	}
+	var downloads atomic.Int32
+	gtfsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(downloads.Add(1)) - 1
+		w.Write(bundles[min(n, len(bundles)-1)])
+	}))
+	defer gtfsServer.Close()
+
+	app := newTestApplication(t)
+	servers := []models.ObaServer{{ID: 1, GtfsUrl: gtfsServer.URL}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cacheDir := t.TempDir()
+
+	// The first download has nothing to compare with.
+	downloadGTFSBundles(servers, cacheDir, app.staticCache, app.bundleDiffs, app.history, logger)
+	if diffs := app.bundleDiffs.List(1); len(diffs) != 0 {
+		t.Fatalf("Expected no diffs after the first download, got %d", len(diffs))
+	}
+
+	downloadGTFSBundles(servers, cacheDir, app.staticCache, app.bundleDiffs, app.history, logger)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers/1/bundle-diffs", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var body struct {
+		Diffs []bundle.Diff `json:"diffs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Diffs) != 1 {
+		t.Fatalf("Expected 1 diff, got %d", len(body.Diffs))
+	}
+	if diff := body.Diffs[0]; len(diff.RoutesRemoved) != 1 || diff.RoutesRemoved[0] != "R2" || len(diff.RoutesAdded) != 0 {
+		t.Errorf("Expected route R2 to be removed, got %+v", diff)
+	}
+
+	if ratio := testutil.ToFloat64(metrics.BundleDiffRoutesRemovedRatio.WithLabelValues("1")); ratio != 0.5 {
+		t.Errorf("Expected half of the routes to be removed, got %v", ratio)
+	}
+
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers/99/bundle-diffs", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown server, got %d", rr.Code)
+	}
+}
+
+func TestBundleDiffsSurviveRestart(t *testing.T) {
+	bundles := [][]byte{
+		buildGTFSZip(t, "R1,A1,1,3\nR2,A1,2,3\n"),
+		buildGTFSZip(t, "R1,A1,1,3\n"),
+		buildGTFSZip(t, "R1,A1,1,3\nR3,A1,3,3\n"),
+	}
+	var downloads atomic.Int32
+	gtfsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(downloads.Add(1)) - 1
+		w.Write(bundles[min(n, len(bundles)-1)])
+	}))
+	defer gtfsServer.Close()
+
+	store := newTestHistory(t)
+	servers := []models.ObaServer{{ID: 1, GtfsUrl: gtfsServer.URL}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cacheDir := t.TempDir()
+
+	app := newTestApplication(t)
+	downloadGTFSBundles(servers, cacheDir, app.staticCache, app.bundleDiffs, store, logger)
+	downloadGTFSBundles(servers, cacheDir, app.staticCache, app.bundleDiffs, store, logger)
+	if diffs := app.bundleDiffs.List(1); len(diffs) != 1 {
+		t.Fatalf("Expected 1 diff before the restart, got %d", len(diffs))
+	}
+
+	// After a restart, the first download is compared with the stored summary.
+	app = newTestApplication(t)
+	restoreBundleDiffs(store, servers, app.bundleDiffs, logger)
+	downloadGTFSBundles(servers, cacheDir, app.staticCache, app.bundleDiffs, store, logger)
+
+	diffs := app.bundleDiffs.List(1)
+	if len(diffs) != 2 {
+		t.Fatalf("Expected the restored diff and a new one, got %d", len(diffs))
+	}
+	if diff := diffs[0]; len(diff.RoutesAdded) != 1 || diff.RoutesAdded[0] != "R3" || len(diff.RoutesRemoved) != 0 {
+		t.Errorf("Expected route R3 to be added compared with the bundle before the restart, got %+v", diff)
+	}
+	if diff := diffs[1]; len(diff.RoutesRemoved) != 1 || diff.RoutesRemoved[0] != "R2" {
+		t.Errorf("Expected the restored diff to remove route R2, got %+v", diff)
+	}
+}
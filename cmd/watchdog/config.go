@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/server"
+)
+
+// applyConfig validates configDoc and makes it the running configuration. Checks of
+// added, removed and changed servers are started and stopped, bundles of new servers
+// and of servers with a new GTFS URL are downloaded, and alert rules and notifiers are
+// replaced. The running configuration is kept when configDoc is invalid.
+func (app *application) applyConfig(configDoc *server.ConfigFile) error {
+	if err := server.ValidateServers(configDoc.Servers); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	app.mu.RLock()
+	previous := app.config.Servers
+	app.mu.RUnlock()
+
+	changes := server.DiffServers(previous, configDoc.Servers)
+	app.updateConfig(configDoc.Servers)
+
+	if app.notifier != nil {
+		if err := app.notifier.SetNotifiers(configDoc.Notifiers); err != nil {
+			app.logger.Error("Failed to refresh notifiers", "error", err)
+		}
+	}
+	if app.alertEngine != nil {
+		app.alertEngine.SetRules(configDoc.AlertRules)
+	}
+
+	if changes.Empty() {
+		return nil
+	}
+	app.logger.Info("Server configuration changed",
+		"added", changes.Added,
+		"removed", changes.Removed,
+		"changed", changes.Changed,
+	)
+
+	gtfsURLs := make(map[int]string, len(previous))
+	for _, s := range previous {
+		gtfsURLs[s.ID] = s.GtfsUrl
+	}
+	var downloads []models.ObaServer
+	for _, s := range configDoc.Servers {
+		if url, ok := gtfsURLs[s.ID]; s.GtfsUrl != "" && (!ok || url != s.GtfsUrl) {
+			downloads = append(downloads, s)
+		}
+	}
+	if len(downloads) > 0 {
+		go downloadGTFSBundles(downloads, app.cacheDir, app.staticCache, app.bundleDiffs, app.history, app.logger)
+	}
+
+	return nil
+}
+
+// currentServers returns the servers of the running configuration.
+func (app *application) currentServers() []models.ObaServer {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.config.Servers
+}
+
+// configFileChecksum returns the SHA-256 of the file at path, or the zero value if
+// it cannot be read.
+func configFileChecksum(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
+
+// watchConfigFile reloads the configuration file whenever its content no longer has
+// checksum, which is checked every interval, and whenever a signal arrives on reload.
+func watchConfigFile(path string, checksum [sha256.Size]byte, app *application, logger *slog.Logger, interval time.Duration, reload <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		forced := false
+		select {
+		case <-ticker.C:
+		case <-reload:
+			forced = true
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Error("Failed to read config file", "path", path, "error", err)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if sum == checksum && !forced {
+			continue
+		}
+		// Remember the checksum of invalid files too, so that they are reported once
+		// rather than on every poll.
+		checksum = sum
+
+		configDoc, err := server.ParseConfigFile(data)
+		if err == nil {
+			err = app.applyConfig(configDoc)
+		}
+		if err != nil {
+			logger.Error("Failed to reload config file, keeping the running configuration", "path", path, "error", err)
+			continue
+		}
+		logger.Info("Successfully reloaded config file", "path", path)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/server"
+)
+
+func TestApplyConfig(t *testing.T) {
+	app := newTestApplication(t)
+	var logBuffer bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&logBuffer, nil))
+
+	err := app.applyConfig(&server.ConfigFile{Servers: []models.ObaServer{
+		{ID: 1, Name: "Renamed Server"},
+		{ID: 2, Name: "New Server"},
+	}})
+	if err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if servers := app.currentServers(); len(servers) != 2 || servers[0].Name != "Renamed Server" {
+		t.Errorf("Expected the new servers to be applied, got %+v", servers)
+	}
+	if !strings.Contains(logBuffer.String(), "added=[2] removed=[] changed=[1]") {
+		t.Errorf("Expected the server changes to be logged, got %q", logBuffer.String())
+	}
+
+	err = app.applyConfig(&server.ConfigFile{Servers: []models.ObaServer{{ID: 3}, {ID: 3}}})
+	if err == nil {
+		t.Fatal("Expected an error for duplicate server IDs")
+	}
+	if servers := app.currentServers(); len(servers) != 2 {
+		t.Errorf("Expected the running configuration to be kept, got %+v", servers)
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	serverCount := func(app *application, want int) bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if len(app.currentServers()) == want {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	writeConfig(`[{"id": 1, "name": "Test Server", "oba_base_url": "https://test.example.com"}]`)
+
+	app := newTestApplication(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reload := make(chan os.Signal, 1)
+	go watchConfigFile(path, configFileChecksum(path), app, logger, 10*time.Millisecond, reload)
+
+	writeConfig(`[
+		{"id": 1, "name": "Test Server", "oba_base_url": "https://test.example.com"},
+		{"id": 2, "name": "Second Server", "oba_base_url": "https://second.example.com"}
+	]`)
+	if !serverCount(app, 2) {
+		t.Fatalf("Expected the changed file to be reloaded, got %+v", app.currentServers())
+	}
+
+	writeConfig(`[{"id": 1}, {"id": 1}]`)
+	time.Sleep(50 * time.Millisecond)
+	if servers := app.currentServers(); len(servers) != 2 {
+		t.Fatalf("Expected an invalid file to be ignored, got %+v", servers)
+	}
+
+	writeConfig(`[{"id": 3, "name": "Third Server", "oba_base_url": "https://third.example.com"}]`)
+	if !serverCount(app, 1) {
+		t.Fatalf("Expected the fixed file to be reloaded, got %+v", app.currentServers())
+	}
+
+	// A SIGHUP reloads the file even though it did not change.
+	app.updateConfig([]models.ObaServer{{ID: 1}, {ID: 2}})
+	reload <- syscall.SIGHUP
+	if !serverCount(app, 1) {
+		t.Errorf("Expected SIGHUP to reload the file, got %+v", app.currentServers())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// envelope wraps JSON responses in a top-level object.
+type envelope map[string]any
+
+// writeJSON encodes data as indented JSON and writes it with the given status and headers.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// errorResponse writes a JSON error message with the given status.
+func (app *application) errorResponse(w http.ResponseWriter, status int, message string) {
+	err := app.writeJSON(w, status, envelope{"error": message}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write error response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
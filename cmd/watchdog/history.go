@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/scheduler"
+	"watchdog.onebusaway.org/internal/status"
+	"watchdog.onebusaway.org/internal/validation"
+)
+
+const (
+	defaultHistoryLimit = 1000
+	maxHistoryLimit     = 10000
+)
+
+// countSamplesState names the trip and stop counts of earlier bundles of a server in
+// the history database.
+const countSamplesState = "validation_count_samples"
+
+// historyRecord converts a check result to its persistent form.
+func historyRecord(result scheduler.Result) history.Record {
+	record := history.Record{
+		ServerID: result.ServerID,
+		Check:    result.Check,
+		At:       result.StartedAt,
+		Duration: result.Duration,
+		OK:       result.Err == nil,
+		Value:    result.Value,
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	return record
+}
+
+// restoreStatus loads the results of the last 24 hours from the persistent history
+// into the in-memory status store, so that the status page survives restarts.
+func (app *application) restoreStatus(servers []models.ObaServer, now time.Time) error {
+	for _, server := range servers {
+		records, err := app.history.Records(history.Query{
+			ServerID: server.ID,
+			From:     now.Add(-status.HistoryRetention),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			result := scheduler.Result{
+				ServerID:  record.ServerID,
+				Check:     record.Check,
+				StartedAt: record.At,
+				Duration:  record.Duration,
+				Value:     record.Value,
+			}
+			if !record.OK {
+				result.Err = errors.New(record.Error)
+			}
+			app.status.Record(result)
+		}
+	}
+	return nil
+}
+
+// restoreCountSamples loads the trip and stop counts that new bundles of the server are
+// compared with from the history database, so that count drops are found across restarts.
+func (app *application) restoreCountSamples(serverID int) {
+	if app.history == nil {
+		return
+	}
+
+	var samples []validation.CountSample
+	ok, err := app.history.State(serverID, countSamplesState, &samples)
+	if err != nil {
+		app.logger.Error("Failed to restore GTFS bundle counts", "server_id", serverID, "error", err)
+		return
+	}
+	if ok {
+		app.validation.RestoreSamples(serverID, samples)
+	}
+}
+
+// saveCountSamples stores the trip and stop counts of the bundles of the server in the
+// history database.
+func (app *application) saveCountSamples(serverID int) {
+	if app.history == nil {
+		return
+	}
+
+	if err := app.history.PutState(serverID, countSamplesState, app.validation.Samples(serverID)); err != nil {
+		app.logger.Error("Failed to store GTFS bundle counts", "server_id", serverID, "error", err)
+	}
+}
+
+// pruneHistory periodically removes records older than the history retention.
+func pruneHistory(store *history.Store, logger *slog.Logger, interval time.Duration) {
+	for {
+		removed, err := store.Prune(time.Now())
+		if err != nil {
+			logger.Error("Failed to prune check history", "error", err)
+		} else if removed > 0 {
+			logger.Info("Pruned check history", "removed", removed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// serverHistoryHandler writes the check executions of a server. The optional query
+// parameters "check", "from" and "to" (RFC 3339, default the last 24 hours) and
+// "limit" narrow the result down.
+func (app *application) serverHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	if _, ok := app.findServer(id); !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	if app.history == nil {
+		app.errorResponse(w, http.StatusNotFound, "check history is disabled")
+		return
+	}
+
+	qs := r.URL.Query()
+	query := history.Query{
+		ServerID: id,
+		Check:    qs.Get("check"),
+		From:     time.Now().Add(-24 * time.Hour),
+		Limit:    defaultHistoryLimit,
+	}
+
+	if from := qs.Get("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			app.errorResponse(w, http.StatusBadRequest, "from must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if to := qs.Get("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			app.errorResponse(w, http.StatusBadRequest, "to must be an RFC 3339 timestamp")
+			return
+		}
+	}
+	if limit := qs.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxHistoryLimit {
+			app.errorResponse(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+			return
+		}
+	}
+
+	records, err := app.history.Records(query)
+	if err != nil {
+		app.logger.Error("Failed to query check history", "server_id", id, "error", err)
+		app.errorResponse(w, http.StatusInternalServerError, "failed to query check history")
+		return
+	}
+	if records == nil {
+		records = []history.Record{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"server_id": id, "records": records}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write history response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
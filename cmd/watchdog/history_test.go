@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/scheduler"
+	"watchdog.onebusaway.org/internal/status"
+)
+
+func newTestHistory(t *testing.T) *history.Store {
+	t.Helper()
+
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"), 0)
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestServerHistoryHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.history = newTestHistory(t)
+
+	now := time.Now()
+	days := 20.0
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: now.Add(-2 * time.Hour)})
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: now.Add(-time.Hour), Err: errors.New("timeout")})
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "bundle_expiration", StartedAt: now.Add(-time.Hour), Value: &days})
+
+	get := func(url string) (*httptest.ResponseRecorder, []history.Record) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+
+		var body struct {
+			Records []history.Record `json:"records"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr, body.Records
+	}
+
+	rr, records := get("/v1/servers/1/history")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if len(records) != 3 {
+		t.Errorf("Expected 3 records, got %d", len(records))
+	}
+
+	_, records = get("/v1/servers/1/history?check=ping&from=" + now.Add(-90*time.Minute).UTC().Format(time.RFC3339))
+	if len(records) != 1 || records[0].OK || records[0].Error != "timeout" {
+		t.Errorf("Expected the failing ping only, got %+v", records)
+	}
+
+	_, records = get("/v1/servers/1/history?check=bundle_expiration")
+	if len(records) != 1 || records[0].Value == nil || *records[0].Value != days {
+		t.Errorf("Expected the bundle expiration value, got %+v", records)
+	}
+
+	if rr, _ := get("/v1/servers/1/history?from=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid timestamp, got %d", rr.Code)
+	}
+	if rr, _ := get("/v1/servers/1/history?limit=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid limit, got %d", rr.Code)
+	}
+	if rr, _ := get("/v1/servers/9/history"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown server, got %d", rr.Code)
+	}
+}
+
+func TestRestoreStatus(t *testing.T) {
+	store := newTestHistory(t)
+	now := time.Now()
+
+	store.Add(history.Record{ServerID: 1, Check: "ping", At: now.Add(-48 * time.Hour), OK: true})
+	store.Add(history.Record{ServerID: 1, Check: "ping", At: now.Add(-time.Hour), OK: false, Error: "timeout"})
+
+	app := newTestApplication(t)
+	app.history = store
+
+	if err := app.restoreStatus(app.config.Servers, now); err != nil {
+		t.Fatalf("restoreStatus failed: %v", err)
+	}
+
+	if samples := app.status.History(1, now.Add(-status.HistoryRetention)); len(samples) != 1 {
+		t.Errorf("Expected only the last 24 hours to be restored, got %d samples", len(samples))
+	}
+	ping := app.status.Checks(1, []string{"ping"})[0]
+	if ping.Status != status.StatusFailing || ping.Error != "timeout" {
+		t.Errorf("Expected the restored ping to be failing, got %+v", ping)
+	}
+}
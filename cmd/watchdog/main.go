@@ -3,7 +3,6 @@ package main
 import (
 	"crypto/sha1"
 	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,14 +10,25 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/alerts"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/metrics"
 	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/notify"
+	"watchdog.onebusaway.org/internal/scheduler"
 	"watchdog.onebusaway.org/internal/server"
+	"watchdog.onebusaway.org/internal/status"
 	"watchdog.onebusaway.org/internal/utils"
+	"watchdog.onebusaway.org/internal/validation"
 )
 
 // Declare a string containing the application version number. Later in the book we'll
@@ -31,9 +41,27 @@ const version = "1.0.0"
 // logger, but it will grow to include a lot more as our build progresses.
 
 type application struct {
-	config server.Config
-	logger *slog.Logger
-	mu     sync.RWMutex
+	config      server.Config
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	scheduler   *scheduler.Scheduler
+	staticCache *bundle.Cache
+	alertEngine *alerts.Engine
+	notifier    *notify.Dispatcher
+	status      *status.Store
+	history     *history.Store
+	validation  *validation.Store
+	bundleDiffs *bundle.DiffStore
+	// realtimeValidation holds the latest GTFS-RT validation reports of every server.
+	realtimeValidation *validation.RealtimeStore
+	// vehicleHistory holds the vehicle positions of the previous plausibility check of every server.
+	vehicleHistory *metrics.VehicleHistory
+	// feedContent holds the content hashes of the GTFS-RT feeds of every server.
+	feedContent *metrics.FeedContentTracker
+	// cacheDir is the directory downloaded GTFS bundles are stored in.
+	cacheDir string
+	// gatherer provides the metrics shown on the status page. Nil means prometheus.DefaultGatherer.
+	gatherer prometheus.Gatherer
 }
 
 func main() {
@@ -43,8 +71,10 @@ func main() {
 	flag.StringVar(&cfg.Env, "env", "development", "Environment (development|staging|production)")
 
 	var (
-		configFile = flag.String("config-file", "", "Path to a local JSON configuration file")
-		configURL  = flag.String("config-url", "", "URL to a remote JSON configuration file")
+		configFile       = flag.String("config-file", "", "Path to a local JSON configuration file")
+		configURL        = flag.String("config-url", "", "URL to a remote JSON configuration file")
+		historyFile      = flag.String("history-file", "history.db", "Path to the check history database (empty to disable)")
+		historyRetention = flag.Duration("history-retention", history.DefaultRetention, "How long check results are kept in the history database")
 	)
 
 	flag.Parse()
@@ -60,13 +90,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	var servers []models.ObaServer
+	var configDoc *server.ConfigFile
 	
 
 	if *configFile != "" {
-		servers, err = loadConfigFromFile(*configFile)
+		configDoc, err = loadConfigFromFile(*configFile)
 	} else if *configURL != "" {
-		servers, err = loadConfigFromURL(*configURL, configAuthUser, configAuthPass)
+		configDoc, err = loadConfigFromURL(*configURL, configAuthUser, configAuthPass)
 	} else {
 		fmt.Println("Error: No configuration provided. Use --config-file or --config-url.")
 		flag.Usage()
@@ -78,6 +108,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	servers := configDoc.Servers
 	if len(servers) == 0 {
 		fmt.Println("Error: No servers found in configuration.")
 		os.Exit(1)
@@ -95,22 +126,69 @@ func main() {
 		os.Exit(1)
 	}
 
+	staticCache := bundle.NewCache()
+	bundleDiffs := bundle.NewDiffStore(bundle.DefaultDiffHistory)
+
+	var historyStore *history.Store
+	if *historyFile != "" {
+		historyStore, err = history.Open(*historyFile, *historyRetention)
+		if err != nil {
+			logger.Error("Failed to open check history", "error", err)
+			os.Exit(1)
+		}
+		restoreBundleDiffs(historyStore, servers, bundleDiffs, logger)
+	}
+
 	// Download GTFS bundles for all servers on startup
-	downloadGTFSBundles(servers, cacheDir, logger)
+	downloadGTFSBundles(servers, cacheDir, staticCache, bundleDiffs, historyStore, logger)
 
 	app := &application{
-		config: cfg,
-		logger: logger,
+		config:      cfg,
+		logger:      logger,
+		staticCache: staticCache,
+		status:      status.NewStore(),
+		history:     historyStore,
+		validation:  validation.NewStore(),
+		bundleDiffs: bundleDiffs,
+		cacheDir:    cacheDir,
+
+		realtimeValidation: validation.NewRealtimeStore(),
+		vehicleHistory:     metrics.NewVehicleHistory(),
+		feedContent:        metrics.NewFeedContentTracker(),
+	}
+
+	if app.history != nil {
+		if err := app.restoreStatus(servers, time.Now()); err != nil {
+			logger.Error("Failed to restore check history", "error", err)
+		}
+
+		go pruneHistory(app.history, logger, time.Hour)
+		go refreshUptime(app, 5*time.Minute)
 	}
 
+	app.notifier, err = notify.NewDispatcher(configDoc.Notifiers, logger)
+	if err != nil {
+		logger.Error("Failed to configure notifiers", "error", err)
+		os.Exit(1)
+	}
+
+	app.alertEngine = alerts.NewEngine(configDoc.AlertRules, prometheus.DefaultGatherer, app.handleAlert)
+
 	app.startMetricsCollection()
 
+	go evaluateAlerts(app.alertEngine, logger, 15*time.Second)
+
 	// Cron job to download GTFS bundles for all servers every 24 hours
-	go refreshGTFSBundles(servers, cacheDir, logger , 24 * time.Hour)
+	go refreshGTFSBundles(app.currentServers, cacheDir, staticCache, bundleDiffs, historyStore, logger, 24*time.Hour)
 
-	// If a remote URL is specified, refresh the configuration every minute
-	if *configURL != "" {
-		go refreshConfig(*configURL, configAuthUser, configAuthPass, app, logger, time.Minute)
+	// Reload the configuration on SIGHUP, when a local file changes and every minute
+	// from a remote URL
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	if *configFile != "" {
+		go watchConfigFile(*configFile, configFileChecksum(*configFile), app, logger, 5*time.Second, reload)
+	} else if *configURL != "" {
+		go refreshConfig(*configURL, configAuthUser, configAuthPass, app, logger, time.Minute, reload)
 	}
 
 	srv := &http.Server{
@@ -158,68 +236,205 @@ func createCacheDirectory(cacheDir string , logger *slog.Logger) error{
 	return nil
 }
 
-// downloadGTFSBundles downloads GTFS bundles for each server and caches them locally.
-func downloadGTFSBundles(servers []models.ObaServer, cacheDir string, logger *slog.Logger) {
+// downloadGTFSBundles downloads GTFS bundles for each server, caches them locally
+// and loads the parsed bundles into staticCache. When the content of a server's
+// bundle changed, the difference to the previous bundle is recorded in diffs. The
+// summary of the latest bundle and the diffs are kept in historyStore, if not nil,
+// so that the first download after a restart is compared too.
+func downloadGTFSBundles(servers []models.ObaServer, cacheDir string, staticCache *bundle.Cache, diffs *bundle.DiffStore, historyStore *history.Store, logger *slog.Logger) {
 	for _, server := range servers {
 		hash := sha1.Sum([]byte(server.GtfsUrl))
 		hashStr := hex.EncodeToString(hash[:])
-		cachePath := filepath.Join(cacheDir, fmt.Sprintf("server_%d_%s.zip", server.ID, hashStr))
+		serverID := strconv.Itoa(server.ID)
 
-		_, err := utils.DownloadGTFSBundle(server.GtfsUrl, cacheDir, server.ID, hashStr)
+		result, err := utils.DownloadGTFSBundle(server.GtfsUrl, cacheDir, server.ID, hashStr)
 		if err != nil {
+			metrics.BundleDownloadFailures.WithLabelValues(serverID).Inc()
 			logger.Error("Failed to download GTFS bundle", "server_id", server.ID, "error", err)
+			continue
+		}
+
+		metrics.BundleDownloadDuration.WithLabelValues(serverID).Set(result.Duration.Seconds())
+		metrics.BundleSize.WithLabelValues(serverID).Set(float64(result.Size))
+		metrics.BundleLastSuccessfulDownload.WithLabelValues(serverID).SetToCurrentTime()
+
+		previous, hasPrevious := staticCache.Get(server.ID)
+		if result.NotModified {
+			logger.Info("GTFS bundle not modified", "server_id", server.ID, "path", result.Path)
+			if hasPrevious && previous.Path == result.Path {
+				continue
+			}
 		} else {
-			logger.Info("Successfully downloaded GTFS bundle", "server_id", server.ID, "path", cachePath)
+			logger.Info("Successfully downloaded GTFS bundle", "server_id", server.ID, "path", result.Path, "resumed", result.Resumed)
+		}
+
+		b, err := staticCache.Load(server.ID, result.Path)
+		if err != nil {
+			logger.Error("Failed to load GTFS bundle", "server_id", server.ID, "path", result.Path, "error", err)
+			continue
+		}
+
+		now := time.Now()
+		summary := bundle.Summarize(b, now)
+
+		// The previous bundle is summarized for the same week as the new one, so that
+		// trips per day compare; a stored summary was made for the week of its download.
+		var before bundle.Summary
+		hasBefore := false
+		if hasPrevious {
+			before, hasBefore = bundle.Summarize(previous, now), true
+		} else if historyStore != nil {
+			before, hasBefore = loadBundleSummary(historyStore, server.ID, logger)
+		}
+		if hasBefore && before.Hash != summary.Hash {
+			recordBundleDiff(server, diffs, bundle.Compare(before, summary, now), logger)
+			saveBundleDiffs(historyStore, server.ID, diffs, logger)
 		}
+		saveBundleSummary(historyStore, server.ID, summary, logger)
 	}
+
+	recordStaticCacheStats(staticCache)
 }
 
-// refreshGTFSBundles periodically downloads GTFS bundles at the specified interval.
-func refreshGTFSBundles(servers []models.ObaServer, cacheDir string, logger *slog.Logger , interval time.Duration) {
+// refreshGTFSBundles periodically downloads the GTFS bundles of the servers returned
+// by servers at the specified interval.
+func refreshGTFSBundles(servers func() []models.ObaServer, cacheDir string, staticCache *bundle.Cache, diffs *bundle.DiffStore, historyStore *history.Store, logger *slog.Logger, interval time.Duration) {
 	for {
 		time.Sleep(interval)
-		downloadGTFSBundles(servers, cacheDir, logger)
+		downloadGTFSBundles(servers(), cacheDir, staticCache, diffs, historyStore, logger)
 	}
 }
 
 // refreshConfig periodically fetches remote config and updates the application servers.
-func refreshConfig(configURL, configAuthUser, configAuthPass string, app *application, logger *slog.Logger , interval time.Duration) {
+// A signal on reload fetches it immediately.
+func refreshConfig(configURL, configAuthUser, configAuthPass string, app *application, logger *slog.Logger , interval time.Duration, reload <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(interval)
-		newServers, err := loadConfigFromURL(configURL, configAuthUser, configAuthPass)
+		select {
+		case <-ticker.C:
+		case <-reload:
+		}
+
+		configDoc, err := loadConfigFromURL(configURL, configAuthUser, configAuthPass)
+		if err == nil {
+			err = app.applyConfig(configDoc)
+		}
 		if err != nil {
 			logger.Error("Failed to refresh remote config", "error", err)
 			continue
 		}
-
-		app.updateConfig(newServers)
 		logger.Info("Successfully refreshed server configuration")
 	}
 }
 
-// updateConfig safely updates the application's server configuration.
+// updateConfig safely updates the application's server configuration and
+// reschedules the checks of servers that were added, removed or changed. The state,
+// alerts and metric series of removed servers are dropped.
 func (app *application) updateConfig(newServers []models.ObaServer) {
 	app.mu.Lock()
 	defer app.mu.Unlock()
+	previous := app.config.Servers
 	app.config.Servers = newServers
+
+	if app.scheduler != nil {
+		app.scheduler.Sync(newServers)
+	}
+
+	serverIDs := make([]int, 0, len(newServers))
+	for _, server := range newServers {
+		serverIDs = append(serverIDs, server.ID)
+	}
+
+	keep := models.ServerIDSet(serverIDs)
+	for _, server := range previous {
+		if !keep[server.ID] {
+			metrics.DeleteServerSeries(server.ID)
+		}
+	}
+
+	retainServers(app.staticCache, serverIDs)
+	retainServers(app.status, serverIDs)
+	retainServers(app.validation, serverIDs)
+	retainServers(app.bundleDiffs, serverIDs)
+	retainServers(app.realtimeValidation, serverIDs)
+	retainServers(app.vehicleHistory, serverIDs)
+	retainServers(app.feedContent, serverIDs)
+	retainServers(app.alertEngine, serverIDs)
+
+	if app.staticCache != nil {
+		recordStaticCacheStats(app.staticCache)
+	}
+}
+
+// retainServers drops what store keeps for the servers not listed in serverIDs.
+// Stores the application was built without are left alone.
+func retainServers[T any, S interface {
+	*T
+	Retain(serverIDs []int)
+}](store S, serverIDs []int) {
+	if store != nil {
+		store.Retain(serverIDs)
+	}
+}
+
+// recordStaticCacheStats exports the size of the parsed GTFS bundle cache.
+func recordStaticCacheStats(staticCache *bundle.Cache) {
+	stats := staticCache.Stats()
+	metrics.StaticCacheBundles.Set(float64(stats.Bundles))
+	metrics.StaticCacheBytes.Set(float64(stats.Bytes))
+}
+
+// recordBundleDiff stores the diff of a changed bundle and exports it as metrics.
+func recordBundleDiff(server models.ObaServer, diffs *bundle.DiffStore, diff bundle.Diff, logger *slog.Logger) {
+	diffs.Add(server.ID, diff)
+
+	serverID := strconv.Itoa(server.ID)
+	removedRatio := 0.0
+	if diff.Previous.Routes > 0 {
+		removedRatio = float64(len(diff.RoutesRemoved)) / float64(diff.Previous.Routes)
+	}
+	metrics.BundleDiffRoutesAdded.WithLabelValues(serverID).Set(float64(len(diff.RoutesAdded)))
+	metrics.BundleDiffRoutesRemoved.WithLabelValues(serverID).Set(float64(len(diff.RoutesRemoved)))
+	metrics.BundleDiffRoutesRemovedRatio.WithLabelValues(serverID).Set(removedRatio)
+	metrics.BundleDiffStopCountDelta.WithLabelValues(serverID).Set(float64(diff.StopCountDelta))
+	metrics.BundleDiffTripsPerDayDelta.WithLabelValues(serverID).Set(diff.TripsPerDayDelta)
+	metrics.BundleDiffServiceEndShiftDays.WithLabelValues(serverID).Set(float64(diff.ServiceEndShiftDays))
+	metrics.BundleDiffAgenciesChanged.WithLabelValues(serverID).Set(float64(len(diff.AgenciesAdded) + len(diff.AgenciesRemoved)))
+	metrics.BundleLastChange.WithLabelValues(serverID).Set(float64(diff.ComparedAt.Unix()))
+
+	logger.Info("GTFS bundle changed",
+		"server_id", server.ID,
+		"routes_added", len(diff.RoutesAdded),
+		"routes_removed", len(diff.RoutesRemoved),
+		"stop_count_delta", diff.StopCountDelta,
+		"trips_per_day_delta", diff.TripsPerDayDelta,
+		"service_end_shift_days", diff.ServiceEndShiftDays,
+	)
 }
 
 
-func loadConfigFromFile(filePath string) ([]models.ObaServer, error) {
+// evaluateAlerts periodically evaluates the metric based alert rules.
+func evaluateAlerts(engine *alerts.Engine, logger *slog.Logger, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := engine.Evaluate(); err != nil {
+			logger.Error("Failed to gather metrics for alert rules", "error", err)
+		}
+	}
+}
+
+func loadConfigFromFile(filePath string) (*server.ConfigFile, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var servers []models.ObaServer
-	if err := json.Unmarshal(data, &servers); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
-	}
-
-	return servers, nil
+	return server.ParseConfigFile(data)
 }
 
-func loadConfigFromURL(url, authUser, authPass string) ([]models.ObaServer, error) {
+func loadConfigFromURL(url, authUser, authPass string) (*server.ConfigFile, error) {
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -245,12 +460,7 @@ func loadConfigFromURL(url, authUser, authPass string) ([]models.ObaServer, erro
 		return nil, fmt.Errorf("failed to read remote config: %v", err)
 	}
 
-	var servers []models.ObaServer
-	if err := json.Unmarshal(data, &servers); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
-	}
-
-	return servers, nil
+	return server.ParseConfigFile(data)
 }
 
 func setupSentry() {
@@ -10,10 +10,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"watchdog.onebusaway.org/internal/bundle"
 	"watchdog.onebusaway.org/internal/models"
 )
 
@@ -40,10 +42,11 @@ func TestLoadConfigFromFile(t *testing.T) {
 		}
 		tmpFile.Close()
 
-		servers, err := loadConfigFromFile(tmpFile.Name())
+		configDoc, err := loadConfigFromFile(tmpFile.Name())
 		if err != nil {
 			t.Fatalf("loadConfigFromFile failed: %v", err)
 		}
+		servers := configDoc.Servers
 
 		if len(servers) != 1 {
 			t.Fatalf("Expected 1 server, got %d", len(servers))
@@ -61,7 +64,7 @@ func TestLoadConfigFromFile(t *testing.T) {
 			GtfsRtApiValue:     "",
 		}
 
-		if servers[0] != expected {
+		if !reflect.DeepEqual(servers[0], expected) {
 			t.Errorf("Expected server %+v, got %+v", expected, servers[0])
 		}
 	})
@@ -110,10 +113,11 @@ func TestLoadConfigFromURL(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		servers, err := loadConfigFromURL(ts.URL, "user", "pass")
+		configDoc, err := loadConfigFromURL(ts.URL, "user", "pass")
 		if err != nil {
 			t.Fatalf("loadConfigFromURL failed: %v", err)
 		}
+		servers := configDoc.Servers
 
 		if len(servers) != 1 {
 			t.Fatalf("Expected 1 server, got %d", len(servers))
@@ -129,7 +133,7 @@ func TestLoadConfigFromURL(t *testing.T) {
 			VehiclePositionUrl: "https://vehicle.example.com",
 		}
 
-		if servers[0] != expected {
+		if !reflect.DeepEqual(servers[0], expected) {
 			t.Errorf("Expected server %+v, got %+v", expected, servers[0])
 		}
 	})
@@ -385,7 +389,7 @@ func TestRefreshConfig(t *testing.T) {
 	originalConfig := make([]models.ObaServer, len(app.config.Servers))
 	copy(originalConfig, app.config.Servers)
 	
-	go refreshConfig(mockServer.URL, "testuser", "testpass", app, testLogger, 100*time.Millisecond)
+	go refreshConfig(mockServer.URL, "testuser", "testpass", app, testLogger, 100*time.Millisecond, nil)
 	
 	time.Sleep(200 * time.Millisecond)
 	
@@ -422,10 +426,54 @@ func TestDownloadGTFSBundles(t *testing.T) {
 	tempDir := t.TempDir()
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	
-	downloadGTFSBundles(servers, tempDir, logger)
+	downloadGTFSBundles(servers, tempDir, bundle.NewCache(), bundle.NewDiffStore(bundle.DefaultDiffHistory), nil, logger)
 	
 }
 
+func TestDownloadGTFSBundlesLoadsStaticCache(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("..", "..", "testdata", "gtfs.zip"))
+	if err != nil {
+		t.Fatalf("Failed to read GTFS fixture: %v", err)
+	}
+
+	gtfsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture)
+	}))
+	defer gtfsServer.Close()
+
+	servers := []models.ObaServer{
+		{ID: 1, GtfsUrl: gtfsServer.URL},
+		{ID: 2, GtfsUrl: gtfsServer.URL},
+	}
+	staticCache := bundle.NewCache()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	downloadGTFSBundles(servers, t.TempDir(), staticCache, bundle.NewDiffStore(bundle.DefaultDiffHistory), nil, logger)
+
+	first, ok := staticCache.Get(1)
+	if !ok {
+		t.Fatal("Expected the bundle of server 1 to be cached")
+	}
+	second, ok := staticCache.Get(2)
+	if !ok {
+		t.Fatal("Expected the bundle of server 2 to be cached")
+	}
+	if first != second {
+		t.Error("Expected servers with the same bundle to share the parsed bundle")
+	}
+
+	app := newTestApplication(t)
+	app.staticCache = staticCache
+	app.updateConfig([]models.ObaServer{{ID: 2}})
+
+	if _, ok := staticCache.Get(1); ok {
+		t.Error("Expected the bundle of removed server 1 to be evicted")
+	}
+	if _, ok := staticCache.Get(2); !ok {
+		t.Error("Expected the bundle of server 2 to be kept")
+	}
+}
+
 func TestRefreshGTFSBundles(t *testing.T) {
 	var logBuffer bytes.Buffer
 	logger := slog.New(slog.NewTextHandler(&logBuffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
@@ -433,7 +481,7 @@ func TestRefreshGTFSBundles(t *testing.T) {
 	servers := []models.ObaServer{{ID: 1, Name: "Test Server", GtfsUrl: "http://example.com/gtfs.zip"}}
 	cacheDir := t.TempDir()
 	
-	go refreshGTFSBundles(servers, cacheDir, logger, 10*time.Millisecond)
+	go refreshGTFSBundles(func() []models.ObaServer { return servers }, cacheDir, bundle.NewCache(), bundle.NewDiffStore(bundle.DefaultDiffHistory), nil, logger, 10*time.Millisecond)
 	
 	time.Sleep(15*time.Millisecond)
 	
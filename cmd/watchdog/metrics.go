@@ -1,55 +1,377 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/bundle"
 	"watchdog.onebusaway.org/internal/metrics"
 	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/scheduler"
+	"watchdog.onebusaway.org/internal/uptime"
 	"watchdog.onebusaway.org/internal/utils"
+	"watchdog.onebusaway.org/internal/validation"
 )
 
+// pingCheck is the check whose results the uptime reports are computed from.
+func pingCheck() scheduler.Check {
+	return scheduler.Check{
+		Name:     uptime.Check,
+		Interval: 15 * time.Second,
+		Timeout:  10 * time.Second,
+		Run:      scheduler.WithoutValue(metrics.ServerPing),
+	}
+}
+
+// checks returns every check the watchdog runs against each server, together with
+// its default interval and timeout. Servers can override both in their "checks" config.
+func (app *application) checks() []scheduler.Check {
+	return []scheduler.Check{
+		pingCheck(),
+		{
+			Name:     "bundle_expiration",
+			Interval: time.Hour,
+			Timeout:  2 * time.Minute,
+			Run:      app.checkBundleExpiration,
+		},
+		{
+			Name:     "bundle_validation",
+			Interval: 10 * time.Minute,
+			Timeout:  5 * time.Minute,
+			Run:      app.checkBundleValidation,
+		},
+		{
+			Name:     "agencies_with_coverage",
+			Interval: 5 * time.Minute,
+			Timeout:  time.Minute,
+			Run:      scheduler.WithoutValue(app.checkAgenciesWithCoverage),
+		},
+		{
+			Name:     "vehicle_count",
+			Interval: 30 * time.Second,
+			Timeout:  20 * time.Second,
+			Run:      scheduler.WithoutValue(app.checkVehicleCount),
+		},
+		{
+			Name:     "vehicle_reconciliation",
+			Interval: 30 * time.Second,
+			Timeout:  20 * time.Second,
+			Run:      app.checkVehicleReconciliation,
+		},
+		{
+			Name:     "feed_content",
+			Interval: 30 * time.Second,
+			Timeout:  20 * time.Second,
+			Enabled: func(server models.ObaServer) bool {
+				return len(server.VehiclePositionFeeds()) > 0 || len(server.TripUpdateFeeds()) > 0
+			},
+			Run: app.checkFeedContent,
+		},
+		{
+			Name:     "vehicle_plausibility",
+			Interval: 30 * time.Second,
+			Timeout:  20 * time.Second,
+			Enabled:  func(server models.ObaServer) bool { return len(server.VehiclePositionFeeds()) > 0 },
+			Run:      app.checkVehiclePlausibility,
+		},
+		{
+			Name:     "arrivals",
+			Interval: 5 * time.Minute,
+			Timeout:  time.Minute,
+			Run:      app.checkArrivals,
+		},
+		{
+			Name:     "trip_updates",
+			Interval: 30 * time.Second,
+			Timeout:  time.Minute,
+			Enabled:  func(server models.ObaServer) bool { return len(server.TripUpdateFeeds()) > 0 },
+			Run:      app.checkTripUpdates,
+		},
+		{
+			Name:     "trip_coverage",
+			Interval: time.Minute,
+			Timeout:  30 * time.Second,
+			Enabled: func(server models.ObaServer) bool {
+				return len(server.VehiclePositionFeeds()) > 0 || len(server.TripUpdateFeeds()) > 0
+			},
+			Run: app.checkTripCoverage,
+		},
+		{
+			Name:     "realtime_validation",
+			Interval: 5 * time.Minute,
+			Timeout:  time.Minute,
+			Enabled:  func(server models.ObaServer) bool { return len(server.RealtimeFeeds()) > 0 },
+			Run:      app.checkRealtimeValidation,
+		},
+		{
+			Name:     "service_alerts",
+			Interval: time.Minute,
+			Timeout:  30 * time.Second,
+			Enabled:  func(server models.ObaServer) bool { return len(server.ServiceAlertsFeeds()) > 0 },
+			Run:      app.checkServiceAlerts,
+		},
+	}
+}
+
 func (app *application) startMetricsCollection() {
+	app.scheduler = scheduler.New(app.checks(), app.handleCheckResult)
+
+	app.mu.RLock()
+	servers := app.config.Servers
+	app.mu.RUnlock()
+
+	app.scheduler.Sync(servers)
+}
+
+// handleCheckResult logs failed checks, records every result for the status API and
+// the persistent history, and feeds it to the alert engine.
+func (app *application) handleCheckResult(result scheduler.Result) {
+	app.logCheckResult(result)
+	if app.status != nil {
+		app.status.Record(result)
+	}
+	if app.history != nil {
+		if err := app.history.Add(historyRecord(result)); err != nil {
+			app.logger.Error("Failed to store check result", "check", result.Check, "server_id", result.ServerID, "error", err)
+		}
+	}
+	if app.alertEngine != nil {
+		app.alertEngine.Observe(result)
+	}
+}
+
+func (app *application) logCheckResult(result scheduler.Result) {
+	if result.Err != nil {
+		app.logger.Error("Check failed",
+			"check", result.Check,
+			"server_id", result.ServerID,
+			"duration", result.Duration,
+			"error", result.Err,
+		)
+	}
+}
+
+// staticBundle returns the parsed GTFS bundle of the server. If the bundle has not
+// been loaded yet, for example because the download at startup failed, the most
+// recent bundle in the cache directory is loaded instead.
+func (app *application) staticBundle(server models.ObaServer) (*bundle.Bundle, error) {
+	if b, ok := app.staticCache.Get(server.ID); ok {
+		return b, nil
+	}
+
+	cachePath, err := utils.GetLastCachedFile(app.cacheDir, server.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	ticker := time.NewTicker(30 * time.Second)
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
+	b, err := app.staticCache.Load(server.ID, cachePath)
+	if err != nil {
+		return nil, err
+	}
+	recordStaticCacheStats(app.staticCache)
+
+	return b, nil
+}
+
+func (app *application) checkBundleExpiration(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
+	if err != nil {
+		return nil, err
+	}
+
+	earliest, _, err := metrics.CheckBundleExpiration(b, app.logger, time.Now(), server)
+	if err != nil {
+		return nil, err
+	}
 
-				app.mu.Lock()
-				servers := app.config.Servers
-				app.mu.Unlock()
+	return scheduler.Value(float64(earliest)), nil
+}
 
-				for _, server := range servers {
-					app.collectMetricsForServer(server)
-				}
+// checkBundleValidation validates the cached bundle of the server whenever it changed
+// since the last validation and fails while the bundle has validation errors.
+func (app *application) checkBundleValidation(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
+	if err != nil {
+		return nil, err
+	}
+
+	report, ok := app.validation.Current(server.ID)
+	if !ok || report.Hash != b.Hash {
+		if !ok {
+			app.restoreCountSamples(server.ID)
+		}
+		report, err = validation.ValidateFile(b.Path, b.Hash, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		app.validation.Add(server.ID, report)
+		app.saveCountSamples(server.ID)
+		recordValidationFindings(server, report)
+		app.logger.Info("Validated GTFS bundle", "server_id", server.ID, "path", b.Path,
+			"errors", report.Count(validation.SeverityError),
+			"warnings", report.Count(validation.SeverityWarning),
+		)
+	}
+
+	errorCount := report.Count(validation.SeverityError)
+	if errorCount > 0 {
+		return scheduler.Value(float64(errorCount)), fmt.Errorf("GTFS bundle has %d validation errors: %s", errorCount, strings.Join(report.Codes(validation.SeverityError), ", "))
+	}
+	return scheduler.Value(0), nil
+}
+
+// recordValidationFindings exports the findings of the current bundle of the server.
+func recordValidationFindings(server models.ObaServer, report *validation.Report) {
+	serverID := strconv.Itoa(server.ID)
+	metrics.BundleValidationFindings.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+
+	counts := make(map[[2]string]int)
+	for _, finding := range report.Findings {
+		counts[[2]string{string(finding.Severity), finding.Code}] += finding.Count
+	}
+	for key, count := range counts {
+		metrics.BundleValidationFindings.WithLabelValues(serverID, key[0], key[1]).Set(float64(count))
+	}
+}
+
+// checkRealtimeValidation validates the GTFS-RT feeds of the server and fails while
+// they have validation errors.
+func (app *application) checkRealtimeValidation(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := metrics.CheckRealtimeValidation(ctx, b.Static, server, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	app.realtimeValidation.Set(server.ID, reports)
+
+	errorCount := 0
+	var codes []string
+	for _, report := range reports {
+		errorCount += report.Count(validation.SeverityError)
+		for _, code := range report.Codes(validation.SeverityError) {
+			if !slices.Contains(codes, code) {
+				codes = append(codes, code)
 			}
 		}
-	}()
+	}
+	if errorCount > 0 {
+		sort.Strings(codes)
+		return scheduler.Value(float64(errorCount)), fmt.Errorf("GTFS-RT feeds have %d validation errors: %s", errorCount, strings.Join(codes, ", "))
+	}
+	return scheduler.Value(0), nil
 }
 
-func (app *application) collectMetricsForServer(server models.ObaServer) {
-	metrics.ServerPing(server)
-	cachePath, err := utils.GetLastCachedFile("cache", server.ID)
+func (app *application) checkAgenciesWithCoverage(ctx context.Context, server models.ObaServer) error {
+	b, err := app.staticBundle(server)
 	if err != nil {
-		app.logger.Error("Failed to get last cached file", "error", err)
-		return
+		return err
 	}
 
-	_, _, err = metrics.CheckBundleExpiration(cachePath, app.logger, time.Now(), server)
+	return metrics.CheckAgenciesWithCoverageMatch(ctx, b.Static, app.logger, server)
+}
+
+func (app *application) checkVehicleCount(ctx context.Context, server models.ObaServer) error {
+	return metrics.CheckVehicleCountMatch(ctx, app.feedContent, server)
+}
+
+func (app *application) checkVehicleReconciliation(ctx context.Context, server models.ObaServer) (*float64, error) {
+	result, err := metrics.CheckVehicleReconciliation(ctx, app.feedContent, server)
+	// A failed fetch returns an empty reconciliation, which has no meaningful score.
+	if result.GtfsRtVehicles > 0 || result.APIVehicles > 0 {
+		return scheduler.Value(result.Score()), err
+	}
+	return nil, err
+}
+
+func (app *application) checkFeedContent(ctx context.Context, server models.ObaServer) (*float64, error) {
+	changes, err := metrics.CheckFeedContentChanges(app.feedContent, server, time.Now())
+	var longest time.Duration
+	for _, change := range changes {
+		longest = max(longest, change.Unchanged)
+	}
+	if len(changes) > 0 {
+		return scheduler.Value(longest.Seconds()), err
+	}
+	return nil, err
+}
+
+func (app *application) checkVehiclePlausibility(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
 	if err != nil {
-		app.logger.Error("Failed to check GTFS bundle expiration", "error", err)
+		return nil, err
 	}
 
-	err = metrics.CheckAgenciesWithCoverageMatch(cachePath, app.logger, server)
+	result, err := metrics.CheckVehiclePlausibility(ctx, app.feedContent, b.Static, app.vehicleHistory, server, time.Now())
+	if result.Vehicles > 0 {
+		return scheduler.Value(float64(result.Implausible())), err
+	}
+	return nil, err
+}
+
+func (app *application) checkArrivals(ctx context.Context, server models.ObaServer) (*float64, error) {
+	// The static bundle is only needed to sample stops.
+	var b *bundle.Bundle
+	if len(server.ArrivalsProbe.StopIDs) == 0 {
+		var err error
+		if b, err = app.staticBundle(server); err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := metrics.CheckArrivals(ctx, b, server)
+	if ratio := summary.RealtimeRatio(); ratio >= 0 {
+		return scheduler.Value(ratio), err
+	}
+	return nil, err
+}
+
+func (app *application) checkTripUpdates(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := metrics.CheckTripUpdates(ctx, app.feedContent, b.Static, app.logger, server)
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduler.Value(float64(summary.TripUpdates)), nil
+}
 
+func (app *application) checkTripCoverage(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
 	if err != nil {
-		app.logger.Error("Failed to check agencies with coverage match metric", "error", err)
+		return nil, err
 	}
 
-	err = metrics.CheckVehicleCountMatch(server)
+	coverage, err := metrics.CheckTripCoverage(ctx, app.feedContent, b, server, time.Now())
+	if ratio := coverage.Ratio(); ratio >= 0 {
+		return scheduler.Value(ratio), err
+	}
+	return nil, err
+}
 
+func (app *application) checkServiceAlerts(ctx context.Context, server models.ObaServer) (*float64, error) {
+	b, err := app.staticBundle(server)
 	if err != nil {
-		app.logger.Error("Failed to check vehicle count match metric", "error", err)
+		return nil, err
 	}
-}
\ No newline at end of file
+
+	summary, err := metrics.CheckServiceAlerts(ctx, app.feedContent, b.Static, app.logger, server, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduler.Value(float64(summary.Active)), nil
+}
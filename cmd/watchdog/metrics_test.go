@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -41,14 +42,51 @@ func TestMetricsEndpoint(t *testing.T) {
 	}
 }
 
-func TestCollectMetricsForServer(t *testing.T) {
+func TestChecks(t *testing.T) {
 	app := newTestApplication(t)
-	
+
 	prometheus.DefaultRegisterer = prometheus.NewRegistry()
-	
+
 	testServer := app.config.Servers[0]
-	
-	app.collectMetricsForServer(testServer)
-	
+	vehicleServer := testServer
+	vehicleServer.VehiclePositionUrl = "https://test.example.com/vehicle-positions"
+
+	names := make(map[string]bool)
+	var enabled, vehicleEnabled []string
+	for _, check := range app.checks() {
+		if names[check.Name] {
+			t.Errorf("Duplicate check name %q", check.Name)
+		}
+		names[check.Name] = true
+
+		if check.Interval <= 0 || check.Timeout <= 0 {
+			t.Errorf("Check %q needs a positive interval and timeout", check.Name)
+		}
+
+		if check.Enabled == nil || check.Enabled(testServer) {
+			enabled = append(enabled, check.Name)
+		}
+		if check.Enabled == nil || check.Enabled(vehicleServer) {
+			vehicleEnabled = append(vehicleEnabled, check.Name)
+		}
+	}
+
+	// Realtime checks only run for servers with the feeds they read.
+	want := []string{
+		"ping", "bundle_expiration", "bundle_validation", "agencies_with_coverage",
+		"vehicle_count", "vehicle_reconciliation", "arrivals",
+	}
+	if !reflect.DeepEqual(enabled, want) {
+		t.Errorf("Expected checks %v for a server without realtime feeds, got %v", want, enabled)
+	}
+	want = []string{
+		"ping", "bundle_expiration", "bundle_validation", "agencies_with_coverage",
+		"vehicle_count", "vehicle_reconciliation", "feed_content", "vehicle_plausibility",
+		"arrivals", "trip_coverage", "realtime_validation",
+	}
+	if !reflect.DeepEqual(vehicleEnabled, want) {
+		t.Errorf("Expected checks %v for a server with a vehicle positions feed, got %v", want, vehicleEnabled)
+	}
+
 	getMetricsForTesting(t, metrics.ObaApiStatus)
-}
\ No newline at end of file
+}
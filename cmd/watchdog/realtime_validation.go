@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// serverRealtimeValidationHandler writes the latest validation reports of the GTFS-RT
+// feeds of the server identified by the :id parameter, one per feed.
+func (app *application) serverRealtimeValidationHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	if _, ok := app.findServer(id); !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	reports, ok := app.realtimeValidation.Current(id)
+	if !ok {
+		app.errorResponse(w, http.StatusNotFound, "the GTFS-RT feeds of the server have not been validated yet")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"server_id": id, "feeds": reports}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write realtime validation response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
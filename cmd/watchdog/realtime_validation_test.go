@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/validation"
+)
+
+func TestServerRealtimeValidationHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		return rr
+	}
+
+	if rr := get("/v1/servers/1/realtime-validation"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before validation, got %d", rr.Code)
+	}
+	if rr := get("/v1/servers/99/realtime-validation"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown server, got %d", rr.Code)
+	}
+
+	app.realtimeValidation.Set(1, []*validation.RealtimeReport{{
+		Feed:        "https://rt.example.com/vehicles",
+		ValidatedAt: time.Now(),
+		Entities:    10,
+		Findings: []validation.Finding{
+			{Severity: validation.SeverityWarning, Code: validation.CodeVehicleWithoutTrip, Count: 3, Examples: []string{"v1"}},
+		},
+	}})
+
+	rr := get("/v1/servers/1/realtime-validation")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var body struct {
+		ServerID int                         `json:"server_id"`
+		Feeds    []validation.RealtimeReport `json:"feeds"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.ServerID != 1 || len(body.Feeds) != 1 || body.Feeds[0].Entities != 10 || len(body.Feeds[0].Findings) != 1 {
+		t.Errorf("Unexpected response %+v", body)
+	}
+}
@@ -15,7 +15,17 @@ func (app *application) routes() http.Handler {
 	// endpoints using the HandlerFunc() method. Note that http.MethodGet and
 	// http.MethodPost are constants which equate to the strings "GET" and "POST"
 	// respectively.
+	router.HandlerFunc(http.MethodGet, "/", app.statusPageHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/alerts", app.alertsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers", app.serversHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id", app.serverHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id/bundle-diffs", app.serverBundleDiffsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id/history", app.serverHistoryHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id/realtime-validation", app.serverRealtimeValidationHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id/uptime", app.serverUptimeHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/servers/:id/validation", app.serverValidationHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/uptime", app.uptimeHandler)
 	router.Handler(http.MethodGet, "/metrics", promhttp.Handler())
 
 	// Return the httprouter instance.
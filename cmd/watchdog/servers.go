@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"watchdog.onebusaway.org/internal/alerts"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/status"
+)
+
+// serverStatus describes a configured server, the latest result of each of its
+// checks and its alerts.
+type serverStatus struct {
+	Server models.ObaServer     `json:"server"`
+	Status status.Status        `json:"status"`
+	Checks []status.CheckStatus `json:"checks"`
+	Alerts []alerts.Alert       `json:"alerts"`
+}
+
+// serversHandler writes the status of every configured server.
+func (app *application) serversHandler(w http.ResponseWriter, r *http.Request) {
+	app.mu.RLock()
+	servers := app.config.Servers
+	app.mu.RUnlock()
+
+	statuses := make([]serverStatus, 0, len(servers))
+	for _, server := range servers {
+		statuses = append(statuses, app.serverStatus(server))
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"servers": statuses}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write servers response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serverHandler writes the status of the server identified by the :id parameter.
+func (app *application) serverHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	server, ok := app.findServer(id)
+	if !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"server": app.serverStatus(server)}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write server response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (app *application) findServer(id int) (models.ObaServer, bool) {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	for _, server := range app.config.Servers {
+		if server.ID == id {
+			return server, true
+		}
+	}
+	return models.ObaServer{}, false
+}
+
+// serverStatus collects the status of server. Secrets in its configuration are redacted.
+func (app *application) serverStatus(server models.ObaServer) serverStatus {
+	var names []string
+	for _, check := range app.checks() {
+		if check.EnabledFor(server) {
+			names = append(names, check.Name)
+		}
+	}
+
+	checks := app.status.Checks(server.ID, names)
+
+	serverAlerts := []alerts.Alert{}
+	if app.alertEngine != nil {
+		for _, alert := range app.alertEngine.Alerts() {
+			if alert.ServerID == server.ID {
+				serverAlerts = append(serverAlerts, alert)
+			}
+		}
+	}
+
+	return serverStatus{
+		Server: server.Redacted(),
+		Status: status.Overall(checks),
+		Checks: checks,
+		Alerts: serverAlerts,
+	}
+}
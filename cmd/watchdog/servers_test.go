@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/scheduler"
+	"watchdog.onebusaway.org/internal/status"
+)
+
+func TestServersHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: time.Now(), Duration: time.Second})
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "test-key") {
+		t.Error("Response must not contain the OBA API key")
+	}
+
+	var body struct {
+		Servers []serverStatus `json:"servers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Servers) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(body.Servers))
+	}
+
+	server := body.Servers[0]
+	if server.Server.ID != 1 || server.Status != status.StatusOK {
+		t.Errorf("Unexpected server status: %+v", server)
+	}
+	// The test server has no trip updates URL, so that check is not listed.
+	for _, check := range server.Checks {
+		if check.Name == "trip_updates" {
+			t.Error("Expected the trip_updates check to be omitted")
+		}
+	}
+	if server.Checks[0].Name != "ping" || server.Checks[0].DurationMs != 1000 {
+		t.Errorf("Unexpected ping status: %+v", server.Checks[0])
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.handleCheckResult(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: time.Now(), Err: errors.New("connection refused")})
+
+	t.Run("Found", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers/1", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+
+		var body struct {
+			Server serverStatus `json:"server"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if body.Server.Status != status.StatusDown {
+			t.Errorf("Expected server to be down, got %s", body.Server.Status)
+		}
+		if body.Server.Checks[0].Error != "connection refused" {
+			t.Errorf("Expected the ping error, got %+v", body.Server.Checks[0])
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers/7", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rr.Code)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/servers/abc", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/status"
+	"watchdog.onebusaway.org/ui"
+)
+
+// Indicator levels of the status page.
+const (
+	levelGreen  = "green"
+	levelYellow = "yellow"
+	levelRed    = "red"
+	levelGrey   = "grey"
+)
+
+// bundleWarningDays is the number of days before expiration at which a bundle turns yellow.
+const bundleWarningDays = 14
+
+// sparklineBuckets is the number of bars in the 24 hour sparkline, one per 15 minutes.
+const sparklineBuckets = 96
+
+var statusTemplate = template.Must(template.ParseFS(ui.Files, "html/status.tmpl"))
+
+type statusPage struct {
+	GeneratedAt time.Time
+	Columns     []string
+	Servers     []statusPageServer
+}
+
+// ColumnCount is the number of columns of the server table.
+func (p statusPage) ColumnCount() int {
+	return len(p.Columns) + 3
+}
+
+type statusPageServer struct {
+	ID         int
+	Name       string
+	Status     status.Status
+	Indicators []indicator
+	Sparkline  sparkline
+}
+
+type indicator struct {
+	Level  string
+	Detail string
+}
+
+type sparkline struct {
+	Width  int
+	Height int
+	Bars   []sparkBar
+}
+
+type sparkBar struct {
+	X, Y, Width, Height float64
+	Level               string
+	Title               string
+}
+
+// statusPageHandler renders an HTML page with the health of every server.
+func (app *application) statusPageHandler(w http.ResponseWriter, r *http.Request) {
+	app.mu.RLock()
+	servers := app.config.Servers
+	app.mu.RUnlock()
+
+	gatherer := app.gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	values, err := gatherServerValues(gatherer,
+		"gtfs_bundle_days_until_earliest_expiration",
+		"oba_agencies_match",
+		"vehicle_count_match",
+	)
+	if err != nil {
+		app.logger.Warn("Failed to gather metrics for status page", "error", err)
+	}
+
+	now := time.Now()
+	page := statusPage{
+		GeneratedAt: now,
+		Columns:     []string{"API", "Bundle expiry", "Agency coverage", "Vehicle count"},
+	}
+	for _, server := range servers {
+		page.Servers = append(page.Servers, app.statusPageServer(server, values, now))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.ExecuteTemplate(w, "status", page); err != nil {
+		app.logger.Error("Failed to render status page", "error", err)
+	}
+}
+
+func (app *application) statusPageServer(server models.ObaServer, values map[string]map[int]float64, now time.Time) statusPageServer {
+	checks := app.status.Checks(server.ID, []string{"ping", "bundle_expiration", "agencies_with_coverage", "vehicle_count"})
+	byName := make(map[string]status.CheckStatus, len(checks))
+	for _, check := range checks {
+		byName[check.Name] = check
+	}
+
+	ping := byName["ping"]
+	api := indicator{Level: levelGrey, Detail: "not checked yet"}
+	switch ping.Status {
+	case status.StatusOK:
+		api = indicator{Level: levelGreen, Detail: fmt.Sprintf("%.0f ms", ping.DurationMs)}
+	case status.StatusFailing:
+		api = indicator{Level: levelRed, Detail: "unreachable"}
+	}
+
+	bundle := matchIndicator(byName["bundle_expiration"], 0, false, "", "")
+	if days, ok := values["gtfs_bundle_days_until_earliest_expiration"][server.ID]; ok && byName["bundle_expiration"].Status == status.StatusOK {
+		bundle = indicator{Level: levelGreen, Detail: fmt.Sprintf("%.0f days", days)}
+		switch {
+		case days <= 0:
+			bundle.Level = levelRed
+			bundle.Detail = "expired"
+		case days < bundleWarningDays:
+			bundle.Level = levelYellow
+		}
+	}
+
+	agencies, agenciesOK := values["oba_agencies_match"][server.ID]
+	vehicles, vehiclesOK := values["vehicle_count_match"][server.ID]
+
+	return statusPageServer{
+		ID:     server.ID,
+		Name:   server.Name,
+		Status: status.Overall(checks),
+		Indicators: []indicator{
+			api,
+			bundle,
+			matchIndicator(byName["agencies_with_coverage"], agencies, agenciesOK, "match", "mismatch"),
+			matchIndicator(byName["vehicle_count"], vehicles, vehiclesOK, "match", "mismatch"),
+		},
+		Sparkline: newSparkline(app.status.History(server.ID, now.Add(-status.HistoryRetention)), now),
+	}
+}
+
+// matchIndicator turns a check and its 0/1 match gauge into an indicator. A failing
+// check is red, a mismatch yellow and a match green.
+func matchIndicator(check status.CheckStatus, value float64, hasValue bool, match, mismatch string) indicator {
+	switch {
+	case check.Status == status.StatusFailing:
+		return indicator{Level: levelRed, Detail: "check failing"}
+	case check.Status == status.StatusUnknown || !hasValue:
+		return indicator{Level: levelGrey, Detail: "not checked yet"}
+	case value == 1:
+		return indicator{Level: levelGreen, Detail: match}
+	default:
+		return indicator{Level: levelYellow, Detail: mismatch}
+	}
+}
+
+// newSparkline draws one bar per 15 minutes of the last 24 hours. The bar height is
+// the share of successful check runs; empty periods are drawn as a thin grey line.
+func newSparkline(samples []status.Sample, now time.Time) sparkline {
+	const barWidth, gap, height = 2.0, 1.0, 20.0
+
+	buckets := status.Buckets(samples, now.Add(-status.HistoryRetention), now, sparklineBuckets)
+	line := sparkline{
+		Width:  int(float64(len(buckets)) * (barWidth + gap)),
+		Height: int(height),
+	}
+
+	for i, bucket := range buckets {
+		bar := sparkBar{
+			X:     float64(i) * (barWidth + gap),
+			Width: barWidth,
+			Title: bucket.Start.Format("15:04") + ": no data",
+		}
+
+		ratio := bucket.SuccessRatio()
+		switch {
+		case ratio < 0:
+			bar.Height = 1
+			bar.Level = levelGrey
+		case ratio == 1:
+			bar.Height = height
+			bar.Level = levelGreen
+		case ratio >= 0.9:
+			bar.Height = math.Max(height*ratio, 1)
+			bar.Level = levelYellow
+		default:
+			bar.Height = math.Max(height*ratio, 1)
+			bar.Level = levelRed
+		}
+		if ratio >= 0 {
+			bar.Title = fmt.Sprintf("%s: %d of %d checks passed", bucket.Start.Format("15:04"), bucket.Total-bucket.Failed, bucket.Total)
+		}
+		bar.Y = height - bar.Height
+
+		line.Bars = append(line.Bars, bar)
+	}
+
+	return line
+}
+
+// gatherServerValues reads the named metrics from gatherer, keyed by metric name and
+// server ID. When a metric has several series for a server the lowest value is used,
+// so that a single mismatching agency marks the server as mismatching.
+func gatherServerValues(gatherer prometheus.Gatherer, names ...string) (map[string]map[int]float64, error) {
+	values := make(map[string]map[int]float64, len(names))
+	for _, name := range names {
+		values[name] = make(map[int]float64)
+	}
+
+	families, err := gatherer.Gather()
+	for _, family := range families {
+		byServer, ok := values[family.GetName()]
+		if !ok {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			if metric.GetGauge() == nil {
+				continue
+			}
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "server_id" {
+					continue
+				}
+				id, convErr := strconv.Atoi(label.GetValue())
+				if convErr != nil {
+					continue
+				}
+				value := metric.GetGauge().GetValue()
+				if current, seen := byServer[id]; !seen || value < current {
+					byServer[id] = value
+				}
+			}
+		}
+	}
+
+	return values, err
+}
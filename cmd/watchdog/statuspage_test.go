@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/scheduler"
+	"watchdog.onebusaway.org/internal/status"
+)
+
+func TestStatusPageHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	expiration := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "gtfs_bundle_days_until_earliest_expiration", Help: "test"}, []string{"server_id"})
+	agencies := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "oba_agencies_match", Help: "test"}, []string{"server_id"})
+	vehicles := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "vehicle_count_match", Help: "test"}, []string{"agency_id", "server_id"})
+	registry.MustRegister(expiration, agencies, vehicles)
+
+	expiration.WithLabelValues("1").Set(5)
+	agencies.WithLabelValues("1").Set(1)
+	vehicles.WithLabelValues("a", "1").Set(1)
+	vehicles.WithLabelValues("b", "1").Set(0)
+
+	app := newTestApplication(t)
+	app.gatherer = registry
+
+	now := time.Now()
+	for _, check := range []string{"ping", "bundle_expiration", "agencies_with_coverage", "vehicle_count"} {
+		app.handleCheckResult(scheduler.Result{ServerID: 1, Check: check, StartedAt: now, Duration: 120 * time.Millisecond})
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected an HTML response, got %q", ct)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"Test Server",
+		"120 ms",
+		"5 days",
+		`<span class="indicator yellow" title="yellow"></span><span class="detail">5 days</span>`,
+		`<span class="indicator green" title="green"></span><span class="detail">match</span>`,
+		`<span class="indicator yellow" title="yellow"></span><span class="detail">mismatch</span>`,
+		"<svg",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in status page", want)
+		}
+	}
+}
+
+func TestNewSparkline(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	samples := []status.Sample{
+		{At: now.Add(-time.Minute), OK: true},
+		{At: now.Add(-2 * time.Minute), OK: false},
+	}
+
+	line := newSparkline(samples, now)
+	if len(line.Bars) != sparklineBuckets {
+		t.Fatalf("Expected %d bars, got %d", sparklineBuckets, len(line.Bars))
+	}
+
+	last := line.Bars[len(line.Bars)-1]
+	if last.Level != levelRed || last.Height != 10 {
+		t.Errorf("Expected a half height red bar, got %+v", last)
+	}
+	if first := line.Bars[0]; first.Level != levelGrey {
+		t.Errorf("Expected an empty grey bar, got %+v", first)
+	}
+}
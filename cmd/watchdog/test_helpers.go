@@ -6,7 +6,11 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/metrics"
 	"watchdog.onebusaway.org/internal/server"
+	"watchdog.onebusaway.org/internal/status"
+	"watchdog.onebusaway.org/internal/validation"
 
 	"watchdog.onebusaway.org/internal/models"
 )
@@ -36,8 +40,17 @@ func newTestApplication(t *testing.T) *application {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	return &application{
-		config: *cfg,
-		logger: logger,
+		config:      *cfg,
+		logger:      logger,
+		staticCache: bundle.NewCache(),
+		status:      status.NewStore(),
+		validation:  validation.NewStore(),
+		bundleDiffs: bundle.NewDiffStore(bundle.DefaultDiffHistory),
+		cacheDir:    "cache",
+
+		realtimeValidation: validation.NewRealtimeStore(),
+		vehicleHistory:     metrics.NewVehicleHistory(),
+		feedContent:        metrics.NewFeedContentTracker(),
 	}
 }
 
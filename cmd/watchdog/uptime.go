@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/metrics"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/uptime"
+)
+
+// serverUptime is the availability report of one server.
+type serverUptime struct {
+	ServerID   int             `json:"server_id"`
+	ServerName string          `json:"server_name"`
+	Reports    []uptime.Report `json:"reports"`
+}
+
+// uptimeSampleSpan returns how long a ping result of server is assumed to hold, from
+// the ping interval and timeout in effect for the server.
+func (app *application) uptimeSampleSpan(server models.ObaServer) time.Duration {
+	check := pingCheck()
+	return uptime.SampleSpan(check.IntervalFor(server), check.TimeoutFor(server))
+}
+
+// rollingUptime computes the rolling availability windows of server.
+func (app *application) rollingUptime(server models.ObaServer, now time.Time) (serverUptime, error) {
+	records, err := app.history.Records(history.Query{
+		ServerID: server.ID,
+		Check:    uptime.Check,
+		From:     now.Add(-uptime.LongestWindow()),
+		To:       now,
+	})
+	if err != nil {
+		return serverUptime{}, err
+	}
+
+	return serverUptime{
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		Reports:    uptime.Rolling(records, now, app.uptimeSampleSpan(server)),
+	}, nil
+}
+
+// recordUptime exports the rolling availability of every server as metrics.
+func (app *application) recordUptime(now time.Time) {
+	app.mu.RLock()
+	servers := app.config.Servers
+	app.mu.RUnlock()
+
+	for _, server := range servers {
+		report, err := app.rollingUptime(server, now)
+		if err != nil {
+			app.logger.Error("Failed to compute uptime", "server_id", server.ID, "error", err)
+			continue
+		}
+
+		serverID := strconv.Itoa(server.ID)
+		for _, r := range report.Reports {
+			if r.AvailabilityPercent == nil {
+				metrics.ServerAvailability.DeleteLabelValues(serverID, r.Window)
+			} else {
+				metrics.ServerAvailability.WithLabelValues(serverID, r.Window).Set(*r.AvailabilityPercent)
+			}
+			metrics.ServerOutages.WithLabelValues(serverID, r.Window).Set(float64(r.Outages))
+			metrics.ServerLongestOutage.WithLabelValues(serverID, r.Window).Set(r.LongestOutageSeconds)
+			if r.MeanTimeToRecoverySeconds == nil {
+				metrics.ServerMeanTimeToRecovery.DeleteLabelValues(serverID, r.Window)
+			} else {
+				metrics.ServerMeanTimeToRecovery.WithLabelValues(serverID, r.Window).Set(*r.MeanTimeToRecoverySeconds)
+			}
+		}
+	}
+}
+
+// refreshUptime periodically recomputes the uptime metrics.
+func refreshUptime(app *application, interval time.Duration) {
+	for {
+		app.recordUptime(time.Now())
+		time.Sleep(interval)
+	}
+}
+
+// uptimeHandler writes the rolling availability of every server.
+func (app *application) uptimeHandler(w http.ResponseWriter, r *http.Request) {
+	if app.history == nil {
+		app.errorResponse(w, http.StatusNotFound, "check history is disabled")
+		return
+	}
+
+	app.mu.RLock()
+	servers := app.config.Servers
+	app.mu.RUnlock()
+
+	now := time.Now()
+	reports := make([]serverUptime, 0, len(servers))
+	for _, server := range servers {
+		report, err := app.rollingUptime(server, now)
+		if err != nil {
+			app.logger.Error("Failed to compute uptime", "server_id", server.ID, "error", err)
+			app.errorResponse(w, http.StatusInternalServerError, "failed to compute uptime")
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"servers": reports}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write uptime response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serverUptimeHandler writes the availability of one server: the rolling windows, or,
+// with "from" and "to" (RFC 3339) or "month" (YYYY-MM, UTC), a report for that period.
+func (app *application) serverUptimeHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	server, ok := app.findServer(id)
+	if !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	if app.history == nil {
+		app.errorResponse(w, http.StatusNotFound, "check history is disabled")
+		return
+	}
+
+	from, to, custom, err := uptimePeriod(r)
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var report serverUptime
+	if custom {
+		records, qerr := app.history.Records(history.Query{ServerID: id, Check: uptime.Check, From: from, To: to})
+		err = qerr
+		report = serverUptime{
+			ServerID:   server.ID,
+			ServerName: server.Name,
+			Reports:    []uptime.Report{uptime.Compute(records, from, to, app.uptimeSampleSpan(server))},
+		}
+	} else {
+		report, err = app.rollingUptime(server, time.Now())
+	}
+	if err != nil {
+		app.logger.Error("Failed to compute uptime", "server_id", id, "error", err)
+		app.errorResponse(w, http.StatusInternalServerError, "failed to compute uptime")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"uptime": report}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write uptime response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// uptimePeriod parses the custom report period of an uptime request. custom is false
+// when the request asks for the rolling windows.
+func uptimePeriod(r *http.Request) (from, to time.Time, custom bool, err error) {
+	qs := r.URL.Query()
+
+	if month := qs.Get("month"); month != "" {
+		from, err = time.Parse("2006-01", month)
+		if err != nil {
+			return from, to, false, errors.New("month must be formatted as YYYY-MM")
+		}
+		return from, from.AddDate(0, 1, 0), true, nil
+	}
+
+	if qs.Get("from") == "" && qs.Get("to") == "" {
+		return from, to, false, nil
+	}
+
+	if from, err = time.Parse(time.RFC3339, qs.Get("from")); err != nil {
+		return from, to, false, errors.New("from must be an RFC 3339 timestamp")
+	}
+	to = time.Now()
+	if qs.Get("to") != "" {
+		if to, err = time.Parse(time.RFC3339, qs.Get("to")); err != nil {
+			return from, to, false, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+	if !to.After(from) {
+		return from, to, false, errors.New("to must be after from")
+	}
+
+	return from, to, true, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"watchdog.onebusaway.org/internal/history"
+	"watchdog.onebusaway.org/internal/metrics"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func TestServerUptimeHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.history = newTestHistory(t)
+	// Pings a minute apart are consecutive results with this interval, not gaps.
+	app.config.Servers[0].Checks = map[string]models.CheckSettings{"ping": {IntervalSeconds: 60}}
+
+	now := time.Now()
+	for i, ok := range []bool{true, false, true, true} {
+		app.history.Add(history.Record{ServerID: 1, Check: "ping", At: now.Add(time.Duration(i-4) * time.Minute), OK: ok})
+	}
+	month := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	app.history.Add(history.Record{ServerID: 1, Check: "ping", At: month, OK: false})
+	app.history.Add(history.Record{ServerID: 1, Check: "ping", At: month.Add(time.Minute), OK: true})
+
+	get := func(url string) (int, serverUptime) {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+
+		var body struct {
+			Uptime serverUptime `json:"uptime"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body.Uptime
+	}
+
+	code, report := get("/v1/servers/1/uptime")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if len(report.Reports) != 4 || report.Reports[0].Window != "1h" {
+		t.Fatalf("Expected the rolling windows, got %+v", report.Reports)
+	}
+	if report.Reports[0].Outages != 1 || report.Reports[0].AvailabilityPercent == nil {
+		t.Errorf("Expected one outage in the last hour, got %+v", report.Reports[0])
+	}
+
+	code, report = get("/v1/servers/1/uptime?month=2025-01")
+	if code != http.StatusOK || len(report.Reports) != 1 {
+		t.Fatalf("Expected a single monthly report, got %d %+v", code, report)
+	}
+	if r := report.Reports[0]; r.Outages != 1 || r.MeanTimeToRecoverySeconds == nil || *r.MeanTimeToRecoverySeconds != 60 {
+		t.Errorf("Unexpected monthly report %+v", r)
+	}
+
+	// With the default interval the minute between the results is a gap, so the
+	// outage ends without a recovery.
+	app.config.Servers[0].Checks = nil
+	_, report = get("/v1/servers/1/uptime?month=2025-01")
+	if r := report.Reports[0]; r.Outages != 1 || r.MeanTimeToRecoverySeconds != nil {
+		t.Errorf("Expected the outage to end at the gap, got %+v", r)
+	}
+
+	if code, _ := get("/v1/servers/1/uptime?month=January"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid month, got %d", code)
+	}
+	if code, _ := get("/v1/servers/1/uptime?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty period, got %d", code)
+	}
+}
+
+func TestRecordUptime(t *testing.T) {
+	app := newTestApplication(t)
+	app.history = newTestHistory(t)
+
+	now := time.Now()
+	app.history.Add(history.Record{ServerID: 1, Check: "ping", At: now.Add(-2 * time.Minute), OK: false})
+	app.history.Add(history.Record{ServerID: 1, Check: "ping", At: now.Add(-time.Minute), OK: true})
+
+	app.recordUptime(now)
+
+	if got := testutil.ToFloat64(metrics.ServerAvailability.WithLabelValues("1", "1h")); got != 50 {
+		t.Errorf("Expected 50%% availability, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.ServerOutages.WithLabelValues("1", "24h")); got != 1 {
+		t.Errorf("Expected 1 outage, got %v", got)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// serverValidationHandler writes the validation report of the current GTFS bundle of
+// the server identified by the :id parameter.
+func (app *application) serverValidationHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorResponse(w, http.StatusBadRequest, "invalid server id")
+		return
+	}
+
+	if _, ok := app.findServer(id); !ok {
+		app.errorResponse(w, http.StatusNotFound, "server not found")
+		return
+	}
+
+	report, ok := app.validation.Current(id)
+	if !ok {
+		app.errorResponse(w, http.StatusNotFound, "the GTFS bundle of the server has not been validated yet")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"server_id": id, "validation": report}, nil)
+	if err != nil {
+		app.logger.Error("Failed to write validation response", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
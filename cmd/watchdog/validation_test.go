@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/gtfstest"
+	"watchdog.onebusaway.org/internal/validation"
+)
+
+func TestServerValidationHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		return rr
+	}
+
+	if rr := get("/v1/servers/1/validation"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before validation, got %d", rr.Code)
+	}
+	if rr := get("/v1/servers/99/validation"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown server, got %d", rr.Code)
+	}
+
+	app.validation.Add(1, &validation.Report{
+		Hash:        "abc",
+		ValidatedAt: time.Now(),
+		Findings: []validation.Finding{
+			{Severity: validation.SeverityError, Code: validation.CodeDuplicateID, File: "stops.txt", Count: 2, Examples: []string{"S1"}},
+		},
+	})
+
+	rr := get("/v1/servers/1/validation")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var body struct {
+		ServerID   int               `json:"server_id"`
+		Validation validation.Report `json:"validation"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.ServerID != 1 || body.Validation.Hash != "abc" || len(body.Validation.Findings) != 1 {
+		t.Errorf("Unexpected response %+v", body)
+	}
+}
+
+func TestBundleValidationBaselineSurvivesRestart(t *testing.T) {
+	store := newTestHistory(t)
+	dir := t.TempDir()
+	feed := map[string]string{
+		"agency.txt": "agency_id,agency_name,agency_url,agency_timezone\nA1,Agency One,https://agency.example.com,America/Los_Angeles\n",
+		"routes.txt": "route_id,agency_id,route_short_name,route_type\nR1,A1,1,3\n",
+		"stops.txt":  "stop_id,stop_name,stop_lat,stop_lon\nS1,First,47.6,-122.3\nS2,Second,47.61,-122.31\n",
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"WK,1,1,1,1,1,0,0,20250101,20251231\n",
+		"trips.txt": "route_id,service_id,trip_id\nR1,WK,T1\nR1,WK,T2\nR1,WK,T3\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"T1,08:00:00,08:00:00,S1,1\nT2,09:00:00,09:00:00,S1,1\nT3,10:00:00,10:00:00,S1,1\n",
+	}
+
+	app := newTestApplication(t)
+	app.history = store
+	if _, err := app.staticCache.Load(1, gtfstest.WriteBundle(t, dir, "first.zip", feed)); err != nil {
+		t.Fatalf("Failed to load bundle: %v", err)
+	}
+	if _, err := app.checkBundleValidation(context.Background(), app.config.Servers[0]); err != nil {
+		t.Fatalf("Expected the first bundle to be valid, got %v", err)
+	}
+
+	// After a restart, the new bundle with a single trip is compared with the first one.
+	app = newTestApplication(t)
+	app.history = store
+	smaller := gtfstest.WithFiles(feed, map[string]string{
+		"trips.txt":      "route_id,service_id,trip_id\nR1,WK,T1\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nT1,08:00:00,08:00:00,S1,1\n",
+	})
+	if _, err := app.staticCache.Load(1, gtfstest.WriteBundle(t, dir, "second.zip", smaller)); err != nil {
+		t.Fatalf("Failed to load bundle: %v", err)
+	}
+	if _, err := app.checkBundleValidation(context.Background(), app.config.Servers[0]); err != nil {
+		t.Fatalf("Expected the second bundle to be valid, got %v", err)
+	}
+
+	report, _ := app.validation.Current(1)
+	if codes := report.Codes(validation.SeverityWarning); !slices.Contains(codes, validation.CodeTripCountDrop) {
+		t.Errorf("Expected a trip count drop compared with the bundle before the restart, got %+v", report.Findings)
+	}
+}
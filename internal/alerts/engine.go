@@ -0,0 +1,376 @@
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/scheduler"
+)
+
+// State is the lifecycle state of an alert.
+type State string
+
+const (
+	// StatePending means the condition holds but not yet for the rule's "for" duration.
+	StatePending State = "pending"
+	// StateFiring means the condition has held for at least the rule's "for" duration.
+	StateFiring State = "firing"
+	// StateResolved means the alert fired and the condition no longer holds.
+	StateResolved State = "resolved"
+)
+
+// resolvedRetention is how long resolved alerts stay visible in Alerts.
+const resolvedRetention = time.Hour
+
+// Alert is the state of one rule for one server.
+type Alert struct {
+	Rule        string     `json:"rule"`
+	ServerID    int        `json:"server_id"`
+	Severity    string     `json:"severity"`
+	Check       string     `json:"check,omitempty"`
+	Metric      string     `json:"metric,omitempty"`
+	State       State      `json:"state"`
+	Message     string     `json:"message"`
+	Value       *float64   `json:"value,omitempty"`
+	ActiveSince time.Time  `json:"active_since"`
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Flapping    bool       `json:"flapping"`
+	Receivers   []string   `json:"receivers,omitempty"`
+}
+
+type alertKey struct {
+	rule     string
+	serverID int
+}
+
+type alertState struct {
+	alert Alert
+	// transitions holds the times the alert started firing or resolved, for flap detection.
+	transitions []time.Time
+	// notified is the last state passed to the change callback.
+	notified State
+}
+
+// Engine evaluates alert rules against check results and exported metrics.
+type Engine struct {
+	gatherer prometheus.Gatherer
+	onChange func(Alert)
+	now      func() time.Time
+
+	mu     sync.Mutex
+	rules  []Rule
+	states map[alertKey]*alertState
+}
+
+// NewEngine creates an Engine evaluating rules. Metric rules read their values from
+// gatherer. onChange, if not nil, is called whenever an alert starts firing or is
+// resolved, unless the alert is flapping.
+func NewEngine(rules []Rule, gatherer prometheus.Gatherer, onChange func(Alert)) *Engine {
+	return &Engine{
+		gatherer: gatherer,
+		onChange: onChange,
+		now:      time.Now,
+		rules:    rules,
+		states:   make(map[alertKey]*alertState),
+	}
+}
+
+// SetRules replaces the rules of the engine. Alerts of rules that still exist keep their state.
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		names[rule.Name] = true
+	}
+	for key := range e.states {
+		if !names[key.rule] {
+			delete(e.states, key)
+		}
+	}
+	e.rules = rules
+}
+
+// Retain removes the alerts of all servers not listed in serverIDs, without notifying
+// them: a server removed from the configuration has nothing left to resolve.
+func (e *Engine) Retain(serverIDs []int) {
+	keep := models.ServerIDSet(serverIDs)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key := range e.states {
+		if !keep[key.serverID] {
+			delete(e.states, key)
+		}
+	}
+}
+
+// Rules returns the rules the engine currently evaluates.
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Observe evaluates the check rules matching the result of a check execution.
+func (e *Engine) Observe(result scheduler.Result) {
+	e.mu.Lock()
+	now := e.now()
+	var changed []Alert
+	for _, rule := range e.rules {
+		if rule.Check != result.Check || !rule.appliesTo(result.ServerID) {
+			continue
+		}
+
+		message := fmt.Sprintf("check %s succeeded", result.Check)
+		if result.Err != nil {
+			message = fmt.Sprintf("check %s failed: %v", result.Check, result.Err)
+		}
+		changed = e.update(changed, rule, result.ServerID, result.Err != nil, message, nil, now)
+	}
+	e.mu.Unlock()
+
+	e.notify(changed)
+}
+
+// Evaluate evaluates all metric rules, promotes pending alerts whose "for" duration
+// has passed and expires old resolved alerts. It should be called periodically.
+func (e *Engine) Evaluate() error {
+	var families []*dto.MetricFamily
+	var gatherErr error
+	if e.gatherer != nil {
+		families, gatherErr = e.gatherer.Gather()
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	e.mu.Lock()
+	now := e.now()
+	var changed []Alert
+
+	for _, rule := range e.rules {
+		if rule.Metric == "" {
+			continue
+		}
+		for serverID, v := range evaluateMetric(rule, byName[rule.Metric]) {
+			changed = e.update(changed, rule, serverID, v.active, v.message, &v.value, now)
+		}
+		// Servers whose series disappeared no longer satisfy the condition.
+		for key, state := range e.states {
+			if key.rule == rule.Name && state.alert.State != StateResolved {
+				if _, ok := byName[rule.Metric]; !ok || !hasServerSeries(byName[rule.Metric], key.serverID) {
+					changed = e.update(changed, rule, key.serverID, false, state.alert.Message, nil, now)
+				}
+			}
+		}
+	}
+
+	for _, rule := range e.rules {
+		for key, state := range e.states {
+			if key.rule != rule.Name {
+				continue
+			}
+			switch state.alert.State {
+			case StatePending:
+				changed = e.update(changed, rule, key.serverID, true, state.alert.Message, state.alert.Value, now)
+			case StateFiring, StateResolved:
+				changed = e.maybeNotify(changed, rule, state, now)
+			}
+			if state.alert.State == StateResolved && now.Sub(*state.alert.ResolvedAt) > max(resolvedRetention, rule.flapWindow()) {
+				delete(e.states, key)
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	e.notify(changed)
+	return gatherErr
+}
+
+// Alerts returns the state of every pending, firing and recently resolved alert,
+// ordered by server ID and rule name.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.states))
+	for _, state := range e.states {
+		alerts = append(alerts, state.alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].ServerID != alerts[j].ServerID {
+			return alerts[i].ServerID < alerts[j].ServerID
+		}
+		return alerts[i].Rule < alerts[j].Rule
+	})
+	return alerts
+}
+
+// update moves the alert of rule for the server according to whether its condition
+// holds and appends alerts that need to be notified to changed. e.mu must be held.
+func (e *Engine) update(changed []Alert, rule Rule, serverID int, active bool, message string, value *float64, now time.Time) []Alert {
+	key := alertKey{rule: rule.Name, serverID: serverID}
+	state := e.states[key]
+
+	if !active {
+		if state == nil {
+			return changed
+		}
+		switch state.alert.State {
+		case StatePending:
+			if state.alert.FiredAt == nil {
+				delete(e.states, key)
+				return changed
+			}
+			// The condition came back briefly after the alert resolved.
+			state.alert.State = StateResolved
+		case StateFiring:
+			state.alert.State = StateResolved
+			state.alert.ResolvedAt = &now
+			state.transitions = append(state.transitions, now)
+		}
+		return e.maybeNotify(changed, rule, state, now)
+	}
+
+	if state == nil {
+		state = &alertState{}
+		e.states[key] = state
+	}
+	if state.alert.State == "" || state.alert.State == StateResolved {
+		state.alert.State = StatePending
+		state.alert.ActiveSince = now
+	}
+
+	state.alert.Rule = rule.Name
+	state.alert.ServerID = serverID
+	state.alert.Severity = rule.severity()
+	state.alert.Check = rule.Check
+	state.alert.Metric = rule.Metric
+	state.alert.Receivers = rule.Receivers
+	state.alert.Message = message
+	state.alert.Value = value
+
+	if state.alert.State == StatePending && now.Sub(state.alert.ActiveSince) >= rule.forDuration() {
+		state.alert.State = StateFiring
+		state.alert.FiredAt = &now
+		state.alert.ResolvedAt = nil
+		state.transitions = append(state.transitions, now)
+	}
+
+	return e.maybeNotify(changed, rule, state, now)
+}
+
+// maybeNotify updates the flapping status of the alert and appends it to changed if
+// its firing or resolved state has not been notified yet. e.mu must be held.
+func (e *Engine) maybeNotify(changed []Alert, rule Rule, state *alertState, now time.Time) []Alert {
+	cutoff := now.Add(-rule.flapWindow())
+	recent := state.transitions[:0]
+	for _, t := range state.transitions {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	state.transitions = recent
+	state.alert.Flapping = rule.FlapThreshold > 0 && len(recent) >= rule.FlapThreshold
+
+	if state.alert.Flapping || state.alert.State == StatePending || state.alert.State == state.notified {
+		return changed
+	}
+	// A pending alert that resolves before firing was never notified, so there is nothing to resolve.
+	if state.alert.State == StateResolved && state.notified == "" {
+		return changed
+	}
+
+	state.notified = state.alert.State
+	return append(changed, state.alert)
+}
+
+func (e *Engine) notify(changed []Alert) {
+	if e.onChange == nil {
+		return
+	}
+	for _, alert := range changed {
+		e.onChange(alert)
+	}
+}
+
+type metricEvaluation struct {
+	active  bool
+	value   float64
+	message string
+}
+
+// evaluateMetric evaluates rule against every series of family, grouped by server_id.
+func evaluateMetric(rule Rule, family *dto.MetricFamily) map[int]metricEvaluation {
+	results := make(map[int]metricEvaluation)
+	if family == nil {
+		return results
+	}
+
+	compare := operators[rule.Operator]
+	for _, metric := range family.GetMetric() {
+		serverID, ok := serverIDLabel(metric)
+		if !ok || !rule.appliesTo(serverID) {
+			continue
+		}
+
+		value, ok := metricValue(metric)
+		if !ok {
+			continue
+		}
+
+		current, seen := results[serverID]
+		if seen && current.active {
+			continue
+		}
+		results[serverID] = metricEvaluation{
+			active:  compare(value, rule.Threshold),
+			value:   value,
+			message: fmt.Sprintf("%s is %v (threshold %s %v)", rule.Metric, value, rule.Operator, rule.Threshold),
+		}
+	}
+
+	return results
+}
+
+func hasServerSeries(family *dto.MetricFamily, serverID int) bool {
+	for _, metric := range family.GetMetric() {
+		if id, ok := serverIDLabel(metric); ok && id == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+func serverIDLabel(metric *dto.Metric) (int, bool) {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == "server_id" {
+			id, err := strconv.Atoi(label.GetValue())
+			return id, err == nil
+		}
+	}
+	return 0, false
+}
+
+func metricValue(metric *dto.Metric) (float64, bool) {
+	switch {
+	case metric.Gauge != nil:
+		return metric.GetGauge().GetValue(), true
+	case metric.Counter != nil:
+		return metric.GetCounter().GetValue(), true
+	case metric.Untyped != nil:
+		return metric.GetUntyped().GetValue(), true
+	}
+	return 0, false
+}
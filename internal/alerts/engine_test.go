@@ -0,0 +1,224 @@
+package alerts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/scheduler"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestEngine(rules []Rule, gatherer prometheus.Gatherer) (*Engine, *testClock, *[]Alert) {
+	clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var notified []Alert
+	engine := NewEngine(rules, gatherer, func(alert Alert) {
+		notified = append(notified, alert)
+	})
+	engine.now = clock.Now
+	return engine, clock, &notified
+}
+
+func failing(serverID int, check string) scheduler.Result {
+	return scheduler.Result{ServerID: serverID, Check: check, Err: errors.New("boom")}
+}
+
+func passing(serverID int, check string) scheduler.Result {
+	return scheduler.Result{ServerID: serverID, Check: check}
+}
+
+func TestEngineCheckRule(t *testing.T) {
+	rules := []Rule{{Name: "ping-down", Check: "ping", Severity: SeverityCritical, ForSeconds: 60}}
+	engine, clock, notified := newTestEngine(rules, nil)
+
+	engine.Observe(failing(1, "ping"))
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StatePending {
+		t.Fatalf("Expected one pending alert, got %+v", alerts)
+	}
+	if len(*notified) != 0 {
+		t.Fatalf("Expected no notification while pending, got %d", len(*notified))
+	}
+
+	clock.Advance(time.Minute)
+	if err := engine.Evaluate(); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	alerts = engine.Alerts()
+	if alerts[0].State != StateFiring || alerts[0].Severity != SeverityCritical {
+		t.Fatalf("Expected a firing critical alert, got %+v", alerts[0])
+	}
+	if len(*notified) != 1 || (*notified)[0].State != StateFiring {
+		t.Fatalf("Expected a firing notification, got %+v", *notified)
+	}
+
+	clock.Advance(15 * time.Second)
+	engine.Observe(passing(1, "ping"))
+	alerts = engine.Alerts()
+	if alerts[0].State != StateResolved || alerts[0].ResolvedAt == nil {
+		t.Fatalf("Expected a resolved alert, got %+v", alerts[0])
+	}
+	if len(*notified) != 2 || (*notified)[1].State != StateResolved {
+		t.Fatalf("Expected a resolved notification, got %+v", *notified)
+	}
+
+	clock.Advance(resolvedRetention + time.Minute)
+	engine.Evaluate()
+	if alerts := engine.Alerts(); len(alerts) != 0 {
+		t.Errorf("Expected resolved alert to expire, got %+v", alerts)
+	}
+}
+
+func TestEnginePendingAlertClearsWithoutNotification(t *testing.T) {
+	rules := []Rule{{Name: "ping-down", Check: "ping", ForSeconds: 60}}
+	engine, clock, notified := newTestEngine(rules, nil)
+
+	engine.Observe(failing(1, "ping"))
+	clock.Advance(30 * time.Second)
+	engine.Observe(passing(1, "ping"))
+
+	if alerts := engine.Alerts(); len(alerts) != 0 {
+		t.Errorf("Expected no alerts, got %+v", alerts)
+	}
+	if len(*notified) != 0 {
+		t.Errorf("Expected no notifications, got %+v", *notified)
+	}
+}
+
+func TestEngineServerSelection(t *testing.T) {
+	rules := []Rule{{Name: "ping-down", Check: "ping", Servers: []int{2}}}
+	engine, _, _ := newTestEngine(rules, nil)
+
+	engine.Observe(failing(1, "ping"))
+	engine.Observe(failing(2, "ping"))
+	engine.Observe(failing(2, "vehicle_count"))
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].ServerID != 2 || alerts[0].State != StateFiring {
+		t.Errorf("Expected only server 2 to fire, got %+v", alerts)
+	}
+}
+
+func TestEngineFlapSuppression(t *testing.T) {
+	rules := []Rule{{Name: "ping-down", Check: "ping", FlapThreshold: 3, FlapWindowSeconds: 600}}
+	engine, clock, notified := newTestEngine(rules, nil)
+
+	// fire, resolve, fire: the third transition marks the alert as flapping.
+	engine.Observe(failing(1, "ping"))
+	clock.Advance(time.Minute)
+	engine.Observe(passing(1, "ping"))
+	clock.Advance(time.Minute)
+	engine.Observe(failing(1, "ping"))
+
+	if len(*notified) != 2 {
+		t.Fatalf("Expected notifications to stop while flapping, got %+v", *notified)
+	}
+	if alerts := engine.Alerts(); !alerts[0].Flapping {
+		t.Fatalf("Expected alert to be flapping, got %+v", alerts[0])
+	}
+
+	// Once the transitions age out of the window the settled state is notified.
+	clock.Advance(10 * time.Minute)
+	engine.Evaluate()
+	if alerts := engine.Alerts(); alerts[0].Flapping {
+		t.Errorf("Expected alert to stop flapping, got %+v", alerts[0])
+	}
+	if len(*notified) != 3 || (*notified)[2].State != StateFiring {
+		t.Errorf("Expected a firing notification after settling, got %+v", *notified)
+	}
+}
+
+func TestEngineMetricRule(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "test_days_until_expiration",
+		Help: "Test gauge",
+	}, []string{"server_id", "agency_id"})
+	registry.MustRegister(gauge)
+
+	rules := []Rule{{Name: "expiring", Metric: "test_days_until_expiration", Operator: "<", Threshold: 7}}
+	engine, _, notified := newTestEngine(rules, registry)
+
+	gauge.WithLabelValues("1", "a").Set(30)
+	gauge.WithLabelValues("1", "b").Set(3)
+	gauge.WithLabelValues("2", "a").Set(30)
+
+	if err := engine.Evaluate(); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].ServerID != 1 || alerts[0].State != StateFiring {
+		t.Fatalf("Expected server 1 to fire, got %+v", alerts)
+	}
+	if alerts[0].Value == nil || *alerts[0].Value != 3 {
+		t.Errorf("Expected value 3, got %v", alerts[0].Value)
+	}
+	if len(*notified) != 1 {
+		t.Errorf("Expected one notification, got %d", len(*notified))
+	}
+
+	gauge.WithLabelValues("1", "b").Set(10)
+	engine.Evaluate()
+	if alerts := engine.Alerts(); alerts[0].State != StateResolved {
+		t.Errorf("Expected alert to resolve, got %+v", alerts[0])
+	}
+
+	gauge.WithLabelValues("2", "a").Set(1)
+	engine.Evaluate()
+	gauge.DeleteLabelValues("2", "a")
+	engine.Evaluate()
+	for _, alert := range engine.Alerts() {
+		if alert.ServerID == 2 && alert.State != StateResolved {
+			t.Errorf("Expected alert to resolve when the series disappears, got %+v", alert)
+		}
+	}
+}
+
+func TestEngineSetRules(t *testing.T) {
+	engine, _, _ := newTestEngine([]Rule{
+		{Name: "ping-down", Check: "ping"},
+		{Name: "vehicles", Check: "vehicle_count"},
+	}, nil)
+
+	engine.Observe(failing(1, "ping"))
+	engine.Observe(failing(1, "vehicle_count"))
+
+	engine.SetRules([]Rule{{Name: "ping-down", Check: "ping"}})
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].Rule != "ping-down" {
+		t.Errorf("Expected only the ping-down alert to remain, got %+v", alerts)
+	}
+	if len(engine.Rules()) != 1 {
+		t.Errorf("Expected 1 rule, got %d", len(engine.Rules()))
+	}
+}
+
+func TestEngineRetain(t *testing.T) {
+	engine, clock, notified := newTestEngine([]Rule{{Name: "ping-down", Check: "ping"}}, nil)
+
+	engine.Observe(failing(1, "ping"))
+	engine.Observe(failing(2, "ping"))
+	clock.Advance(time.Minute)
+	engine.Evaluate()
+	sent := len(*notified)
+
+	engine.Retain([]int{2})
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].ServerID != 2 {
+		t.Errorf("Expected only the alert of server 2 to remain, got %+v", alerts)
+	}
+	if len(*notified) != sent {
+		t.Errorf("Expected no notification for the alerts of removed servers, got %+v", (*notified)[sent:])
+	}
+}
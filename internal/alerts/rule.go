@@ -0,0 +1,130 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+)
+
+// Severities an alert rule can have.
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Rule describes when the watchdog should raise an alert for a server. A rule
+// either watches a check (firing while the check fails) or compares a metric
+// exported by the watchdog with a threshold.
+type Rule struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity,omitempty"`
+
+	// Check is the name of a scheduled check, e.g. "ping". The rule fires while the check fails.
+	Check string `json:"check,omitempty"`
+
+	// Metric is the name of a Prometheus metric labelled with server_id, e.g.
+	// "gtfs_bundle_days_until_earliest_expiration". The rule fires while any series
+	// of the server satisfies "value Operator Threshold".
+	Metric    string  `json:"metric,omitempty"`
+	Operator  string  `json:"operator,omitempty"`
+	Threshold float64 `json:"threshold"`
+
+	// ForSeconds is how long the condition must hold before the alert fires.
+	ForSeconds int `json:"for_seconds,omitempty"`
+
+	// An alert that changes between firing and resolved FlapThreshold times within
+	// FlapWindowSeconds is considered flapping and stops notifying until it settles.
+	FlapThreshold     int `json:"flap_threshold,omitempty"`
+	FlapWindowSeconds int `json:"flap_window_seconds,omitempty"`
+
+	// Servers restricts the rule to the listed server IDs. Empty means all servers.
+	Servers []int `json:"servers,omitempty"`
+	// Receivers names the notifiers that alerts of this rule are routed to.
+	Receivers []string `json:"receivers,omitempty"`
+}
+
+// defaultFlapWindow is used when a rule sets FlapThreshold without a window.
+const defaultFlapWindow = time.Hour
+
+var operators = map[string]func(value, threshold float64) bool{
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// Validate reports configuration mistakes in the rule.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("alert rule is missing a name")
+	}
+
+	switch r.Severity {
+	case "", SeverityCritical, SeverityWarning, SeverityInfo:
+	default:
+		return fmt.Errorf("alert rule %q has unknown severity %q", r.Name, r.Severity)
+	}
+
+	if (r.Check == "") == (r.Metric == "") {
+		return fmt.Errorf("alert rule %q must set exactly one of check or metric", r.Name)
+	}
+
+	if r.Metric != "" {
+		if _, ok := operators[r.Operator]; !ok {
+			return fmt.Errorf("alert rule %q has unknown operator %q", r.Name, r.Operator)
+		}
+	}
+
+	if r.ForSeconds < 0 || r.FlapThreshold < 0 || r.FlapWindowSeconds < 0 {
+		return fmt.Errorf("alert rule %q has a negative duration or threshold", r.Name)
+	}
+
+	return nil
+}
+
+// ValidateRules validates every rule and checks that rule names are unique.
+func ValidateRules(rules []Rule) error {
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate alert rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+func (r Rule) severity() string {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+func (r Rule) appliesTo(serverID int) bool {
+	if len(r.Servers) == 0 {
+		return true
+	}
+	for _, id := range r.Servers {
+		if id == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) forDuration() time.Duration {
+	return time.Duration(r.ForSeconds) * time.Second
+}
+
+func (r Rule) flapWindow() time.Duration {
+	if r.FlapWindowSeconds == 0 {
+		return defaultFlapWindow
+	}
+	return time.Duration(r.FlapWindowSeconds) * time.Second
+}
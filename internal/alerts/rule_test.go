@@ -0,0 +1,65 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"CheckRule", Rule{Name: "ping-down", Check: "ping"}, false},
+		{"MetricRule", Rule{Name: "expiring", Metric: "m", Operator: "<", Threshold: 7}, false},
+		{"MissingName", Rule{Check: "ping"}, true},
+		{"CheckAndMetric", Rule{Name: "both", Check: "ping", Metric: "m", Operator: "<"}, true},
+		{"NeitherCheckNorMetric", Rule{Name: "none"}, true},
+		{"UnknownOperator", Rule{Name: "op", Metric: "m", Operator: "=~"}, true},
+		{"UnknownSeverity", Rule{Name: "sev", Check: "ping", Severity: "page"}, true},
+		{"NegativeFor", Rule{Name: "for", Check: "ping", ForSeconds: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRulesDuplicateNames(t *testing.T) {
+	rules := []Rule{
+		{Name: "ping-down", Check: "ping"},
+		{Name: "ping-down", Check: "vehicle_count"},
+	}
+	if err := ValidateRules(rules); err == nil {
+		t.Error("Expected an error for duplicate rule names")
+	}
+}
+
+func TestRuleDefaults(t *testing.T) {
+	rule := Rule{Name: "r", Check: "ping"}
+
+	if got := rule.severity(); got != SeverityWarning {
+		t.Errorf("Expected default severity %q, got %q", SeverityWarning, got)
+	}
+	if got := rule.flapWindow(); got != defaultFlapWindow {
+		t.Errorf("Expected default flap window %v, got %v", defaultFlapWindow, got)
+	}
+	if !rule.appliesTo(42) {
+		t.Error("Expected a rule without servers to apply to every server")
+	}
+
+	rule.Servers = []int{1}
+	rule.ForSeconds = 30
+	if rule.appliesTo(42) {
+		t.Error("Expected rule to only apply to server 1")
+	}
+	if got := rule.forDuration(); got != 30*time.Second {
+		t.Errorf("Expected for duration 30s, got %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+)
+
+// Bundle is a parsed static GTFS bundle together with information about the file it came from.
+type Bundle struct {
+	// Hash is the hex encoded SHA-256 of the zip file content.
+	Hash     string
+	Path     string
+	LoadedAt time.Time
+	Static   *gtfs.Static
+	// SizeBytes estimates the memory held by the parsed bundle using the
+	// uncompressed size of the files in the zip archive.
+	SizeBytes int64
+	// FeedInfo is nil when the bundle has no feed_info.txt.
+	FeedInfo *FeedInfo
+	// Calendars holds the calendar.txt date range of each service ID. Services only
+	// defined in calendar_dates.txt have no entry.
+	Calendars map[string]DateRange
+	// StopAgencies holds the agency of the routes serving each stop, keyed by stop ID.
+	// It is nil when the bundle has a single agency.
+	StopAgencies map[string]string
+}
+
+// Load reads and parses the bundle at path without caching it.
+func Load(path string) (*Bundle, error) {
+	data, hash, err := readBundleFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, hash, path)
+}
+
+func readBundleFile(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+func parse(data []byte, hash, path string) (*Bundle, error) {
+	staticData, err := gtfs.ParseStatic(data, gtfs.ParseStaticOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	feedInfo, calendars := readCalendarFiles(data, staticData)
+
+	return &Bundle{
+		Hash:      hash,
+		Path:      path,
+		LoadedAt:  time.Now(),
+		Static:    staticData,
+		SizeBytes: uncompressedSize(data),
+		FeedInfo:  feedInfo,
+		Calendars: calendars,
+
+		StopAgencies: stopAgencies(staticData),
+	}, nil
+}
+
+// stopAgencies maps each stop of a bundle with several agencies to the agency of the
+// routes serving it. A stop served by several agencies gets the agency of the first
+// trip. Bundles with a single agency return nil. Walking every stop time is costly for
+// large bundles, so it is done once when the bundle is loaded.
+func stopAgencies(staticData *gtfs.Static) map[string]string {
+	if len(staticData.Agencies) < 2 {
+		return nil
+	}
+
+	agencies := make(map[string]string)
+	for _, trip := range staticData.Trips {
+		if trip.Route == nil || trip.Route.Agency == nil {
+			continue
+		}
+		for _, stopTime := range trip.StopTimes {
+			if stopTime.Stop == nil {
+				continue
+			}
+			if _, ok := agencies[stopTime.Stop.Id]; !ok {
+				agencies[stopTime.Stop.Id] = trip.Route.Agency.Id
+			}
+		}
+	}
+	return agencies
+}
+
+func uncompressedSize(data []byte) int64 {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return int64(len(data))
+	}
+
+	var size int64
+	for _, file := range reader.File {
+		size += int64(file.UncompressedSize64)
+	}
+	return size
+}
@@ -0,0 +1,119 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/gtfstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Fixture", func(t *testing.T) {
+		b, err := Load(getFixturePath(t, "gtfs.zip"))
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+
+		if len(b.Static.Agencies) != 1 {
+			t.Errorf("Expected 1 agency, got %d", len(b.Static.Agencies))
+		}
+		if len(b.Hash) != 64 {
+			t.Errorf("Expected a hex encoded SHA-256 hash, got %q", b.Hash)
+		}
+		// The uncompressed size of the files in testdata/gtfs.zip.
+		if b.SizeBytes != 19330193 {
+			t.Errorf("Expected size of 19330193 bytes, got %d", b.SizeBytes)
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		if _, err := Load("invalid/path/to/gtfs.zip"); err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	})
+
+	t.Run("NotAZip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gtfs.zip")
+		if err := os.WriteFile(path, []byte("<html>Not Found</html>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Load(path); err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	})
+
+	t.Run("MissingRequiredFile", func(t *testing.T) {
+		files := gtfstest.WithFiles(minimalFeed, map[string]string{"stop_times.txt": ""})
+		path := gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", files)
+
+		if _, err := Load(path); err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	})
+}
+
+func TestLoadCalendarFiles(t *testing.T) {
+	t.Run("FeedInfo", func(t *testing.T) {
+		files := gtfstest.WithFiles(minimalFeed, map[string]string{
+			"feed_info.txt": "\ufefffeed_publisher_name,feed_publisher_url,feed_lang,feed_start_date,feed_end_date,feed_version\n" +
+				"Agency One,https://agency.example.com,en,20250101,20250630,v42\n",
+			"calendar_dates.txt": "service_id,date,exception_type\nWK,20260105,1\n",
+		})
+		b, err := Load(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", files))
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+
+		if b.FeedInfo == nil {
+			t.Fatal("Expected feed info to be read")
+		}
+		if b.FeedInfo.Version != "v42" || b.FeedInfo.EndDate.Format("20060102") != "20250630" {
+			t.Errorf("Unexpected feed info %+v", b.FeedInfo)
+		}
+		if b.FeedInfo.EndDate.Location().String() != "America/Los_Angeles" {
+			t.Errorf("Expected dates in the agency timezone, got %v", b.FeedInfo.EndDate.Location())
+		}
+
+		// The calendar range excludes the calendar_dates.txt addition.
+		if got := b.Calendars["WK"].EndDate.Format("20060102"); got != "20251231" {
+			t.Errorf("Expected calendar end date 20251231, got %s", got)
+		}
+	})
+
+	t.Run("NoFeedInfo", func(t *testing.T) {
+		b, err := Load(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", minimalFeed))
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if b.FeedInfo != nil {
+			t.Errorf("Expected no feed info, got %+v", b.FeedInfo)
+		}
+	})
+}
+
+func TestStopAgencies(t *testing.T) {
+	agencyA, agencyB := &gtfs.Agency{Id: "A"}, &gtfs.Agency{Id: "B"}
+	stops := []gtfs.Stop{{Id: "s1"}, {Id: "s2"}, {Id: "s3"}}
+	staticData := &gtfs.Static{
+		Agencies: []gtfs.Agency{*agencyA, *agencyB},
+		Stops:    stops,
+		Trips: []gtfs.ScheduledTrip{
+			{ID: "t1", Route: &gtfs.Route{Id: "r1", Agency: agencyA}, StopTimes: []gtfs.ScheduledStopTime{{Stop: &stops[0]}}},
+			{ID: "t2", Route: &gtfs.Route{Id: "r2", Agency: agencyB}, StopTimes: []gtfs.ScheduledStopTime{{Stop: &stops[1]}, {Stop: &stops[0]}}},
+		},
+	}
+
+	// s1 keeps the agency of the first trip serving it and s3 is served by no trip.
+	if got, want := stopAgencies(staticData), map[string]string{"s1": "A", "s2": "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected stop agencies %v, got %v", want, got)
+	}
+
+	staticData.Agencies = staticData.Agencies[:1]
+	if got := stopAgencies(staticData); got != nil {
+		t.Errorf("Expected no stop agencies for a single agency bundle, got %v", got)
+	}
+}
@@ -0,0 +1,158 @@
+package bundle
+
+import (
+	"sort"
+	"sync"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// Cache keeps the parsed static GTFS bundle of every server in memory so that
+// checks do not have to re-parse the zip file on every run. Servers whose
+// bundles have identical content share a single parsed copy.
+type Cache struct {
+	mu       sync.RWMutex
+	byHash   map[string]*cacheEntry
+	byServer map[int]string
+}
+
+type cacheEntry struct {
+	bundle  *Bundle
+	servers map[int]struct{}
+}
+
+// CacheStats describes the current content of a Cache.
+type CacheStats struct {
+	Bundles int
+	Servers int
+	// Bytes is the estimated memory held by all cached bundles, see Bundle.SizeBytes.
+	Bytes int64
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		byHash:   make(map[string]*cacheEntry),
+		byServer: make(map[int]string),
+	}
+}
+
+// Load reads the bundle at path and makes it the current bundle of the server.
+// The file is only parsed if no other server already uses a bundle with the same
+// content. The previous bundle of the server is released.
+func (c *Cache) Load(serverID int, path string) (*Bundle, error) {
+	data, hash, err := readBundleFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	entry, ok := c.byHash[hash]
+	c.mu.RUnlock()
+
+	var parsed *Bundle
+	if ok {
+		parsed = entry.bundle
+	} else {
+		parsed, err = parse(data, hash, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another goroutine may have cached the same content while we were parsing.
+	entry, ok = c.byHash[hash]
+	if !ok {
+		entry = &cacheEntry{bundle: parsed, servers: make(map[int]struct{})}
+		c.byHash[hash] = entry
+	}
+
+	if previous, ok := c.byServer[serverID]; ok && previous != hash {
+		c.release(serverID, previous)
+	}
+	entry.servers[serverID] = struct{}{}
+	c.byServer[serverID] = hash
+
+	return entry.bundle, nil
+}
+
+// Get returns the current bundle of the server, if one has been loaded.
+func (c *Cache) Get(serverID int) (*Bundle, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hash, ok := c.byServer[serverID]
+	if !ok {
+		return nil, false
+	}
+	return c.byHash[hash].bundle, true
+}
+
+// Remove drops the bundle of the server. The parsed bundle is evicted once no
+// other server uses it.
+func (c *Cache) Remove(serverID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hash, ok := c.byServer[serverID]; ok {
+		c.release(serverID, hash)
+		delete(c.byServer, serverID)
+	}
+}
+
+// Retain removes the bundles of all servers not listed in serverIDs.
+func (c *Cache) Retain(serverIDs []int) {
+	keep := models.ServerIDSet(serverIDs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for serverID, hash := range c.byServer {
+		if keep[serverID] {
+			continue
+		}
+		c.release(serverID, hash)
+		delete(c.byServer, serverID)
+	}
+}
+
+// ServerIDs returns the IDs of all servers with a cached bundle in ascending order.
+func (c *Cache) ServerIDs() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]int, 0, len(c.byServer))
+	for id := range c.byServer {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Stats returns the number of cached bundles and their estimated memory use.
+func (c *Cache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := CacheStats{Bundles: len(c.byHash), Servers: len(c.byServer)}
+	for _, entry := range c.byHash {
+		stats.Bytes += entry.bundle.SizeBytes
+	}
+	return stats
+}
+
+// release detaches the server from the bundle with the given hash and evicts the
+// bundle once it is unused. c.mu must be held for writing.
+func (c *Cache) release(serverID int, hash string) {
+	entry, ok := c.byHash[hash]
+	if !ok {
+		return
+	}
+	delete(entry.servers, serverID)
+	if len(entry.servers) == 0 {
+		delete(c.byHash, hash)
+	}
+}
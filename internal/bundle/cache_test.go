@@ -0,0 +1,75 @@
+package bundle
+
+import (
+	"reflect"
+	"testing"
+
+	"watchdog.onebusaway.org/internal/gtfstest"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	first := gtfstest.WriteBundle(t, dir, "first.zip", minimalFeed)
+	copyOfFirst := gtfstest.WriteBundle(t, dir, "copy.zip", minimalFeed)
+	second := gtfstest.WriteBundle(t, dir, "second.zip", gtfstest.WithFiles(minimalFeed, map[string]string{
+		"routes.txt": "route_id,agency_id,route_short_name,route_type\nR1,A1,1,3\nR2,A1,2,3\n",
+	}))
+
+	cache := NewCache()
+
+	b1, err := cache.Load(1, first)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	b2, err := cache.Load(2, copyOfFirst)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if b1 != b2 {
+		t.Error("Expected servers with identical bundles to share the parsed bundle")
+	}
+	if stats := cache.Stats(); stats.Bundles != 1 || stats.Servers != 2 || stats.Bytes != b1.SizeBytes {
+		t.Errorf("Unexpected stats after loading identical bundles: %+v", stats)
+	}
+
+	b3, err := cache.Load(2, second)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(b3.Static.Routes) != 2 {
+		t.Errorf("Expected the new bundle of server 2 to have 2 routes, got %d", len(b3.Static.Routes))
+	}
+	if got, _ := cache.Get(2); got != b3 {
+		t.Error("Expected Get to return the latest bundle of server 2")
+	}
+	if stats := cache.Stats(); stats.Bundles != 2 {
+		t.Errorf("Expected 2 cached bundles, got %d", stats.Bundles)
+	}
+
+	cache.Remove(1)
+	if _, ok := cache.Get(1); ok {
+		t.Error("Expected server 1 to be removed")
+	}
+	if stats := cache.Stats(); stats.Bundles != 1 || stats.Bytes != b3.SizeBytes {
+		t.Errorf("Expected the unused bundle to be evicted, got %+v", stats)
+	}
+
+	if _, err := cache.Load(3, first); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cache.Retain([]int{3})
+	if ids := cache.ServerIDs(); !reflect.DeepEqual(ids, []int{3}) {
+		t.Errorf("Expected only server 3 to be retained, got %v", ids)
+	}
+	if stats := cache.Stats(); stats.Bundles != 1 {
+		t.Errorf("Expected one bundle after Retain, got %+v", stats)
+	}
+
+	if _, err := cache.Load(4, "invalid/path/to/gtfs.zip"); err == nil {
+		t.Error("Expected an error for a missing bundle")
+	}
+	if _, ok := cache.Get(4); ok {
+		t.Error("Expected failed load not to be cached")
+	}
+}
@@ -0,0 +1,122 @@
+package bundle
+
+import (
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+)
+
+// serviceCalendar holds the dates a service runs on: the weekdays of its calendar.txt
+// range and the exceptions of calendar_dates.txt.
+type serviceCalendar struct {
+	// start and end are the calendar.txt range. hasRange is false for services that are
+	// only defined in calendar_dates.txt.
+	start, end time.Time
+	hasRange   bool
+	weekdays   [7]bool
+	added      []time.Time
+	removed    map[string]bool
+}
+
+// newServiceCalendar returns the calendar of service. calendars holds the calendar.txt
+// ranges of the bundle, which the GTFS library widens to include calendar_dates.txt
+// exceptions; when nil, the service's own dates are used as its range.
+func newServiceCalendar(service gtfs.Service, calendars map[string]DateRange) serviceCalendar {
+	c := serviceCalendar{
+		start:    service.StartDate,
+		end:      service.EndDate,
+		hasRange: true,
+		weekdays: [7]bool{service.Sunday, service.Monday, service.Tuesday, service.Wednesday, service.Thursday, service.Friday, service.Saturday},
+		removed:  make(map[string]bool, len(service.RemovedDates)),
+	}
+	if calendars != nil {
+		calendar, ok := calendars[service.Id]
+		c.start, c.end, c.hasRange = calendar.StartDate, calendar.EndDate, ok
+	}
+	if c.weekdays == [7]bool{} {
+		c.hasRange = false
+	}
+	for _, date := range service.RemovedDates {
+		c.removed[dateKey(date)] = true
+	}
+	for _, date := range service.AddedDates {
+		if !c.removed[dateKey(date)] {
+			c.added = append(c.added, date)
+		}
+	}
+	return c
+}
+
+// regular reports whether date is a weekday of the calendar.txt range that is not removed.
+func (c serviceCalendar) regular(date time.Time) bool {
+	day := dateKey(date)
+	return c.hasRange && !c.removed[day] && day >= dateKey(c.start) && day <= dateKey(c.end) && c.weekdays[date.Weekday()]
+}
+
+// ServiceActiveOn reports whether service runs on the calendar date of date, taking
+// calendar_dates.txt exceptions into account. Dates are compared by year, month and
+// day, so date should be in the timezone of the agency. calendars holds the calendar.txt
+// ranges of the bundle; when nil, the service's own dates are used as its range.
+func ServiceActiveOn(service gtfs.Service, calendars map[string]DateRange, date time.Time) bool {
+	c := newServiceCalendar(service, calendars)
+	day := dateKey(date)
+	for _, added := range c.added {
+		if dateKey(added) == day {
+			return true
+		}
+	}
+	return c.regular(date)
+}
+
+// FirstServiceDate returns the first date on which service is active, taking dates added
+// and removed in calendar_dates.txt into account, or the zero time if it never is.
+// calendars is used like in ServiceActiveOn.
+func FirstServiceDate(service gtfs.Service, calendars map[string]DateRange) time.Time {
+	c := newServiceCalendar(service, calendars)
+
+	var firstDate time.Time
+	for _, date := range c.added {
+		if firstDate.IsZero() || date.Before(firstDate) {
+			firstDate = date
+		}
+	}
+	if !c.hasRange {
+		return firstDate
+	}
+
+	for date := c.start; !date.After(c.end) && (firstDate.IsZero() || date.Before(firstDate)); date = date.AddDate(0, 0, 1) {
+		if c.regular(date) {
+			return date
+		}
+	}
+	return firstDate
+}
+
+// LastServiceDate returns the last date on which service is active, taking dates added
+// and removed in calendar_dates.txt into account, or the zero time if it never is.
+// calendars is used like in ServiceActiveOn.
+func LastServiceDate(service gtfs.Service, calendars map[string]DateRange) time.Time {
+	c := newServiceCalendar(service, calendars)
+
+	var lastDate time.Time
+	for _, date := range c.added {
+		if date.After(lastDate) {
+			lastDate = date
+		}
+	}
+	if !c.hasRange {
+		return lastDate
+	}
+
+	for date := c.end; !date.Before(c.start) && date.After(lastDate); date = date.AddDate(0, 0, -1) {
+		if c.regular(date) {
+			return date
+		}
+	}
+	return lastDate
+}
+
+// dateKey formats the calendar date of t so that keys sort chronologically.
+func dateKey(t time.Time) string {
+	return t.Format("20060102")
+}
@@ -0,0 +1,93 @@
+package bundle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+)
+
+func TestLastServiceDate(t *testing.T) {
+	date := func(day int) time.Time { return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC) }
+	// 2025-01-31 is a Friday.
+	weekday := gtfs.Service{
+		Id:     "WK",
+		Monday: true, Tuesday: true, Wednesday: true, Thursday: true, Friday: true,
+		StartDate: date(1),
+		// calendar_dates.txt entries widen the range seen by the GTFS library.
+		EndDate:      time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC),
+		RemovedDates: []time.Time{date(31), time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	calendars := map[string]DateRange{"WK": {StartDate: date(1), EndDate: date(31)}}
+
+	if got := LastServiceDate(weekday, calendars); !got.Equal(date(30)) {
+		t.Errorf("Expected the last weekday before the removed date, got %v", got)
+	}
+
+	weekday.AddedDates = []time.Time{time.Date(2025, 2, 8, 0, 0, 0, 0, time.UTC)}
+	if got := LastServiceDate(weekday, calendars); !got.Equal(weekday.AddedDates[0]) {
+		t.Errorf("Expected the added date after the calendar range, got %v", got)
+	}
+
+	datesOnly := gtfs.Service{Id: "SPECIAL", StartDate: date(4), EndDate: date(5), AddedDates: []time.Time{date(4)}, RemovedDates: []time.Time{date(5)}}
+	if got := LastServiceDate(datesOnly, calendars); !got.Equal(date(4)) {
+		t.Errorf("Expected the added date of a calendar_dates.txt only service, got %v", got)
+	}
+
+	never := gtfs.Service{Id: "NEVER", StartDate: date(1), EndDate: date(31)}
+	if got := LastServiceDate(never, nil); !got.IsZero() {
+		t.Errorf("Expected no active date for a service without weekdays, got %v", got)
+	}
+}
+
+func TestFirstServiceDate(t *testing.T) {
+	date := func(day int) time.Time { return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC) }
+	// 2025-01-06 is a Monday.
+	weekday := gtfs.Service{
+		Id:     "WK",
+		Monday: true, Tuesday: true, Wednesday: true, Thursday: true, Friday: true,
+		// calendar_dates.txt entries widen the range seen by the GTFS library.
+		StartDate:    time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:      date(31),
+		RemovedDates: []time.Time{date(6), time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	calendars := map[string]DateRange{"WK": {StartDate: date(4), EndDate: date(31)}}
+
+	if got := FirstServiceDate(weekday, calendars); !got.Equal(date(7)) {
+		t.Errorf("Expected the first weekday after the removed date, got %v", got)
+	}
+
+	weekday.AddedDates = []time.Time{date(2)}
+	if got := FirstServiceDate(weekday, calendars); !got.Equal(date(2)) {
+		t.Errorf("Expected the added date before the calendar range, got %v", got)
+	}
+
+	never := gtfs.Service{Id: "NEVER", StartDate: date(1), EndDate: date(31)}
+	if got := FirstServiceDate(never, nil); !got.IsZero() {
+		t.Errorf("Expected no active date for a service without weekdays, got %v", got)
+	}
+}
+
+func TestServiceActiveOn(t *testing.T) {
+	date := func(day int) time.Time { return time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC) }
+	// 2025-01-06 is a Monday.
+	monday := gtfs.Service{
+		Id:           "MO",
+		Monday:       true,
+		StartDate:    date(1),
+		EndDate:      date(31),
+		AddedDates:   []time.Time{date(7)},
+		RemovedDates: []time.Time{date(13)},
+	}
+
+	for day, want := range map[int]bool{6: true, 7: true, 8: false, 13: false, 20: true} {
+		if got := ServiceActiveOn(monday, nil, date(day)); got != want {
+			t.Errorf("ServiceActiveOn(2025-01-%02d) = %v, want %v", day, got, want)
+		}
+	}
+
+	// Services missing from calendar.txt only run on their added dates.
+	if ServiceActiveOn(monday, map[string]DateRange{}, date(6)) || !ServiceActiveOn(monday, map[string]DateRange{}, date(7)) {
+		t.Error("Expected a calendar_dates.txt only service to run on its added dates only")
+	}
+}
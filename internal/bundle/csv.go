@@ -0,0 +1,53 @@
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// EachCSVRow calls fn for every row of a CSV file of a bundle zip, keyed by column
+// name. Column names and values are trimmed and a leading byte order mark is dropped.
+// The row map is reused between calls, so fn must copy what it keeps. An empty file
+// has no rows.
+func EachCSVRow(file *zip.File, fn func(row map[string]string)) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+
+	row := make(map[string]string, len(columns))
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		clear(row)
+		for i, value := range record {
+			if i < len(columns) {
+				row[columns[i]] = strings.TrimSpace(value)
+			}
+		}
+		fn(row)
+	}
+}
@@ -0,0 +1,213 @@
+package bundle
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// tripsPerDayWindow is the number of days, starting at the comparison date, over
+// which the scheduled trips of a bundle are averaged.
+const tripsPerDayWindow = 7
+
+// DefaultDiffHistory is the number of diffs a DiffStore keeps per server.
+const DefaultDiffHistory = 30
+
+// Summary holds the figures of a bundle that are compared between downloads.
+type Summary struct {
+	Hash     string   `json:"hash"`
+	Agencies []string `json:"agencies"`
+	RouteIDs []string `json:"-"`
+	Routes   int      `json:"routes"`
+	Stops    int      `json:"stops"`
+	Trips    int      `json:"trips"`
+	// ServiceStart and ServiceEnd are the first and last date any service is active, see
+	// FirstServiceDate and LastServiceDate.
+	ServiceStart time.Time `json:"service_start"`
+	ServiceEnd   time.Time `json:"service_end"`
+	// TripsPerDay is the mean number of trips scheduled per day over the week
+	// starting at the date the summary was made for.
+	TripsPerDay float64 `json:"trips_per_day"`
+}
+
+// Summarize computes the summary of b, averaging trips per day over the week starting at from.
+func Summarize(b *Bundle, from time.Time) Summary {
+	staticData := b.Static
+	summary := Summary{
+		Hash:  b.Hash,
+		Stops: len(staticData.Stops),
+		Trips: len(staticData.Trips),
+	}
+
+	for _, agency := range staticData.Agencies {
+		summary.Agencies = append(summary.Agencies, agency.Id)
+	}
+	sort.Strings(summary.Agencies)
+
+	for _, route := range staticData.Routes {
+		summary.RouteIDs = append(summary.RouteIDs, route.Id)
+	}
+	sort.Strings(summary.RouteIDs)
+	summary.Routes = len(summary.RouteIDs)
+
+	for _, service := range staticData.Services {
+		first, last := FirstServiceDate(service, b.Calendars), LastServiceDate(service, b.Calendars)
+		if first.IsZero() {
+			continue
+		}
+		if summary.ServiceStart.IsZero() || first.Before(summary.ServiceStart) {
+			summary.ServiceStart = first
+		}
+		if last.After(summary.ServiceEnd) {
+			summary.ServiceEnd = last
+		}
+	}
+
+	tripsPerService := make(map[string]int)
+	for _, trip := range staticData.Trips {
+		if trip.Service != nil {
+			tripsPerService[trip.Service.Id]++
+		}
+	}
+	total := 0
+	for _, service := range staticData.Services {
+		if tripsPerService[service.Id] == 0 {
+			continue
+		}
+		for day := 0; day < tripsPerDayWindow; day++ {
+			if ServiceActiveOn(service, b.Calendars, from.AddDate(0, 0, day)) {
+				total += tripsPerService[service.Id]
+			}
+		}
+	}
+	summary.TripsPerDay = float64(total) / tripsPerDayWindow
+
+	return summary
+}
+
+// Diff describes what changed between two successive bundles of a server.
+type Diff struct {
+	ComparedAt       time.Time `json:"compared_at"`
+	Previous         Summary   `json:"previous"`
+	Current          Summary   `json:"current"`
+	AgenciesAdded    []string  `json:"agencies_added"`
+	AgenciesRemoved  []string  `json:"agencies_removed"`
+	RoutesAdded      []string  `json:"routes_added"`
+	RoutesRemoved    []string  `json:"routes_removed"`
+	StopCountDelta   int       `json:"stop_count_delta"`
+	TripsPerDayDelta float64   `json:"trips_per_day_delta"`
+	// ServiceStartShiftDays and ServiceEndShiftDays are the number of days the service
+	// date range moved; positive values move it later.
+	ServiceStartShiftDays int `json:"service_start_shift_days"`
+	ServiceEndShiftDays   int `json:"service_end_shift_days"`
+}
+
+// Compare diffs the summary of the bundle a server had before with the summary of its
+// new bundle. Trips per day are only comparable when both summaries were made for the
+// same week, see Summarize.
+func Compare(before, after Summary, now time.Time) Diff {
+	diff := Diff{
+		ComparedAt:            now,
+		Previous:              before,
+		Current:               after,
+		StopCountDelta:        after.Stops - before.Stops,
+		TripsPerDayDelta:      after.TripsPerDay - before.TripsPerDay,
+		ServiceStartShiftDays: daysBetween(before.ServiceStart, after.ServiceStart),
+		ServiceEndShiftDays:   daysBetween(before.ServiceEnd, after.ServiceEnd),
+	}
+	diff.AgenciesAdded, diff.AgenciesRemoved = difference(before.Agencies, after.Agencies)
+	diff.RoutesAdded, diff.RoutesRemoved = difference(before.RouteIDs, after.RouteIDs)
+
+	return diff
+}
+
+// difference returns the IDs only in after and the IDs only in before. Both must be sorted.
+func difference(before, after []string) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || (i < len(before) && before[i] < after[j]):
+			removed = append(removed, before[i])
+			i++
+		case i == len(before) || after[j] < before[i]:
+			added = append(added, after[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// daysBetween returns the number of calendar days from a to b, or 0 if either is unset.
+func daysBetween(a, b time.Time) int {
+	if a.IsZero() || b.IsZero() {
+		return 0
+	}
+	dateA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dateB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dateB.Sub(dateA).Hours() / 24)
+}
+
+// DiffStore keeps the most recent bundle diffs of every server. It is safe for concurrent use.
+type DiffStore struct {
+	mu      sync.RWMutex
+	limit   int
+	servers map[int][]Diff
+}
+
+// NewDiffStore creates an empty DiffStore keeping up to limit diffs per server.
+func NewDiffStore(limit int) *DiffStore {
+	return &DiffStore{limit: limit, servers: make(map[int][]Diff)}
+}
+
+// Add records a diff of the server, dropping its oldest diff beyond the limit.
+func (s *DiffStore) Add(serverID int, diff Diff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	diffs := append(s.servers[serverID], diff)
+	if len(diffs) > s.limit {
+		diffs = diffs[len(diffs)-s.limit:]
+	}
+	s.servers[serverID] = diffs
+}
+
+// Restore sets the diffs of the server, for example after a restart. diffs must be
+// newest first, as returned by List.
+func (s *DiffStore) Restore(serverID int, diffs []Diff) {
+	restored := make([]Diff, 0, min(len(diffs), s.limit))
+	for i := min(len(diffs), s.limit) - 1; i >= 0; i-- {
+		restored = append(restored, diffs[i])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.servers[serverID] = restored
+}
+
+// List returns the recorded diffs of the server, newest first.
+func (s *DiffStore) List(serverID int) []Diff {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	diffs := s.servers[serverID]
+	list := make([]Diff, len(diffs))
+	for i, diff := range diffs {
+		list[len(diffs)-1-i] = diff
+	}
+	return list
+}
+
+// Retain removes the diffs of all servers not listed in serverIDs.
+func (s *DiffStore) Retain(serverIDs []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	models.RetainServers(s.servers, serverIDs)
+}
@@ -0,0 +1,129 @@
+package bundle
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/gtfstest"
+)
+
+func TestCompare(t *testing.T) {
+	previous, err := Load(gtfstest.WriteBundle(t, t.TempDir(), "previous.zip", minimalFeed))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	files := gtfstest.WithFiles(minimalFeed, map[string]string{
+		"agency.txt": "agency_id,agency_name,agency_url,agency_timezone\n" +
+			"A2,Agency Two,https://agency.example.com,America/Los_Angeles\n",
+		"routes.txt": "route_id,agency_id,route_short_name,route_type\n" +
+			"R2,A2,2,3\n" +
+			"R3,A2,3,3\n",
+		"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n" +
+			"S1,First,47.6,-122.3\n" +
+			"S2,Second,47.61,-122.31\n" +
+			"S3,Third,47.62,-122.32\n",
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"WK,1,1,1,1,1,0,0,20250101,20260630\n" +
+			"WE,0,0,0,0,0,1,1,20250101,20260630\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n" +
+			"WK,20250304,2\n",
+		"trips.txt": "route_id,service_id,trip_id\n" +
+			"R2,WK,T1\n" +
+			"R2,WK,T2\n" +
+			"R3,WE,T3\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"T1,08:00:00,08:00:00,S1,1\n" +
+			"T1,08:10:00,08:10:00,S2,2\n" +
+			"T2,09:00:00,09:00:00,S1,1\n" +
+			"T2,09:10:00,09:10:00,S3,2\n" +
+			"T3,10:00:00,10:00:00,S2,1\n" +
+			"T3,10:10:00,10:10:00,S3,2\n",
+	})
+	current, err := Load(gtfstest.WriteBundle(t, t.TempDir(), "current.zip", files))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Monday, March 3rd 2025.
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+	diff := Compare(Summarize(previous, now), Summarize(current, now), now)
+
+	if !reflect.DeepEqual(diff.RoutesAdded, []string{"R2", "R3"}) || !reflect.DeepEqual(diff.RoutesRemoved, []string{"R1"}) {
+		t.Errorf("Expected R2 and R3 added and R1 removed, got %v and %v", diff.RoutesAdded, diff.RoutesRemoved)
+	}
+	if !reflect.DeepEqual(diff.AgenciesAdded, []string{"A2"}) || !reflect.DeepEqual(diff.AgenciesRemoved, []string{"A1"}) {
+		t.Errorf("Expected A2 added and A1 removed, got %v and %v", diff.AgenciesAdded, diff.AgenciesRemoved)
+	}
+	if diff.StopCountDelta != 1 {
+		t.Errorf("Expected a stop count delta of 1, got %d", diff.StopCountDelta)
+	}
+	if diff.ServiceStartShiftDays != 0 || diff.ServiceEndShiftDays != 181 {
+		t.Errorf("Expected the service end to move by 181 days, got %d and %d", diff.ServiceStartShiftDays, diff.ServiceEndShiftDays)
+	}
+
+	// Previously one trip on each of 5 weekdays. Now two trips on 4 weekdays, as
+	// Tuesday is removed, and one trip on each weekend day.
+	if diff.Previous.TripsPerDay != 5.0/7 || diff.Current.TripsPerDay != 10.0/7 {
+		t.Errorf("Expected 5/7 and 10/7 trips per day, got %v and %v", diff.Previous.TripsPerDay, diff.Current.TripsPerDay)
+	}
+	if math.Abs(diff.TripsPerDayDelta-5.0/7) > 1e-9 {
+		t.Errorf("Expected a trips per day delta of 5/7, got %v", diff.TripsPerDayDelta)
+	}
+	if diff.Previous.Routes != 1 || diff.Current.Routes != 2 {
+		t.Errorf("Expected 1 and 2 routes, got %d and %d", diff.Previous.Routes, diff.Current.Routes)
+	}
+}
+
+func TestSummarizeServiceDates(t *testing.T) {
+	// A removed date after the calendar.txt range widens the service dates of the GTFS
+	// library, but the service does not run any longer.
+	files := gtfstest.WithFiles(minimalFeed, map[string]string{
+		"calendar_dates.txt": "service_id,date,exception_type\nWK,20260105,2\n",
+	})
+	b, err := Load(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", files))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	summary := Summarize(b, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC))
+	if summary.ServiceEnd.Format(time.DateOnly) != "2025-12-31" {
+		t.Errorf("Expected the service to end on 2025-12-31, got %v", summary.ServiceEnd)
+	}
+	if summary.ServiceStart.Format(time.DateOnly) != "2025-01-01" {
+		t.Errorf("Expected the service to start on 2025-01-01, got %v", summary.ServiceStart)
+	}
+}
+
+func TestDiffStore(t *testing.T) {
+	store := NewDiffStore(2)
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		store.Add(1, Diff{ComparedAt: start.AddDate(0, 0, i)})
+	}
+	store.Add(2, Diff{ComparedAt: start})
+
+	diffs := store.List(1)
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 diffs, got %d", len(diffs))
+	}
+	if !diffs[0].ComparedAt.Equal(start.AddDate(0, 0, 2)) || !diffs[1].ComparedAt.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("Expected the 2 newest diffs, newest first, got %v and %v", diffs[0].ComparedAt, diffs[1].ComparedAt)
+	}
+
+	restored := NewDiffStore(2)
+	restored.Restore(1, diffs)
+	if !reflect.DeepEqual(restored.List(1), diffs) {
+		t.Errorf("Expected the restored diffs to be listed like the original ones, got %+v", restored.List(1))
+	}
+
+	store.Retain([]int{1})
+	if len(store.List(2)) != 0 {
+		t.Error("Expected the diffs of server 2 to be removed")
+	}
+	if len(store.List(1)) != 2 {
+		t.Error("Expected the diffs of server 1 to be kept")
+	}
+}
@@ -0,0 +1,96 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"maps"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+)
+
+// FeedInfo holds the fields of feed_info.txt, which the GTFS library does not parse.
+// Dates are zero when the bundle does not set them.
+type FeedInfo struct {
+	PublisherName string
+	Version       string
+	StartDate     time.Time
+	EndDate       time.Time
+}
+
+// DateRange is the start_date / end_date of a calendar.txt entry. The GTFS library
+// widens these to include calendar_dates.txt exceptions, so they are kept separately.
+type DateRange struct {
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// readCalendarFiles reads feed_info.txt and calendar.txt from the bundle zip. Dates are
+// parsed in the timezone of the first agency, like the GTFS library does. Missing or
+// malformed files are skipped.
+func readCalendarFiles(data []byte, staticData *gtfs.Static) (*FeedInfo, map[string]DateRange) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil
+	}
+
+	timezone := time.UTC
+	if len(staticData.Agencies) > 0 {
+		if location, err := time.LoadLocation(staticData.Agencies[0].Timezone); err == nil {
+			timezone = location
+		}
+	}
+
+	var feedInfo *FeedInfo
+	calendars := make(map[string]DateRange)
+	for _, file := range reader.File {
+		switch file.Name {
+		case "feed_info.txt":
+			rows, err := readCSV(file)
+			if err != nil || len(rows) == 0 {
+				continue
+			}
+			row := rows[0]
+			feedInfo = &FeedInfo{
+				PublisherName: row["feed_publisher_name"],
+				Version:       row["feed_version"],
+				StartDate:     parseDate(row["feed_start_date"], timezone),
+				EndDate:       parseDate(row["feed_end_date"], timezone),
+			}
+		case "calendar.txt":
+			rows, err := readCSV(file)
+			if err != nil {
+				continue
+			}
+			for _, row := range rows {
+				start, end := parseDate(row["start_date"], timezone), parseDate(row["end_date"], timezone)
+				if start.IsZero() || end.IsZero() {
+					continue
+				}
+				calendars[row["service_id"]] = DateRange{StartDate: start, EndDate: end}
+			}
+		}
+	}
+
+	return feedInfo, calendars
+}
+
+// readCSV reads a CSV file of the zip into one map per row, keyed by column name.
+func readCSV(file *zip.File) ([]map[string]string, error) {
+	var rows []map[string]string
+	err := EachCSVRow(file, func(row map[string]string) {
+		rows = append(rows, maps.Clone(row))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func parseDate(value string, timezone *time.Location) time.Time {
+	date, err := time.ParseInLocation("20060102", value, timezone)
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
@@ -0,0 +1,22 @@
+package bundle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"watchdog.onebusaway.org/internal/gtfstest"
+)
+
+// minimalFeed is the content of a small but complete static GTFS bundle.
+var minimalFeed = gtfstest.MinimalFeed()
+
+func getFixturePath(t *testing.T, fixturePath string) string {
+	t.Helper()
+
+	absPath, err := filepath.Abs(filepath.Join("..", "..", "testdata", fixturePath))
+	if err != nil {
+		t.Fatalf("Failed to get absolute path to testdata/%s: %v", fixturePath, err)
+	}
+
+	return absPath
+}
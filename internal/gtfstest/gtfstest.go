@@ -0,0 +1,86 @@
+// Package gtfstest builds static GTFS bundles for tests.
+package gtfstest
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// minimalFeed is the content of a small but complete static GTFS bundle.
+var minimalFeed = map[string]string{
+	"agency.txt": "agency_id,agency_name,agency_url,agency_timezone\n" +
+		"A1,Agency One,https://agency.example.com,America/Los_Angeles\n",
+	"routes.txt": "route_id,agency_id,route_short_name,route_type\n" +
+		"R1,A1,1,3\n",
+	"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n" +
+		"S1,First,47.6,-122.3\n" +
+		"S2,Second,47.61,-122.31\n",
+	"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+		"WK,1,1,1,1,1,0,0,20250101,20251231\n",
+	"trips.txt": "route_id,service_id,trip_id\n" +
+		"R1,WK,T1\n",
+	"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+		"T1,08:00:00,08:00:00,S1,1\n" +
+		"T1,08:10:00,08:10:00,S2,2\n",
+}
+
+// MinimalFeed returns the files of a small but complete static GTFS bundle: one agency
+// with one route whose single weekday trip serves two stops throughout 2025.
+func MinimalFeed() map[string]string {
+	return WithFiles(minimalFeed, nil)
+}
+
+// WriteBundle writes files, keyed by file name, into a zip archive called name inside
+// dir and returns its path.
+func WriteBundle(t *testing.T, dir, name string, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create bundle %s: %v", path, err)
+	}
+	defer out.Close()
+
+	// Write files in a fixed order so that identical content yields identical archives.
+	names := make([]string, 0, len(files))
+	for fileName := range files {
+		names = append(names, fileName)
+	}
+	sort.Strings(names)
+
+	writer := zip.NewWriter(out)
+	for _, fileName := range names {
+		w, err := writer.Create(fileName)
+		if err != nil {
+			t.Fatalf("Failed to add %s to bundle: %v", fileName, err)
+		}
+		if _, err := w.Write([]byte(files[fileName])); err != nil {
+			t.Fatalf("Failed to write %s to bundle: %v", fileName, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to finish bundle %s: %v", path, err)
+	}
+
+	return path
+}
+
+// WithFiles returns a copy of files with the given files replaced. An empty content removes the file.
+func WithFiles(files map[string]string, replacements map[string]string) map[string]string {
+	result := make(map[string]string, len(files)+len(replacements))
+	for name, content := range files {
+		result[name] = content
+	}
+	for name, content := range replacements {
+		if content == "" {
+			delete(result, name)
+		} else {
+			result[name] = content
+		}
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+// Package history persists the results of check executions in an embedded bbolt database.
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultRetention is how long records are kept when no retention is configured.
+const DefaultRetention = 35 * 24 * time.Hour
+
+var (
+	resultsBucket = []byte("results")
+	stateBucket   = []byte("state")
+)
+
+// Record is a single check execution.
+type Record struct {
+	ServerID int           `json:"server_id"`
+	Check    string        `json:"check"`
+	At       time.Time     `json:"at"`
+	Duration time.Duration `json:"duration"`
+	OK       bool          `json:"ok"`
+	Value    *float64      `json:"value,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Query selects records of a server. From is inclusive and To exclusive; a zero
+// To means no upper bound. An empty Check selects every check. Limit, if positive,
+// keeps only the most recent records.
+type Query struct {
+	ServerID int
+	Check    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// Store is a persistent history of check executions. Records of each check of each
+// server live in their own bucket, keyed by time, so that range queries and pruning
+// are cursor scans and a query for one check never decodes the records of the others.
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration
+}
+
+// Open opens or creates the history database at path. Records older than retention
+// are removed by Prune; zero means DefaultRetention.
+func Open(path string, retention time.Duration) (*Store, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{resultsBucket, stateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+
+	return &Store{db: db, retention: retention}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Retention returns how long records are kept.
+func (s *Store) Retention() time.Duration {
+	return s.retention
+}
+
+// Add stores a record. Concurrent calls are batched into a single transaction.
+func (s *Store) Add(record Record) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		server, err := tx.Bucket(resultsBucket).CreateBucketIfNotExists(serverKey(record.ServerID))
+		if err != nil {
+			return err
+		}
+		bucket, err := server.CreateBucketIfNotExists([]byte(record.Check))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(timeKey(record.At), value)
+	})
+}
+
+// Records returns the records matching q, oldest first.
+func (s *Store) Records(q Query) ([]Record, error) {
+	var records []Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		server := tx.Bucket(resultsBucket).Bucket(serverKey(q.ServerID))
+		if server == nil {
+			return nil
+		}
+
+		if q.Check != "" {
+			return readRecords(server.Bucket([]byte(q.Check)), q, &records)
+		}
+		return server.ForEachBucket(func(name []byte) error {
+			return readRecords(server.Bucket(name), q, &records)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Records of several checks are read one check after the other.
+	if q.Check == "" {
+		sort.SliceStable(records, func(i, j int) bool { return records[i].At.Before(records[j].At) })
+	}
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+	return records, nil
+}
+
+// readRecords appends the records of the check bucket within the time range of q.
+// A nil bucket has no records.
+func readRecords(bucket *bolt.Bucket, q Query, records *[]Record) error {
+	if bucket == nil {
+		return nil
+	}
+
+	c := bucket.Cursor()
+	for k, v := c.Seek(timeKey(q.From)); k != nil; k, v = c.Next() {
+		if !q.To.IsZero() && bytes.Compare(k, timeKey(q.To)) >= 0 {
+			break
+		}
+
+		var record Record
+		if err := json.Unmarshal(v, &record); err != nil {
+			return fmt.Errorf("corrupt history record %x: %w", k, err)
+		}
+		*records = append(*records, record)
+	}
+	return nil
+}
+
+// PutState stores value, encoded as JSON, under name for the server, replacing the
+// value stored before. State is kept for components that compare a server with its
+// past, such as the previous GTFS bundle, and is not subject to the retention.
+func (s *Store) PutState(serverID int, name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(stateBucket).CreateBucketIfNotExists(serverKey(serverID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), data)
+	})
+}
+
+// State decodes the value stored under name for the server into value. It reports
+// false when nothing is stored.
+func (s *Store) State(serverID int, name string, value any) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(stateBucket).Bucket(serverKey(serverID)); bucket != nil {
+			data = bytes.Clone(bucket.Get([]byte(name)))
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, fmt.Errorf("corrupt %s state of server %d: %w", name, serverID, err)
+	}
+	return true, nil
+}
+
+// Prune removes records older than the retention and returns how many were removed.
+func (s *Store) Prune(now time.Time) (int, error) {
+	cutoff := timeKey(now.Add(-s.retention))
+	removed := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(resultsBucket).ForEachBucket(func(serverName []byte) error {
+			server := tx.Bucket(resultsBucket).Bucket(serverName)
+			return server.ForEachBucket(func(checkName []byte) error {
+				bucket := server.Bucket(checkName)
+
+				var expired [][]byte
+				c := bucket.Cursor()
+				for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+
+				for _, k := range expired {
+					if err := bucket.Delete(k); err != nil {
+						return err
+					}
+				}
+				removed += len(expired)
+				return nil
+			})
+		})
+	})
+
+	return removed, err
+}
+
+func serverKey(serverID int) []byte {
+	return []byte(strconv.Itoa(serverID))
+}
+
+// timeKey encodes t so that keys sort chronologically. Times before the epoch are clamped.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if nanos := t.UnixNano(); nanos > 0 && !t.IsZero() {
+		binary.BigEndian.PutUint64(key, uint64(nanos))
+	}
+	return key
+}
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T, retention time.Duration) *Store {
+	t.Helper()
+
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"), retention)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreAddAndRecords(t *testing.T) {
+	store := openTestStore(t, 0)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := 12.0
+
+	records := []Record{
+		{ServerID: 1, Check: "ping", At: start, Duration: time.Second, OK: true},
+		{ServerID: 1, Check: "bundle_expiration", At: start, OK: true, Value: &days},
+		{ServerID: 1, Check: "ping", At: start.Add(time.Hour), OK: false, Error: "timeout"},
+		{ServerID: 2, Check: "ping", At: start, OK: true},
+	}
+	for _, record := range records {
+		if err := store.Add(record); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	got, err := store.Records(Query{ServerID: 1})
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 records of server 1, got %d", len(got))
+	}
+	if got[2].Error != "timeout" || !got[2].At.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected records in chronological order, got %+v", got)
+	}
+
+	got, _ = store.Records(Query{ServerID: 1, Check: "bundle_expiration"})
+	if len(got) != 1 || got[0].Value == nil || *got[0].Value != days {
+		t.Errorf("Expected the bundle expiration record with its value, got %+v", got)
+	}
+
+	got, _ = store.Records(Query{ServerID: 1, Check: "ping", From: start.Add(time.Minute)})
+	if len(got) != 1 || got[0].OK {
+		t.Errorf("Expected only the failing ping, got %+v", got)
+	}
+
+	got, _ = store.Records(Query{ServerID: 1, To: start.Add(time.Minute)})
+	if len(got) != 2 {
+		t.Errorf("Expected 2 records before the upper bound, got %+v", got)
+	}
+
+	got, _ = store.Records(Query{ServerID: 1, Limit: 1})
+	if len(got) != 1 || !got[0].At.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the most recent record, got %+v", got)
+	}
+
+	got, _ = store.Records(Query{ServerID: 3})
+	if len(got) != 0 {
+		t.Errorf("Expected no records of an unknown server, got %+v", got)
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	store := openTestStore(t, 24*time.Hour)
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, age := range []time.Duration{72 * time.Hour, 25 * time.Hour, time.Hour} {
+		for _, serverID := range []int{1, 2} {
+			if err := store.Add(Record{ServerID: serverID, Check: "ping", At: now.Add(-age), OK: true}); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+		}
+	}
+
+	removed, err := store.Prune(now)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed != 4 {
+		t.Errorf("Expected 4 records to be removed, got %d", removed)
+	}
+
+	got, _ := store.Records(Query{ServerID: 1})
+	if len(got) != 1 {
+		t.Errorf("Expected 1 record to remain, got %d", len(got))
+	}
+}
+
+func TestStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := store.Add(Record{ServerID: 1, Check: "ping", At: time.Now(), OK: true}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(path, 0)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	got, _ := store.Records(Query{ServerID: 1})
+	if len(got) != 1 {
+		t.Errorf("Expected the record to survive a restart, got %d", len(got))
+	}
+}
+
+func TestStoreRecordsReadsOnlyTheQueriedCheck(t *testing.T) {
+	store := openTestStore(t, 0)
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	if err := store.Add(Record{ServerID: 1, Check: "ping", At: now, OK: true}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Add(Record{ServerID: 1, Check: "arrivals", At: now, OK: true}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// A record of another check that cannot be decoded must not affect a ping query.
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(resultsBucket).Bucket(serverKey(1)).Bucket([]byte("arrivals")).Put(timeKey(now.Add(time.Second)), []byte("{"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to write corrupt record: %v", err)
+	}
+
+	got, err := store.Records(Query{ServerID: 1, Check: "ping"})
+	if err != nil || len(got) != 1 || got[0].Check != "ping" {
+		t.Errorf("Expected only the ping record, got %+v, %v", got, err)
+	}
+	if _, err := store.Records(Query{ServerID: 1}); err == nil {
+		t.Error("Expected a query of every check to report the corrupt record")
+	}
+}
+
+func TestStoreState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	type counts struct {
+		Trips int `json:"trips"`
+	}
+	var got counts
+	if ok, err := store.State(1, "counts", &got); ok || err != nil {
+		t.Fatalf("Expected no state before it is stored, got %v, %v", ok, err)
+	}
+
+	if err := store.PutState(1, "counts", counts{Trips: 3}); err != nil {
+		t.Fatalf("PutState failed: %v", err)
+	}
+	if err := store.PutState(1, "counts", counts{Trips: 4}); err != nil {
+		t.Fatalf("PutState failed: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(path, 0)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	if ok, err := store.State(1, "counts", &got); !ok || err != nil || got.Trips != 4 {
+		t.Errorf("Expected the latest state to survive a restart, got %+v, %v, %v", got, ok, err)
+	}
+	if ok, _ := store.State(2, "counts", &got); ok {
+		t.Error("Expected no state for another server")
+	}
+}
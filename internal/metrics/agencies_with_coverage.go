@@ -2,44 +2,20 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"sort"
 	"strconv"
+	"strings"
 
-	onebusaway "github.com/OneBusAway/go-sdk"
-	"github.com/OneBusAway/go-sdk/option"
 	"github.com/getsentry/sentry-go"
 	"github.com/jamespfennell/gtfs"
+	"github.com/prometheus/client_golang/prometheus"
 	"watchdog.onebusaway.org/internal/models"
 )
 
-func CheckAgenciesWithCoverage(cachePath string, logger *slog.Logger, server models.ObaServer) (int, error) {
-	file, err := os.Open(cachePath)
-	if err != nil {
-		sentry.CaptureException(err)
-		return 0, err
-	}
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		sentry.CaptureException(err)
-		return 0, err
-	}
-
-	fileBytes := make([]byte, fileInfo.Size())
-	_, err = file.Read(fileBytes)
-	if err != nil {
-		return 0, err
-	}
-
-	staticData, err := gtfs.ParseStatic(fileBytes, gtfs.ParseStaticOptions{})
-	if err != nil {
-		sentry.CaptureException(err)
-		return 0, err
-	}
-
+func CheckAgenciesWithCoverage(staticData *gtfs.Static, logger *slog.Logger, server models.ObaServer) (int, error) {
 	if len(staticData.Agencies) == 0 {
 		return 0, fmt.Errorf("no agencies found in GTFS bundle")
 	}
@@ -51,46 +27,170 @@ func CheckAgenciesWithCoverage(cachePath string, logger *slog.Logger, server mod
 	return len(staticData.Agencies), nil
 }
 
-func GetAgenciesWithCoverage(server models.ObaServer) (int, error) {
-	client := onebusaway.NewClient(
-		option.WithAPIKey(server.ObaApiKey),
-		option.WithBaseURL(server.ObaBaseURL),
-	)
+func GetAgenciesWithCoverage(ctx context.Context, server models.ObaServer) (int, error) {
+	agencyIDs, err := getCoverageAgencyIDs(ctx, server)
+	return len(agencyIDs), err
+}
 
-	ctx := context.Background()
+// getCoverageAgencyIDs returns the IDs listed by the agencies-with-coverage endpoint.
+func getCoverageAgencyIDs(ctx context.Context, server models.ObaServer) ([]string, error) {
+	client := newObaClient(server)
 
 	response, err := client.AgenciesWithCoverage.List(ctx)
 
 	if err != nil {
+		recordObaDecodeError(server, "agencies-with-coverage", err)
 		sentry.CaptureException(err)
-		return 0, err
+		return nil, err
 	}
 
 	if response == nil {
-		return 0, nil
+		return nil, nil
+	}
+
+	agencyIDs := make([]string, 0, len(response.Data.List))
+	for _, agency := range response.Data.List {
+		agencyIDs = append(agencyIDs, agency.AgencyID)
 	}
 
 	AgenciesInCoverageEndpoint.WithLabelValues(
 		strconv.Itoa(server.ID),
-	).Set(float64(len(response.Data.List)))
+	).Set(float64(len(agencyIDs)))
 
-	return len(response.Data.List), nil
+	return agencyIDs, nil
 }
 
-func CheckAgenciesWithCoverageMatch(cachePath string, logger *slog.Logger, server models.ObaServer) error {
-	staticGtfsAgenciesCount, err := CheckAgenciesWithCoverage(cachePath, logger, server)
-	if err != nil {
+// CheckAgenciesWithCoverageMatch compares the agency IDs of the static bundle with the
+// agencies-with-coverage endpoint. It exports which agencies are missing from either
+// side and returns an error listing them when the sets differ.
+func CheckAgenciesWithCoverageMatch(ctx context.Context, staticData *gtfs.Static, logger *slog.Logger, server models.ObaServer) error {
+	if _, err := CheckAgenciesWithCoverage(staticData, logger, server); err != nil {
 		return err
 	}
 
-	coverageAgenciesCount, err := GetAgenciesWithCoverage(server)
+	coverageAgencyIDs, err := getCoverageAgencyIDs(ctx, server)
+	if err != nil {
+		AgenciesMatch.WithLabelValues(strconv.Itoa(server.ID)).Set(0)
+		return fmt.Errorf("failed to fetch agencies with coverage: %v", err)
+	}
+
+	staticAgencyIDs := make([]string, 0, len(staticData.Agencies))
+	for _, agency := range staticData.Agencies {
+		staticAgencyIDs = append(staticAgencyIDs, agency.Id)
+	}
+
+	comparison := CompareAgencyIDs(staticAgencyIDs, coverageAgencyIDs)
+	recordAgencyComparison(server, comparison)
+	recordAgenciesInCoverage(server, coverageAgencyIDs)
+
+	if comparison.Match() {
+		return nil
+	}
+	return comparison.err()
+}
+
+// AgencyComparison is the difference between the agency IDs of the static bundle
+// and those listed by the agencies-with-coverage endpoint.
+type AgencyComparison struct {
+	// Common lists the agencies present on both sides.
+	Common []string
+	// MissingFromCoverage lists static agencies the endpoint does not list.
+	MissingFromCoverage []string
+	// MissingFromStatic lists agencies of the endpoint absent from the static bundle.
+	MissingFromStatic []string
+}
+
+// Match reports whether both sides list the same agencies.
+func (c AgencyComparison) Match() bool {
+	return len(c.MissingFromCoverage) == 0 && len(c.MissingFromStatic) == 0
+}
+
+func (c AgencyComparison) err() error {
+	var parts []string
+	if len(c.MissingFromCoverage) > 0 {
+		parts = append(parts, fmt.Sprintf("agencies missing from agencies-with-coverage: %s", strings.Join(c.MissingFromCoverage, ", ")))
+	}
+	if len(c.MissingFromStatic) > 0 {
+		parts = append(parts, fmt.Sprintf("agencies missing from static GTFS: %s", strings.Join(c.MissingFromStatic, ", ")))
+	}
+	return errors.New(strings.Join(parts, "; "))
+}
+
+// CompareAgencyIDs compares the agency IDs of the static bundle with those of the
+// agencies-with-coverage endpoint. Duplicates are ignored and the results are sorted.
+func CompareAgencyIDs(staticAgencyIDs, coverageAgencyIDs []string) AgencyComparison {
+	inStatic := make(map[string]bool, len(staticAgencyIDs))
+	for _, id := range staticAgencyIDs {
+		inStatic[id] = true
+	}
+	inCoverage := make(map[string]bool, len(coverageAgencyIDs))
+	for _, id := range coverageAgencyIDs {
+		inCoverage[id] = true
+	}
+
+	var comparison AgencyComparison
+	for id := range inStatic {
+		if inCoverage[id] {
+			comparison.Common = append(comparison.Common, id)
+		} else {
+			comparison.MissingFromCoverage = append(comparison.MissingFromCoverage, id)
+		}
+	}
+	for id := range inCoverage {
+		if !inStatic[id] {
+			comparison.MissingFromStatic = append(comparison.MissingFromStatic, id)
+		}
+	}
+
+	sort.Strings(comparison.Common)
+	sort.Strings(comparison.MissingFromCoverage)
+	sort.Strings(comparison.MissingFromStatic)
+	return comparison
+}
+
+// recordAgencyComparison exports the comparison of the server. Agencies that dropped
+// out of both sides since the previous run are removed.
+func recordAgencyComparison(server models.ObaServer, comparison AgencyComparison) {
+	serverID := strconv.Itoa(server.ID)
 
 	matchValue := 0
-	if coverageAgenciesCount == staticGtfsAgenciesCount {
+	if comparison.Match() {
 		matchValue = 1
 	}
+	AgenciesMatch.WithLabelValues(serverID).Set(float64(matchValue))
 
-	AgenciesMatch.WithLabelValues(strconv.Itoa(server.ID)).Set(float64(matchValue))
+	AgencyMissingFromCoverage.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+	AgencyMissingFromStaticGtfs.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
 
-	return nil
+	for _, id := range comparison.Common {
+		AgencyMissingFromCoverage.WithLabelValues(id, serverID).Set(0)
+		AgencyMissingFromStaticGtfs.WithLabelValues(id, serverID).Set(0)
+	}
+	for _, id := range comparison.MissingFromCoverage {
+		AgencyMissingFromCoverage.WithLabelValues(id, serverID).Set(1)
+	}
+	for _, id := range comparison.MissingFromStatic {
+		AgencyMissingFromStaticGtfs.WithLabelValues(id, serverID).Set(1)
+	}
+}
+
+// recordAgenciesInCoverage exports whether each configured agency of the server is
+// listed by the agencies-with-coverage endpoint.
+func recordAgenciesInCoverage(server models.ObaServer, coverageAgencyIDs []string) {
+	listed := make(map[string]bool, len(coverageAgencyIDs))
+	for _, id := range coverageAgencyIDs {
+		listed[id] = true
+	}
+
+	serverID := strconv.Itoa(server.ID)
+	for _, agency := range server.AgencyList() {
+		if agency.ID == "" {
+			continue
+		}
+		value := 0
+		if listed[agency.ID] {
+			value = 1
+		}
+		AgencyInCoverage.WithLabelValues(agency.ID, serverID).Set(float64(value))
+	}
 }
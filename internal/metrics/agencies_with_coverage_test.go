@@ -1,11 +1,15 @@
 package metrics
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/jamespfennell/gtfs"
 	"watchdog.onebusaway.org/internal/models"
 )
 
@@ -13,15 +17,15 @@ func TestCheckAgenciesWithCoverage(t *testing.T) {
 	// Test case: Successful execution
 
 	t.Run("Success", func(t *testing.T) {
-		fixturePath := getFixturePath(t, "gtfs.zip")
+		staticData := loadStaticFixture(t, "gtfs.zip")
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-		ts := setupObaServer(t, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[{"agencyId":"1"}]}}`, http.StatusOK)
+		ts := setupObaServer(t, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[{"agencyId":"40"}]}}`, http.StatusOK)
 		defer ts.Close()
 
 		testServer := createTestServer(ts.URL, "Test Server", 999, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		err := CheckAgenciesWithCoverageMatch(fixturePath, logger, testServer)
+		err := CheckAgenciesWithCoverageMatch(context.Background(), staticData, logger, testServer)
 		if err != nil {
 			t.Fatalf("CheckAgenciesWithCoverageMatch failed: %v", err)
 		}
@@ -36,38 +40,72 @@ func TestCheckAgenciesWithCoverage(t *testing.T) {
 		}
 	})
 
-	// Test case: Error opening file
-	t.Run("ErrorOpeningFile", func(t *testing.T) {
+	t.Run("ConfiguredAgencies", func(t *testing.T) {
+		staticData := loadStaticFixture(t, "gtfs.zip")
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-		testServer := createTestServer("http://example.com", "Test Server", 999, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		err := CheckAgenciesWithCoverageMatch("invalid/path/to/gtfs.zip", logger, testServer)
-		if err == nil {
-			t.Fatal("Expected an error but got nil")
+		ts := setupObaServer(t, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[{"agencyId":"40"}]}}`, http.StatusOK)
+		defer ts.Close()
+
+		testServer := models.ObaServer{
+			ID:         1302,
+			ObaBaseURL: ts.URL,
+			ObaApiKey:  "test-key",
+			Agencies:   []models.Agency{{ID: "40"}, {ID: "3"}},
+		}
+
+		if err := CheckAgenciesWithCoverageMatch(context.Background(), staticData, logger, testServer); err != nil {
+			t.Fatalf("CheckAgenciesWithCoverageMatch failed: %v", err)
+		}
+
+		for agencyID, want := range map[string]float64{"40": 1, "3": 0} {
+			inCoverage, err := getMetricValue(AgencyInCoverage, map[string]string{"agency_id": agencyID, "server_id": "1302"})
+			if err != nil {
+				t.Fatalf("Failed to get AgencyInCoverage for agency %s: %v", agencyID, err)
+			}
+			if inCoverage != want {
+				t.Errorf("Expected agency %s in coverage to be %v, got %v", agencyID, want, inCoverage)
+			}
 		}
-		t.Log("Received expected error:", err)
 	})
 
-	// Test case: Error reading file
-	t.Run("ErrorReadingFile", func(t *testing.T) {
-		fixturePath := getFixturePath(t, "empty.zip")
+	t.Run("SameCountDifferentAgencies", func(t *testing.T) {
+		staticData := loadStaticFixture(t, "gtfs.zip")
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-		testServer := createTestServer("http://example.com", "Test Server", 999, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		err := CheckAgenciesWithCoverageMatch(fixturePath, logger, testServer)
+		ts := setupObaServer(t, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[{"agencyId":"1"}]}}`, http.StatusOK)
+		defer ts.Close()
+
+		testServer := createTestServer(ts.URL, "Test Server", 1401, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
+
+		err := CheckAgenciesWithCoverageMatch(context.Background(), staticData, logger, testServer)
 		if err == nil {
-			t.Fatal("Expected an error but got nil")
+			t.Fatal("Expected an error for mismatched agency IDs")
+		}
+		if !strings.Contains(err.Error(), "missing from agencies-with-coverage: 40") || !strings.Contains(err.Error(), "missing from static GTFS: 1") {
+			t.Errorf("Expected the error to name the missing agencies, got %q", err)
+		}
+
+		match, _ := getMetricValue(AgenciesMatch, map[string]string{"server_id": "1401"})
+		if match != 0 {
+			t.Errorf("Expected agency match metric to be 0, got %v", match)
+		}
+		missing, _ := getMetricValue(AgencyMissingFromCoverage, map[string]string{"agency_id": "40", "server_id": "1401"})
+		if missing != 1 {
+			t.Errorf("Expected agency 40 to be missing from coverage, got %v", missing)
+		}
+		missing, _ = getMetricValue(AgencyMissingFromStaticGtfs, map[string]string{"agency_id": "1", "server_id": "1401"})
+		if missing != 1 {
+			t.Errorf("Expected agency 1 to be missing from the static GTFS, got %v", missing)
 		}
-		t.Log("Received expected error:", err)
 	})
 
-	// Test case: Error parsing GTFS data
-	t.Run("ErrorParsingGTFSData", func(t *testing.T) {
-		fixturePath := getFixturePath(t, "invalid_gtfs.zip")
+	// Test case: Bundle without agencies
+	t.Run("NoAgencies", func(t *testing.T) {
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 		testServer := createTestServer("http://example.com", "Test Server", 999, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		err := CheckAgenciesWithCoverageMatch(fixturePath, logger, testServer)
+		err := CheckAgenciesWithCoverageMatch(context.Background(), &gtfs.Static{}, logger, testServer)
 		if err == nil {
 			t.Fatal("Expected an error but got nil")
 		}
@@ -75,6 +113,27 @@ func TestCheckAgenciesWithCoverage(t *testing.T) {
 	})
 }
 
+func TestCompareAgencyIDs(t *testing.T) {
+	comparison := CompareAgencyIDs([]string{"1", "3", "40", "1"}, []string{"40", "2", "1"})
+
+	if comparison.Match() {
+		t.Error("Expected the agency sets not to match")
+	}
+	if !reflect.DeepEqual(comparison.Common, []string{"1", "40"}) {
+		t.Errorf("Expected common agencies [1 40], got %v", comparison.Common)
+	}
+	if !reflect.DeepEqual(comparison.MissingFromCoverage, []string{"3"}) {
+		t.Errorf("Expected [3] missing from coverage, got %v", comparison.MissingFromCoverage)
+	}
+	if !reflect.DeepEqual(comparison.MissingFromStatic, []string{"2"}) {
+		t.Errorf("Expected [2] missing from static GTFS, got %v", comparison.MissingFromStatic)
+	}
+
+	if !CompareAgencyIDs([]string{"1", "2"}, []string{"2", "1"}).Match() {
+		t.Error("Expected identical agency sets in a different order to match")
+	}
+}
+
 // OBASdk tests
 func TestGetAgenciesWithCoverage(t *testing.T) {
 	t.Run("NilResponse", func(t *testing.T) {
@@ -88,7 +147,7 @@ func TestGetAgenciesWithCoverage(t *testing.T) {
 			ObaApiKey:  "test-key",
 		}
 
-		count, err := GetAgenciesWithCoverage(server)
+		count, err := GetAgenciesWithCoverage(context.Background(), server)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
@@ -109,7 +168,7 @@ func TestGetAgenciesWithCoverage(t *testing.T) {
 			ObaApiKey:  "test-key",
 		}
 
-		count, err := GetAgenciesWithCoverage(server)
+		count, err := GetAgenciesWithCoverage(context.Background(), server)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
@@ -130,7 +189,7 @@ func TestGetAgenciesWithCoverage(t *testing.T) {
 			ObaApiKey:  "test-key",
 		}
 
-		_, err := GetAgenciesWithCoverage(server)
+		_, err := GetAgenciesWithCoverage(context.Background(), server)
 		if err == nil {
 			t.Fatal("Expected an error but got nil")
 		}
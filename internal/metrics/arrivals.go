@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	onebusaway "github.com/OneBusAway/go-sdk"
+	"github.com/getsentry/sentry-go"
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// ArrivalsSummary holds the figures of one arrivals-and-departures probe.
+type ArrivalsSummary struct {
+	// Stops counts the stops that answered; FailedStops those whose request failed.
+	Stops       int
+	FailedStops int
+	EmptyStops  int
+	Arrivals    int
+	Predicted   int
+	// TotalLatency is the summed latency of the requests that succeeded.
+	TotalLatency time.Duration
+}
+
+// RealtimeRatio is the share of arrivals with a realtime prediction, or -1 without arrivals.
+func (s ArrivalsSummary) RealtimeRatio() float64 {
+	if s.Arrivals == 0 {
+		return -1
+	}
+	return float64(s.Predicted) / float64(s.Arrivals)
+}
+
+// CheckArrivals calls arrivals-and-departures-for-stop for the configured stops of the
+// server, or for a random sample of stops of the static bundle, and exports the share
+// of arrivals with realtime predictions, the share of empty responses and the latency.
+// It returns an error when no stop answered or when none of the arrivals is predicted.
+// b is only used for sampling and may be nil when the server configures stops.
+func CheckArrivals(ctx context.Context, b *bundle.Bundle, server models.ObaServer) (ArrivalsSummary, error) {
+	stopIDs := server.ArrivalsProbe.StopIDs
+	if len(stopIDs) == 0 {
+		stopIDs = sampleStopIDs(b, probeAgencyID(b.Static, server), server.ArrivalsProbe.SampleSizeOrDefault())
+	}
+	if len(stopIDs) == 0 {
+		return ArrivalsSummary{}, fmt.Errorf("no stops to probe for server %d", server.ID)
+	}
+
+	client := newObaClient(server)
+
+	var summary ArrivalsSummary
+	var errs []error
+	for _, stopID := range stopIDs {
+		start := time.Now()
+		response, err := client.ArrivalAndDeparture.List(ctx, stopID, onebusaway.ArrivalAndDepartureListParams{})
+		if err != nil {
+			recordObaDecodeError(server, "arrivals-and-departures-for-stop", err)
+			summary.FailedStops++
+			errs = append(errs, fmt.Errorf("stop %s: %v", stopID, err))
+			continue
+		}
+		summary.TotalLatency += time.Since(start)
+		summary.Stops++
+
+		var arrivals []onebusaway.ArrivalAndDepartureListResponseDataEntryArrivalsAndDeparture
+		if response != nil {
+			arrivals = response.Data.Entry.ArrivalsAndDepartures
+		}
+		if len(arrivals) == 0 {
+			summary.EmptyStops++
+			continue
+		}
+		for _, arrival := range arrivals {
+			summary.Arrivals++
+			if arrival.Predicted {
+				summary.Predicted++
+			}
+		}
+	}
+
+	if summary.Stops == 0 {
+		err := fmt.Errorf("arrivals-and-departures-for-stop failed for every probed stop: %w", errors.Join(errs...))
+		sentry.CaptureException(err)
+		return summary, err
+	}
+
+	serverID := strconv.Itoa(server.ID)
+	if ratio := summary.RealtimeRatio(); ratio >= 0 {
+		ArrivalsRealtimeRatio.WithLabelValues(serverID).Set(ratio)
+	} else {
+		ArrivalsRealtimeRatio.DeleteLabelValues(serverID)
+	}
+	ArrivalsEmptyResponseRatio.WithLabelValues(serverID).Set(float64(summary.EmptyStops) / float64(summary.Stops))
+	ArrivalsProbeLatency.WithLabelValues(serverID).Set(summary.TotalLatency.Seconds() / float64(summary.Stops))
+
+	if summary.Arrivals > 0 && summary.Predicted == 0 {
+		return summary, fmt.Errorf("none of the %d arrivals at %d probed stops has a realtime prediction", summary.Arrivals, summary.Stops)
+	}
+	return summary, nil
+}
+
+// probeAgencyID returns the agency prefix OBA uses for stops whose agency is not known
+// from the bundle: the first configured agency, or the first agency of the bundle.
+func probeAgencyID(staticData *gtfs.Static, server models.ObaServer) string {
+	if agencies := server.AgencyList(); agencies[0].ID != "" {
+		return agencies[0].ID
+	}
+	if len(staticData.Agencies) > 0 {
+		return staticData.Agencies[0].Id
+	}
+	return ""
+}
+
+// sampleStopIDs picks up to n random boarding locations of the bundle and returns
+// their OBA stop IDs, prefixed with the agency serving the stop or with agencyID.
+func sampleStopIDs(b *bundle.Bundle, agencyID string, n int) []string {
+	var candidates []string
+	for _, stop := range b.Static.Stops {
+		if stop.Type == gtfs.StopType_Stop || stop.Type == gtfs.StopType_Platform {
+			candidates = append(candidates, stop.Id)
+		}
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	stopIDs := make([]string, len(candidates))
+	for i, id := range candidates {
+		prefix, ok := b.StopAgencies[id]
+		if !ok {
+			prefix = agencyID
+		}
+		stopIDs[i] = prefix + "_" + id
+	}
+	return stopIDs
+}
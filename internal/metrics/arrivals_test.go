@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// arrivalsHandler serves arrivals-and-departures-for-stop. stop returns the number of
+// arrivals and predicted arrivals of a stop, or false to fail the request.
+func arrivalsHandler(stop func(stopID string) (arrivals, predicted int, ok bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stopID := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".json")
+		arrivals, predicted, ok := stop(stopID)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		entries := make([]string, arrivals)
+		for i := range entries {
+			entries[i] = fmt.Sprintf(`{"tripId":"1_trip%d","predicted":%v}`, i, i < predicted)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"entry":{"arrivalsAndDepartures":[%s]}}}`, strings.Join(entries, ","))
+	}
+}
+
+func TestCheckArrivals(t *testing.T) {
+	t.Run("ConfiguredStops", func(t *testing.T) {
+		stops := map[string][2]int{"1_100": {4, 3}, "1_101": {0, 0}, "1_102": {4, 1}}
+		ts := setupTestServer(t, arrivalsHandler(func(stopID string) (int, int, bool) {
+			counts, ok := stops[stopID]
+			return counts[0], counts[1], ok
+		}))
+
+		server := models.ObaServer{
+			ID:            1601,
+			ObaBaseURL:    ts.URL,
+			ObaApiKey:     "test-key",
+			ArrivalsProbe: models.ArrivalsProbeSettings{StopIDs: []string{"1_100", "1_101", "1_102", "1_missing"}},
+		}
+
+		summary, err := CheckArrivals(context.Background(), nil, server)
+		if err != nil {
+			t.Fatalf("CheckArrivals failed: %v", err)
+		}
+		if summary.Stops != 3 || summary.FailedStops != 1 || summary.EmptyStops != 1 {
+			t.Errorf("Expected 3 answered, 1 failed and 1 empty stop, got %+v", summary)
+		}
+		if summary.RealtimeRatio() != 0.5 {
+			t.Errorf("Expected a realtime ratio of 0.5, got %v", summary.RealtimeRatio())
+		}
+
+		ratio, _ := getMetricValue(ArrivalsRealtimeRatio, map[string]string{"server_id": "1601"})
+		if ratio != 0.5 {
+			t.Errorf("Expected realtime ratio metric of 0.5, got %v", ratio)
+		}
+		empty, _ := getMetricValue(ArrivalsEmptyResponseRatio, map[string]string{"server_id": "1601"})
+		if empty != 1.0/3.0 {
+			t.Errorf("Expected empty response ratio of 1/3, got %v", empty)
+		}
+	})
+
+	t.Run("ScheduleOnly", func(t *testing.T) {
+		ts := setupTestServer(t, arrivalsHandler(func(string) (int, int, bool) { return 3, 0, true }))
+
+		server := models.ObaServer{
+			ID:            1602,
+			ObaBaseURL:    ts.URL,
+			ObaApiKey:     "test-key",
+			ArrivalsProbe: models.ArrivalsProbeSettings{StopIDs: []string{"1_100", "1_101"}},
+		}
+
+		_, err := CheckArrivals(context.Background(), nil, server)
+		if err == nil || !strings.Contains(err.Error(), "none of the 6 arrivals") {
+			t.Errorf("Expected an error about missing predictions, got %v", err)
+		}
+	})
+
+	t.Run("EveryStopFails", func(t *testing.T) {
+		ts := setupTestServer(t, arrivalsHandler(func(string) (int, int, bool) { return 0, 0, false }))
+
+		server := models.ObaServer{
+			ID:            1603,
+			ObaBaseURL:    ts.URL,
+			ObaApiKey:     "test-key",
+			ArrivalsProbe: models.ArrivalsProbeSettings{StopIDs: []string{"1_100"}},
+		}
+
+		if _, err := CheckArrivals(context.Background(), nil, server); err == nil {
+			t.Error("Expected an error when every stop fails")
+		}
+	})
+
+	t.Run("SampledStops", func(t *testing.T) {
+		b := loadBundleFixture(t, "gtfs.zip")
+
+		var mu sync.Mutex
+		var probed []string
+		ts := setupTestServer(t, arrivalsHandler(func(stopID string) (int, int, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			probed = append(probed, stopID)
+			return 1, 1, true
+		}))
+
+		server := models.ObaServer{
+			ID:            1604,
+			ObaBaseURL:    ts.URL,
+			ObaApiKey:     "test-key",
+			ArrivalsProbe: models.ArrivalsProbeSettings{SampleSize: 3},
+		}
+
+		if _, err := CheckArrivals(context.Background(), b, server); err != nil {
+			t.Fatalf("CheckArrivals failed: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(probed) != 3 {
+			t.Fatalf("Expected 3 sampled stops, got %v", probed)
+		}
+		for _, stopID := range probed {
+			// The fixture's only agency is 40.
+			if !strings.HasPrefix(stopID, "40_") {
+				t.Errorf("Expected stop IDs prefixed with the bundle's agency, got %s", stopID)
+			}
+		}
+	})
+}
+
+func TestSampleStopIDs(t *testing.T) {
+	b := &bundle.Bundle{
+		Static: &gtfs.Static{
+			Stops: []gtfs.Stop{
+				{Id: "s1", Type: gtfs.StopType_Stop},
+				{Id: "s2", Type: gtfs.StopType_Stop},
+				{Id: "s3", Type: gtfs.StopType_Stop},
+				{Id: "station", Type: gtfs.StopType_Station},
+			},
+		},
+		StopAgencies: map[string]string{"s1": "A", "s2": "B"},
+	}
+
+	got := sampleStopIDs(b, "A", 10)
+	sort.Strings(got)
+	// s3 is served by no trip and falls back to the default agency.
+	if want := []string{"A_s1", "A_s3", "B_s2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected every stop prefixed with its own agency %v, got %v", want, got)
+	}
+
+	// With a single agency, the configured agency ID is used for every stop.
+	b.StopAgencies = nil
+	got = sampleStopIDs(b, "1", 10)
+	sort.Strings(got)
+	if want := []string{"1_s1", "1_s2", "1_s3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the configured agency for every stop %v, got %v", want, got)
+	}
+}
@@ -3,55 +3,53 @@ package metrics
 import (
 	"fmt"
 	"log/slog"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/bundle"
 	"watchdog.onebusaway.org/internal/models"
 )
 
 // CheckBundleExpiration calculates the number of days remaining until the GTFS bundle expires.
-func CheckBundleExpiration(cachePath string, logger *slog.Logger, currentTime time.Time, server models.ObaServer) (int, int, error) {
-
-	file, err := os.Open(cachePath)
-	if err != nil {
-		return 0, 0, err
-	}
-	defer file.Close()
-
-	// Convert the file into a byte slice
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return 0, 0, err
-	}
-	fileBytes := make([]byte, fileInfo.Size())
-	_, err = file.Read(fileBytes)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	staticData, err := gtfs.ParseStatic(fileBytes, gtfs.ParseStaticOptions{})
-	if err != nil {
-		return 0, 0, err
-	}
-
+// The earliest expiration is the earliest last active date of any service. The latest is the
+// feed_end_date of feed_info.txt when set, otherwise the last date any service is active. It
+// also exports the days of remaining service coverage, up to the last date any trip runs.
+func CheckBundleExpiration(b *bundle.Bundle, logger *slog.Logger, currentTime time.Time, server models.ObaServer) (int, int, error) {
+	staticData := b.Static
 	if len(staticData.Services) == 0 {
 		return 0, 0, fmt.Errorf("no services found in GTFS bundle")
 	}
 
-	// Get the earliest and latest expiration dates
-	// This is workaround because the GTFS library does not support feed_info.txt
-	earliestEndDate := staticData.Services[0].EndDate
-	latestEndDate := staticData.Services[0].EndDate
+	var earliestEndDate, latestEndDate time.Time
 	for _, service := range staticData.Services {
-		if service.EndDate.Before(earliestEndDate) {
-			earliestEndDate = service.EndDate
+		lastDate := bundle.LastServiceDate(service, b.Calendars)
+		if lastDate.IsZero() {
+			continue
+		}
+		if earliestEndDate.IsZero() || lastDate.Before(earliestEndDate) {
+			earliestEndDate = lastDate
 		}
-		if service.EndDate.After(latestEndDate) {
-			latestEndDate = service.EndDate
+		if lastDate.After(latestEndDate) {
+			latestEndDate = lastDate
 		}
 	}
+	if latestEndDate.IsZero() {
+		return 0, 0, fmt.Errorf("no service in GTFS bundle is active on any date")
+	}
+
+	coverageEndDate := ServiceCoverageEnd(staticData, b.Calendars)
+
+	if b.FeedInfo != nil && !b.FeedInfo.EndDate.IsZero() {
+		if b.FeedInfo.EndDate.After(coverageEndDate) {
+			logger.Warn("feed_info.txt promises service beyond the last date any trip runs",
+				"server_id", server.ID,
+				"feed_end_date", b.FeedInfo.EndDate.Format(time.DateOnly),
+				"last_service_date", coverageEndDate.Format(time.DateOnly),
+			)
+		}
+		latestEndDate = b.FeedInfo.EndDate
+	}
 
 	daysUntilEarliestExpiration := int(earliestEndDate.Sub(currentTime).Hours() / 24)
 	daysUntilLatestExpiration := int(latestEndDate.Sub(currentTime).Hours() / 24)
@@ -59,5 +57,33 @@ func CheckBundleExpiration(cachePath string, logger *slog.Logger, currentTime ti
 	BundleEarliestExpirationGauge.WithLabelValues(strconv.Itoa(server.ID)).Set(float64(daysUntilEarliestExpiration))
 	BundleLatestExpirationGauge.WithLabelValues(strconv.Itoa(server.ID)).Set(float64(daysUntilLatestExpiration))
 
+	if coverageEndDate.IsZero() {
+		BundleServiceCoverageGauge.DeleteLabelValues(strconv.Itoa(server.ID))
+	} else {
+		BundleServiceCoverageGauge.WithLabelValues(strconv.Itoa(server.ID)).Set(float64(int(coverageEndDate.Sub(currentTime).Hours() / 24)))
+	}
+
 	return daysUntilEarliestExpiration, daysUntilLatestExpiration, nil
 }
+
+// ServiceCoverageEnd returns the last date on which any trip of the bundle runs, or the
+// zero time if none does.
+func ServiceCoverageEnd(staticData *gtfs.Static, calendars map[string]bundle.DateRange) time.Time {
+	used := make(map[string]bool)
+	for _, trip := range staticData.Trips {
+		if trip.Service != nil {
+			used[trip.Service.Id] = true
+		}
+	}
+
+	var coverageEnd time.Time
+	for _, service := range staticData.Services {
+		if !used[service.Id] {
+			continue
+		}
+		if lastDate := bundle.LastServiceDate(service, calendars); lastDate.After(coverageEnd) {
+			coverageEnd = lastDate
+		}
+	}
+	return coverageEnd
+}
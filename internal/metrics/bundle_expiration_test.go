@@ -5,16 +5,19 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/bundle"
 )
 
 func TestCheckBundleExpiration(t *testing.T) {
-	fixturePath := getFixturePath(t, "gtfs.zip")
+	b := loadBundleFixture(t, "gtfs.zip")
 	fixedTime := time.Date(2025, 1, 12, 20, 16, 38, 0, time.UTC)
 
 	testServer := createTestServer("www.example.com", "Test Server", 999, "", "www.example.com", "test-api-value", "test-api-key", "1")
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	earliest, latest, err := CheckBundleExpiration(fixturePath, logger, fixedTime, testServer)
+	earliest, latest, err := CheckBundleExpiration(b, logger, fixedTime, testServer)
 	if err != nil {
 		t.Fatalf("CheckBundleExpiration failed: %v", err)
 	}
@@ -46,3 +49,55 @@ func TestCheckBundleExpiration(t *testing.T) {
 		t.Errorf("Expected latest expiration metric to be %v, got %v", expectedLatest, latestMetric)
 	}
 }
+
+func TestCheckBundleExpirationWithoutServices(t *testing.T) {
+	testServer := createTestServer("www.example.com", "Test Server", 999, "", "www.example.com", "test-api-value", "test-api-key", "1")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	_, _, err := CheckBundleExpiration(&bundle.Bundle{Static: &gtfs.Static{}}, logger, time.Now(), testServer)
+	if err == nil {
+		t.Fatal("Expected an error for a bundle without services, got nil")
+	}
+}
+
+func TestCheckBundleExpirationCoverage(t *testing.T) {
+	staticData := loadStaticFixture(t, "gtfs.zip")
+	fixedTime := time.Date(2025, 1, 12, 20, 16, 38, 0, time.UTC)
+	testServer := createTestServer("www.example.com", "Test Server", 1701, "", "www.example.com", "test-api-value", "test-api-key", "1")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	// Without feed_info.txt the latest expiration is the last active service date.
+	// A removed date at the end of a calendar no longer counts as service.
+	b := &bundle.Bundle{Static: staticData, Calendars: loadBundleFixture(t, "gtfs.zip").Calendars}
+	_, latest, err := CheckBundleExpiration(b, logger, fixedTime, testServer)
+	if err != nil {
+		t.Fatalf("CheckBundleExpiration failed: %v", err)
+	}
+
+	lastServiceDate := ServiceCoverageEnd(staticData, b.Calendars)
+	// The last service of the fixture runs on Friday 2025-03-28.
+	if got := lastServiceDate.Format(time.DateOnly); got != "2025-03-28" {
+		t.Errorf("Expected service coverage to end on 2025-03-28, got %s", got)
+	}
+	if expected := int(lastServiceDate.Sub(fixedTime).Hours() / 24); latest != expected {
+		t.Errorf("Expected latest expiration of %d days, got %d", expected, latest)
+	}
+
+	coverage, err := getMetricValue(BundleServiceCoverageGauge, map[string]string{"server_id": "1701"})
+	if err != nil {
+		t.Fatalf("Failed to get service coverage metric value: %v", err)
+	}
+	if coverage != float64(latest) {
+		t.Errorf("Expected %d days of service coverage, got %v", latest, coverage)
+	}
+
+	// feed_info.txt takes precedence for the latest expiration.
+	b.FeedInfo = &bundle.FeedInfo{EndDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)}
+	_, latest, err = CheckBundleExpiration(b, logger, fixedTime, testServer)
+	if err != nil {
+		t.Fatalf("CheckBundleExpiration failed: %v", err)
+	}
+	if expected := int(b.FeedInfo.EndDate.Sub(fixedTime).Hours() / 24); latest != expected {
+		t.Errorf("Expected latest expiration from feed_info.txt of %d days, got %d", expected, latest)
+	}
+}
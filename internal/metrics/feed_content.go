@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// feedContentState is the content of a feed when it was last fetched.
+type feedContentState struct {
+	hash      string
+	idle      bool
+	changedAt time.Time
+}
+
+// FeedContentTracker remembers a hash of the last content fetched from each GTFS-RT
+// feed of every server, keyed by feed URL, and when that content last changed. The
+// checks record every feed they fetch. Timestamps are left out of the hash, so a
+// producer that refreshes them on otherwise identical data does not count as a change.
+// It is safe for concurrent use.
+type FeedContentTracker struct {
+	mu      sync.Mutex
+	servers map[int]map[string]feedContentState
+}
+
+// NewFeedContentTracker creates an empty FeedContentTracker.
+func NewFeedContentTracker() *FeedContentTracker {
+	return &FeedContentTracker{servers: make(map[int]map[string]feedContentState)}
+}
+
+// Observe records data as fetched from feedURL of the server at now. idle tells whether
+// the feed had any service to report. The first observation of a feed counts as a
+// change, since earlier content is unknown. A nil tracker records nothing.
+func (t *FeedContentTracker) Observe(serverID int, feedURL string, data []byte, idle bool, now time.Time) {
+	if t == nil {
+		return
+	}
+	hash := contentHash(data)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	feeds, ok := t.servers[serverID]
+	if !ok {
+		feeds = make(map[string]feedContentState)
+		t.servers[serverID] = feeds
+	}
+	state, ok := feeds[feedURL]
+	if !ok || state.hash != hash {
+		state = feedContentState{hash: hash, changedAt: now}
+	}
+	state.idle = idle
+	feeds[feedURL] = state
+}
+
+// lastChange returns the state of the content last fetched from feedURL of the server,
+// if the feed has been fetched.
+func (t *FeedContentTracker) lastChange(serverID int, feedURL string) (feedContentState, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.servers[serverID][feedURL]
+	return state, ok
+}
+
+// Retain removes the feeds of all servers not listed in serverIDs.
+func (t *FeedContentTracker) Retain(serverIDs []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	models.RetainServers(t.servers, serverIDs)
+}
+
+// feedMessageDescriptor describes the GTFS-RT feed message walked by contentHash.
+var feedMessageDescriptor = (&gtfsrt.FeedMessage{}).ProtoReflect().Descriptor()
+
+// contentHash returns the SHA-256 of the encoded feed message in data, leaving out the
+// header, trip update and vehicle timestamps. It walks the wire format instead of
+// decoding the message, so recording the content costs little next to parsing the feed.
+// Data that is not a valid message is hashed as is.
+func contentHash(data []byte) string {
+	h := sha256.New()
+	if !hashFields(h, data, feedMessageDescriptor) {
+		h.Reset()
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashFields writes the fields of the message of type desc encoded in b to h, except
+// those named timestamp, walking nested messages the same way. It reports whether b is
+// a valid encoding.
+func hashFields(h hash.Hash, b []byte, desc protoreflect.MessageDescriptor) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return false
+		}
+
+		field := desc.Fields().ByNumber(num)
+		switch {
+		case field != nil && field.Name() == "timestamp":
+		case field != nil && field.Kind() == protoreflect.MessageKind && typ == protowire.BytesType:
+			// Leaving out timestamps changes the length of the nested message, so it is
+			// delimited like a group instead.
+			value, _ := protowire.ConsumeBytes(b[n:])
+			h.Write(protowire.AppendTag(nil, num, protowire.StartGroupType))
+			if !hashFields(h, value, field.Message()) {
+				return false
+			}
+			h.Write(protowire.AppendTag(nil, num, protowire.EndGroupType))
+		default:
+			h.Write(b[:n+m])
+		}
+		b = b[n+m:]
+	}
+	return true
+}
+
+// feedIdle reports whether the feed has no trip updates and no vehicles assigned to a
+// trip. Producers keep serving the same empty feed, or the same parked vehicles, while
+// there is no service, which is not a sign of a hung producer.
+func feedIdle(realtimeData *gtfs.Realtime) bool {
+	for _, trip := range realtimeData.Trips {
+		if trip.IsEntityInMessage || trip.Vehicle != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// FeedContentChange is the time the content of a GTFS-RT feed last changed.
+type FeedContentChange struct {
+	// Feed is the URL of the feed, with credentials redacted.
+	Feed string
+	// FeedType is vehicle_positions or trip_updates.
+	FeedType  string
+	Hash      string
+	ChangedAt time.Time
+	// Idle is set when the feed has no trip updates and no vehicles assigned to a trip,
+	// such as overnight. Idle feeds are not expected to change.
+	Idle bool
+	// Unchanged is how long the content has been identical at the time of the check,
+	// or zero when the feed is idle.
+	Unchanged time.Duration
+}
+
+// CheckFeedContentChanges exports, by feed type, the seconds since the content of the
+// longest unchanged vehicle positions or trip updates feed of the server last changed,
+// as recorded in tracker by the checks that fetch the feeds. Feeds that have not been
+// fetched yet are left out. It returns an error naming the feeds whose content has not
+// changed for longer than the server's frozen feed threshold. Idle feeds, with no
+// service to report, are never frozen.
+func CheckFeedContentChanges(tracker *FeedContentTracker, server models.ObaServer, now time.Time) ([]FeedContentChange, error) {
+	feedsByType := []struct {
+		feedType string
+		feeds    []models.GtfsRtFeed
+	}{
+		{"vehicle_positions", server.VehiclePositionFeeds()},
+		{"trip_updates", server.TripUpdateFeeds()},
+	}
+
+	configured := false
+	var changes []FeedContentChange
+	for _, group := range feedsByType {
+		for _, feed := range group.feeds {
+			configured = true
+			state, ok := tracker.lastChange(server.ID, feed.URL)
+			if !ok {
+				continue
+			}
+			change := FeedContentChange{
+				Feed:      feed.RedactedURL(),
+				FeedType:  group.feedType,
+				Hash:      state.hash,
+				ChangedAt: state.changedAt,
+				Idle:      state.idle,
+			}
+			if !state.idle {
+				change.Unchanged = max(now.Sub(state.changedAt), 0)
+			}
+			changes = append(changes, change)
+		}
+	}
+	if !configured {
+		return nil, fmt.Errorf("no vehicle positions or trip updates URL configured for server %d", server.ID)
+	}
+
+	return changes, recordFeedContentChanges(changes, server)
+}
+
+// recordFeedContentChanges exports the longest unchanged feed of each type and returns
+// an error naming the frozen feeds.
+func recordFeedContentChanges(changes []FeedContentChange, server models.ObaServer) error {
+	serverID := strconv.Itoa(server.ID)
+	longest := make(map[string]time.Duration)
+	for _, change := range changes {
+		longest[change.FeedType] = max(longest[change.FeedType], change.Unchanged)
+	}
+	RealtimeFeedUnchanged.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+	for feedType, unchanged := range longest {
+		RealtimeFeedUnchanged.WithLabelValues(serverID, feedType).Set(unchanged.Seconds())
+	}
+
+	threshold := server.FeedFrozenThreshold()
+	var frozen []string
+	for _, change := range changes {
+		if change.Unchanged > threshold {
+			frozen = append(frozen, fmt.Sprintf("%s %s unchanged for %s", change.FeedType, change.Feed, change.Unchanged.Truncate(time.Second)))
+		}
+	}
+	if len(frozen) > 0 {
+		sort.Strings(frozen)
+		return fmt.Errorf("GTFS-RT feed content has not changed for more than %s: %s", threshold, strings.Join(frozen, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func encodeFeed(t *testing.T, timestamp time.Time, entities ...*gtfsrt.FeedEntity) []byte {
+	t.Helper()
+
+	data, err := proto.Marshal(&gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0"), Timestamp: proto.Uint64(uint64(timestamp.Unix()))},
+		Entity: entities,
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode feed: %v", err)
+	}
+	return data
+}
+
+func TestContentHash(t *testing.T) {
+	start := time.Unix(1736700000, 0)
+	later := start.Add(time.Minute)
+	hash := contentHash(encodeFeed(t, start, positionEntity("1", "T1", 47.6, -122.3, start), tripUpdateEntity("2", "T2")))
+
+	if got := contentHash(encodeFeed(t, later, positionEntity("1", "T1", 47.6, -122.3, later), tripUpdateEntity("2", "T2"))); got != hash {
+		t.Error("Expected refreshed timestamps not to change the hash")
+	}
+	if got := contentHash(encodeFeed(t, start, positionEntity("1", "T1", 47.7, -122.3, start), tripUpdateEntity("2", "T2"))); got == hash {
+		t.Error("Expected a moved vehicle to change the hash")
+	}
+	if got := contentHash([]byte("not a feed")); got == "" || got == hash {
+		t.Errorf("Expected invalid data to be hashed as is, got %q", got)
+	}
+}
+
+func TestFeedContentTracker(t *testing.T) {
+	tracker := NewFeedContentTracker()
+	start := time.Unix(1736700000, 0)
+	feed := func(tripID string) []byte {
+		return encodeFeed(t, start, positionEntity("1", tripID, 47.6, -122.3, start))
+	}
+	changedAt := func(serverID int, url string) time.Time {
+		state, _ := tracker.lastChange(serverID, url)
+		return state.changedAt
+	}
+
+	if _, ok := tracker.lastChange(1, "feed"); ok {
+		t.Fatal("Expected no content before the first fetch")
+	}
+
+	tracker.Observe(1, "feed", feed("T1"), false, start)
+	if got := changedAt(1, "feed"); !got.Equal(start) {
+		t.Errorf("Expected the first fetch to count as a change, got %v", got)
+	}
+
+	tracker.Observe(1, "feed", feed("T1"), false, start.Add(time.Minute))
+	if got := changedAt(1, "feed"); !got.Equal(start) {
+		t.Errorf("Expected identical content to keep the change time, got %v", got)
+	}
+
+	tracker.Observe(1, "feed", feed("T2"), false, start.Add(2*time.Minute))
+	if got := changedAt(1, "feed"); !got.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Expected new content to be a change, got %v", got)
+	}
+
+	tracker.Observe(2, "feed", feed("T2"), false, start.Add(3*time.Minute))
+	if got := changedAt(2, "feed"); !got.Equal(start.Add(3 * time.Minute)) {
+		t.Errorf("Expected servers to be tracked separately, got %v", got)
+	}
+
+	tracker.Retain([]int{2})
+	if _, ok := tracker.lastChange(1, "feed"); ok {
+		t.Error("Expected the feeds of removed servers to be forgotten")
+	}
+
+	var none *FeedContentTracker
+	none.Observe(1, "feed", feed("T1"), false, start)
+}
+
+func TestCheckFeedContentChanges(t *testing.T) {
+	now := time.Now()
+
+	// Vehicles move on every fetch, while the trip updates producer only refreshes the
+	// header timestamp of identical data.
+	var moves float32
+	vehicles := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		moves += 0.001
+		w.Write(encodeFeed(t, time.Now(), positionEntity("1", "T1", 47.6+moves, -122.3, now)))
+	}))
+	tripUpdates := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encodeFeed(t, time.Now(), tripUpdateEntity("1", "T1")))
+	}))
+	server := models.ObaServer{ID: 2501, VehiclePositionUrl: vehicles.URL, TripUpdateUrl: tripUpdates.URL}
+	tracker := NewFeedContentTracker()
+	staticData := loadStaticFixture(t, "gtfs.zip")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	changes, err := CheckFeedContentChanges(tracker, server, now)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes before the feeds are fetched, got %+v (%v)", changes, err)
+	}
+
+	fetchFeeds := func() {
+		t.Helper()
+		if _, err := CountVehiclePositions(context.Background(), tracker, server); err != nil {
+			t.Fatalf("CountVehiclePositions failed: %v", err)
+		}
+		if _, err := CheckTripUpdates(context.Background(), tracker, staticData, logger, server); err != nil {
+			t.Fatalf("CheckTripUpdates failed: %v", err)
+		}
+	}
+
+	fetchFeeds()
+	changes, err = CheckFeedContentChanges(tracker, server, now)
+	if err != nil {
+		t.Fatalf("Expected feeds seen for the first time to pass, got %v", err)
+	}
+	if len(changes) != 2 || changes[0].FeedType != "vehicle_positions" || changes[1].FeedType != "trip_updates" || changes[1].Hash == "" {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+
+	// Pretend both feeds were first fetched ten minutes ago.
+	tracker.mu.Lock()
+	for _, url := range []string{vehicles.URL, tripUpdates.URL} {
+		state := tracker.servers[server.ID][url]
+		state.changedAt = now.Add(-10 * time.Minute)
+		tracker.servers[server.ID][url] = state
+	}
+	tracker.mu.Unlock()
+
+	fetchFeeds()
+	changes, err = CheckFeedContentChanges(tracker, server, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "trip_updates") || strings.Contains(err.Error(), "vehicle_positions") {
+		t.Errorf("Expected only the trip updates feed to be frozen, got %v", err)
+	}
+	if changes[0].Unchanged > time.Minute || changes[1].Unchanged < 10*time.Minute {
+		t.Errorf("Expected only the trip updates feed to be unchanged for 10m, got %+v", changes)
+	}
+
+	got, err := getMetricValue(RealtimeFeedUnchanged, map[string]string{"server_id": "2501", "feed_type": "trip_updates"})
+	if err != nil || got < 600 {
+		t.Errorf("Expected 600 seconds since the trip updates changed, got %v (%v)", got, err)
+	}
+
+	server.FeedFrozenThresholdSeconds = 900
+	if _, err := CheckFeedContentChanges(tracker, server, time.Now()); err != nil {
+		t.Errorf("Expected no frozen feeds below a 15 minute threshold, got %v", err)
+	}
+
+	if _, err := CheckFeedContentChanges(tracker, models.ObaServer{ID: 2503}, now); err == nil {
+		t.Error("Expected an error for a server without realtime feeds")
+	}
+}
+
+func TestCheckFeedContentChangesIdleFeeds(t *testing.T) {
+	now := time.Unix(1736700000, 0)
+	tracker := NewFeedContentTracker()
+	server := models.ObaServer{ID: 2502, VehiclePositionUrl: "https://example.com/vehicles", TripUpdateUrl: "https://example.com/trips"}
+
+	// Overnight the vehicles are parked without a trip and there are no trip updates.
+	for _, at := range []time.Time{now.Add(-time.Hour), now} {
+		tracker.Observe(server.ID, server.VehiclePositionUrl, encodeFeed(t, at, positionEntity("1", "", 47.6, -122.3, at)), true, at)
+		tracker.Observe(server.ID, server.TripUpdateUrl, encodeFeed(t, at), true, at)
+	}
+
+	changes, err := CheckFeedContentChanges(tracker, server, now)
+	if err != nil {
+		t.Errorf("Expected idle feeds not to be frozen, got %v", err)
+	}
+	for _, change := range changes {
+		if !change.Idle || change.Unchanged != 0 || !change.ChangedAt.Equal(now.Add(-time.Hour)) {
+			t.Errorf("Expected an idle feed unchanged for an hour, got %+v", change)
+		}
+	}
+
+	got, err := getMetricValue(RealtimeFeedUnchanged, map[string]string{"server_id": "2502", "feed_type": "vehicle_positions"})
+	if err != nil || got != 0 {
+		t.Errorf("Expected 0 seconds for an idle feed, got %v (%v)", got, err)
+	}
+}
+
+func TestFeedIdle(t *testing.T) {
+	now := time.Unix(1736700000, 0)
+	for _, tc := range []struct {
+		name     string
+		entities []*gtfsrt.FeedEntity
+		idle     bool
+	}{
+		{"Empty", nil, true},
+		{"ParkedVehicles", []*gtfsrt.FeedEntity{positionEntity("1", "", 47.6, -122.3, now)}, true},
+		{"VehicleOnTrip", []*gtfsrt.FeedEntity{positionEntity("1", "T1", 47.6, -122.3, now)}, false},
+		{"TripUpdate", []*gtfsrt.FeedEntity{tripUpdateEntity("1", "T1")}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			realtimeData, err := gtfs.ParseRealtime(encodeFeed(t, now, tc.entities...), &gtfs.ParseRealtimeOptions{})
+			if err != nil {
+				t.Fatalf("ParseRealtime failed: %v", err)
+			}
+			if got := feedIdle(realtimeData); got != tc.idle {
+				t.Errorf("Expected idle %v, got %v", tc.idle, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// FeedFreshness describes how old the data in a GTFS-RT vehicle positions feed is.
+type FeedFreshness struct {
+	// HasHeaderTimestamp is false when the feed header carries no timestamp.
+	HasHeaderTimestamp bool
+	FeedAge            time.Duration
+	// VehiclesWithTimestamp counts the vehicles used for the age statistics below.
+	VehiclesWithTimestamp int
+	OldestVehicleAge      time.Duration
+	MedianVehicleAge      time.Duration
+	StaleVehicles         int
+}
+
+// ComputeFeedFreshness derives the feed and vehicle ages of realtimeData relative to currentTime.
+// Vehicles older than staleThreshold are counted as stale; vehicles without a timestamp are ignored.
+func ComputeFeedFreshness(realtimeData *gtfs.Realtime, currentTime time.Time, staleThreshold time.Duration) FeedFreshness {
+	var freshness FeedFreshness
+
+	if !realtimeData.CreatedAt.IsZero() {
+		freshness.HasHeaderTimestamp = true
+		freshness.FeedAge = currentTime.Sub(realtimeData.CreatedAt)
+	}
+
+	var ages []time.Duration
+	for _, vehicle := range realtimeData.Vehicles {
+		if vehicle.Timestamp == nil {
+			continue
+		}
+		age := currentTime.Sub(*vehicle.Timestamp)
+		ages = append(ages, age)
+		if age > staleThreshold {
+			freshness.StaleVehicles++
+		}
+	}
+
+	if len(ages) == 0 {
+		return freshness
+	}
+
+	sort.Slice(ages, func(i, j int) bool { return ages[i] < ages[j] })
+
+	freshness.VehiclesWithTimestamp = len(ages)
+	freshness.OldestVehicleAge = ages[len(ages)-1]
+	if mid := len(ages) / 2; len(ages)%2 == 1 {
+		freshness.MedianVehicleAge = ages[mid]
+	} else {
+		freshness.MedianVehicleAge = (ages[mid-1] + ages[mid]) / 2
+	}
+
+	return freshness
+}
+
+// recordFeedFreshness exports the freshness of the vehicle positions feed of the server.
+func recordFeedFreshness(realtimeData *gtfs.Realtime, server models.ObaServer, currentTime time.Time) FeedFreshness {
+	freshness := ComputeFeedFreshness(realtimeData, currentTime, server.VehicleStaleThreshold())
+	serverID := strconv.Itoa(server.ID)
+
+	if freshness.HasHeaderTimestamp {
+		RealtimeFeedAge.WithLabelValues(serverID).Set(freshness.FeedAge.Seconds())
+	} else {
+		RealtimeFeedAge.DeleteLabelValues(serverID)
+	}
+
+	// Without vehicle timestamps the ages are unknown, not zero.
+	if freshness.VehiclesWithTimestamp > 0 {
+		RealtimeOldestVehicleAge.WithLabelValues(serverID).Set(freshness.OldestVehicleAge.Seconds())
+		RealtimeMedianVehicleAge.WithLabelValues(serverID).Set(freshness.MedianVehicleAge.Seconds())
+	} else {
+		RealtimeOldestVehicleAge.DeleteLabelValues(serverID)
+		RealtimeMedianVehicleAge.DeleteLabelValues(serverID)
+	}
+	RealtimeStaleVehicles.WithLabelValues(serverID).Set(float64(freshness.StaleVehicles))
+
+	return freshness
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func vehicleAt(timestamp time.Time) gtfs.Vehicle {
+	return gtfs.Vehicle{Timestamp: &timestamp}
+}
+
+func TestComputeFeedFreshness(t *testing.T) {
+	now := time.Date(2025, 1, 19, 21, 0, 0, 0, time.UTC)
+
+	t.Run("Header and vehicle timestamps", func(t *testing.T) {
+		realtimeData := &gtfs.Realtime{
+			CreatedAt: now.Add(-30 * time.Second),
+			Vehicles: []gtfs.Vehicle{
+				vehicleAt(now.Add(-10 * time.Second)),
+				vehicleAt(now.Add(-40 * time.Second)),
+				vehicleAt(now.Add(-20 * time.Minute)),
+				vehicleAt(now.Add(-60 * time.Second)),
+				{},
+			},
+		}
+
+		freshness := ComputeFeedFreshness(realtimeData, now, 5*time.Minute)
+
+		if !freshness.HasHeaderTimestamp || freshness.FeedAge != 30*time.Second {
+			t.Errorf("Expected feed age of 30s, got %v (has header timestamp: %v)", freshness.FeedAge, freshness.HasHeaderTimestamp)
+		}
+		if freshness.VehiclesWithTimestamp != 4 {
+			t.Errorf("Expected 4 vehicles with timestamp, got %d", freshness.VehiclesWithTimestamp)
+		}
+		if freshness.OldestVehicleAge != 20*time.Minute {
+			t.Errorf("Expected oldest vehicle age of 20m, got %v", freshness.OldestVehicleAge)
+		}
+		if freshness.MedianVehicleAge != 50*time.Second {
+			t.Errorf("Expected median vehicle age of 50s, got %v", freshness.MedianVehicleAge)
+		}
+		if freshness.StaleVehicles != 1 {
+			t.Errorf("Expected 1 stale vehicle, got %d", freshness.StaleVehicles)
+		}
+	})
+
+	t.Run("No timestamps", func(t *testing.T) {
+		freshness := ComputeFeedFreshness(&gtfs.Realtime{Vehicles: []gtfs.Vehicle{{}}}, now, time.Minute)
+
+		if freshness.HasHeaderTimestamp {
+			t.Error("Expected feed without header timestamp")
+		}
+		if freshness.VehiclesWithTimestamp != 0 || freshness.StaleVehicles != 0 {
+			t.Errorf("Expected no vehicle statistics, got %+v", freshness)
+		}
+	})
+}
+
+func TestCountVehiclePositionsRecordsFreshness(t *testing.T) {
+	mockServer := setupGtfsRtServer(t, "gtfs_rt_feed_vehicles.pb")
+	defer mockServer.Close()
+
+	server := models.ObaServer{
+		ID:                           905,
+		VehiclePositionUrl:           mockServer.URL,
+		VehicleStaleThresholdSeconds: 60,
+	}
+
+	if _, err := CountVehiclePositions(context.Background(), nil, server); err != nil {
+		t.Fatalf("CountVehiclePositions failed: %v", err)
+	}
+
+	// The fixture was recorded in January 2025, so the feed and all of its vehicles are stale by now.
+	feedAge, err := getMetricValue(RealtimeFeedAge, map[string]string{"server_id": "905"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feedAge < 60 {
+		t.Errorf("Expected the fixture feed to be older than a minute, got %v seconds", feedAge)
+	}
+
+	stale, err := getMetricValue(RealtimeStaleVehicles, map[string]string{"server_id": "905"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale != 9 {
+		t.Errorf("Expected all 9 fixture vehicles to be stale, got %v", stale)
+	}
+}
+
+func TestRecordFeedFreshnessWithoutVehicleTimestamps(t *testing.T) {
+	now := time.Date(2025, 1, 19, 21, 0, 0, 0, time.UTC)
+	server := models.ObaServer{ID: 906}
+
+	recordFeedFreshness(&gtfs.Realtime{Vehicles: []gtfs.Vehicle{vehicleAt(now.Add(-time.Minute))}}, server, now)
+	recordFeedFreshness(&gtfs.Realtime{Vehicles: []gtfs.Vehicle{{}}}, server, now)
+
+	// DeleteLabelValues reports whether the series existed.
+	if RealtimeOldestVehicleAge.DeleteLabelValues("906") || RealtimeMedianVehicleAge.DeleteLabelValues("906") {
+		t.Error("Expected the vehicle age series to be removed when no vehicle has a timestamp")
+	}
+}
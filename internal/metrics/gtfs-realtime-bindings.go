@@ -2,103 +2,236 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	onebusaway "github.com/OneBusAway/go-sdk"
-	"github.com/OneBusAway/go-sdk/option"
 	"github.com/getsentry/sentry-go"
 	"github.com/jamespfennell/gtfs"
 	"watchdog.onebusaway.org/internal/models"
 )
 
-func CountVehiclePositions(server models.ObaServer) (int, error) {
-	parsedURL, err := url.Parse(server.VehiclePositionUrl)
+// fetchGtfsRtFeed downloads the GTFS-RT feed and parses it, sending the feed's
+// auth header if it has one. The content of the feed is recorded in feedContent under
+// the server.
+func fetchGtfsRtFeed(ctx context.Context, feedContent *FeedContentTracker, serverID int, feed models.GtfsRtFeed) (*gtfs.Realtime, error) {
+	data, err := fetchGtfsRtData(ctx, feed)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse GTFS-RT URL: %v", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", parsedURL.String(), nil)
+	realtimeData, err := gtfs.ParseRealtime(data, &gtfs.ParseRealtimeOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %v", err)
+	}
+	feedContent.Observe(serverID, feed.URL, data, feedIdle(realtimeData), time.Now())
+
+	return realtimeData, nil
+}
+
+// fetchGtfsRtData downloads the GTFS-RT feed without parsing it.
+func fetchGtfsRtData(ctx context.Context, feed models.GtfsRtFeed) ([]byte, error) {
+	parsedURL, err := url.Parse(feed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GTFS-RT URL: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", parsedURL.String(), nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create HTTP request: %v", err)
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
 	}
-	if server.GtfsRtApiKey != "" && server.GtfsRtApiValue != "" {
-		req.Header.Set(server.GtfsRtApiKey, server.GtfsRtApiValue)
+	if feed.ApiKey != "" && feed.ApiValue != "" {
+		req.Header.Set(feed.ApiKey, feed.ApiValue)
 	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		sentry.CaptureException(err)
-		return 0, fmt.Errorf("failed to fetch GTFS-RT feed: %v", err)
+		return nil, fmt.Errorf("failed to fetch GTFS-RT feed: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GTFS-RT feed returned status: %d", resp.StatusCode)
+	}
+
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read GTFS-RT feed: %v", err)
+		return nil, fmt.Errorf("failed to read GTFS-RT feed: %v", err)
 	}
 
-	realtimeData, err := gtfs.ParseRealtime(data, &gtfs.ParseRealtimeOptions{})
+	return data, nil
+}
+
+// vehicleFeedGroup is a vehicle positions feed and the agencies it covers.
+type vehicleFeedGroup struct {
+	feed     models.GtfsRtFeed
+	agencies []models.Agency
+}
+
+// vehicleFeedGroups groups the agencies of the server by vehicle positions feed, in
+// configuration order. Agencies sharing a feed are compared with it together.
+func vehicleFeedGroups(server models.ObaServer) []vehicleFeedGroup {
+	var groups []vehicleFeedGroup
+	index := make(map[models.GtfsRtFeed]int)
+	for _, agency := range server.AgencyList() {
+		feed := agency.VehiclePositionFeed()
+		i, ok := index[feed]
+		if !ok {
+			i = len(groups)
+			index[feed] = i
+			groups = append(groups, vehicleFeedGroup{feed: feed})
+		}
+		groups[i].agencies = append(groups[i].agencies, agency)
+	}
+	return groups
+}
+
+// countFeedVehicles counts the vehicle positions in one feed of the server.
+func countFeedVehicles(ctx context.Context, feedContent *FeedContentTracker, server models.ObaServer, feed models.GtfsRtFeed) (*gtfs.Realtime, int, error) {
+	realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, feed)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse GTFS-RT feed: %v", err)
+		return nil, 0, err
 	}
 
 	count := len(realtimeData.Vehicles)
 
 	RealtimeVehiclePositions.WithLabelValues(
-		server.VehiclePositionUrl,
+		feed.URL,
 		strconv.Itoa(server.ID),
 	).Set(float64(count))
 
-	return count, nil
+	return realtimeData, count, nil
 }
 
-func VehiclesForAgencyAPI(server models.ObaServer) (int, error) {
+// CountVehiclePositions counts the vehicle positions in every distinct vehicle
+// positions feed of the server and records the freshness of the combined data.
+func CountVehiclePositions(ctx context.Context, feedContent *FeedContentTracker, server models.ObaServer) (int, error) {
+	var feeds []*gtfs.Realtime
+	total := 0
+	for _, group := range vehicleFeedGroups(server) {
+		realtimeData, count, err := countFeedVehicles(ctx, feedContent, server, group.feed)
+		if err != nil {
+			return 0, err
+		}
+		feeds = append(feeds, realtimeData)
+		total += count
+	}
+
+	recordFeedFreshness(mergeRealtime(feeds), server, time.Now())
 
-	client := onebusaway.NewClient(
-		option.WithAPIKey(server.ObaApiKey),
-		option.WithBaseURL(server.ObaBaseURL),
-	)
+	return total, nil
+}
+
+// mergeRealtime combines the vehicles of several feeds. The combined feed is as old
+// as its oldest feed, and has no header timestamp if any feed lacks one.
+func mergeRealtime(feeds []*gtfs.Realtime) *gtfs.Realtime {
+	if len(feeds) == 1 {
+		return feeds[0]
+	}
 
-	ctx := context.Background()
+	merged := &gtfs.Realtime{CreatedAt: feeds[0].CreatedAt}
+	for _, feed := range feeds {
+		merged.Vehicles = append(merged.Vehicles, feed.Vehicles...)
+		if feed.CreatedAt.IsZero() || merged.CreatedAt.IsZero() {
+			merged.CreatedAt = time.Time{}
+		} else if feed.CreatedAt.Before(merged.CreatedAt) {
+			merged.CreatedAt = feed.CreatedAt
+		}
+	}
+	return merged
+}
 
-	response, err := client.VehiclesForAgency.List(ctx, server.AgencyID, onebusaway.VehiclesForAgencyListParams{})
+// fetchAgencyVehicles returns the vehicles the OBA API reports for one agency.
+func fetchAgencyVehicles(ctx context.Context, client *onebusaway.Client, server models.ObaServer, agencyID string) ([]onebusaway.VehiclesForAgencyListResponseDataList, error) {
+	response, err := client.VehiclesForAgency.List(ctx, agencyID, onebusaway.VehiclesForAgencyListParams{})
 
 	if err != nil {
+		recordObaDecodeError(server, "vehicles-for-agency", err)
 		sentry.CaptureException(err)
-		return 0, err
+		return nil, err
 	}
 
 	if response == nil {
-		return 0, nil
+		return nil, nil
 	}
 
-	VehicleCountAPI.WithLabelValues(server.AgencyID, strconv.Itoa(server.ID)).Set(float64(len(response.Data.List)))
+	VehicleCountAPI.WithLabelValues(agencyID, strconv.Itoa(server.ID)).Set(float64(len(response.Data.List)))
 
-	return len(response.Data.List), nil
+	return response.Data.List, nil
 }
 
-func CheckVehicleCountMatch(server models.ObaServer) error {
-	gtfsRtVehicleCount, err := CountVehiclePositions(server)
-	if err != nil {
-		return fmt.Errorf("failed to count vehicle positions from GTFS-RT: %v", err)
-	}
+// vehiclesForAgency counts the vehicles the OBA API reports for one agency.
+func vehiclesForAgency(ctx context.Context, client *onebusaway.Client, server models.ObaServer, agencyID string) (int, error) {
+	vehicles, err := fetchAgencyVehicles(ctx, client, server, agencyID)
+	return len(vehicles), err
+}
 
-	apiVehicleCount, err := VehiclesForAgencyAPI(server)
-	if err != nil {
-		return fmt.Errorf("failed to count vehicle positions from API: %v", err)
+// VehiclesForAgencyAPI counts the vehicles the OBA API reports across all agencies of the server.
+func VehiclesForAgencyAPI(ctx context.Context, server models.ObaServer) (int, error) {
+	client := newObaClient(server)
+
+	total := 0
+	for _, agency := range server.AgencyList() {
+		count, err := vehiclesForAgency(ctx, client, server, agency.ID)
+		if err != nil {
+			return 0, err
+		}
+		total += count
 	}
 
-	match := 0
-	if gtfsRtVehicleCount == apiVehicleCount {
-		match = 1
+	return total, nil
+}
+
+// CheckVehicleCountMatch compares every vehicle positions feed of the server with the
+// vehicles the OBA API reports for the agencies it covers.
+func CheckVehicleCountMatch(ctx context.Context, feedContent *FeedContentTracker, server models.ObaServer) error {
+	client := newObaClient(server)
+	serverID := strconv.Itoa(server.ID)
+
+	var errs []error
+	var feeds []*gtfs.Realtime
+	for _, group := range vehicleFeedGroups(server) {
+		realtimeData, gtfsRtVehicleCount, err := countFeedVehicles(ctx, feedContent, server, group.feed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to count vehicle positions from GTFS-RT: %v", err))
+			continue
+		}
+		feeds = append(feeds, realtimeData)
+
+		apiVehicleCount := 0
+		var apiErr error
+		for _, agency := range group.agencies {
+			count, err := vehiclesForAgency(ctx, client, server, agency.ID)
+			if err != nil {
+				apiErr = fmt.Errorf("failed to count vehicle positions from API: %v", err)
+				break
+			}
+			apiVehicleCount += count
+		}
+		if apiErr != nil {
+			errs = append(errs, apiErr)
+			continue
+		}
+
+		match := 0
+		if gtfsRtVehicleCount == apiVehicleCount {
+			match = 1
+		}
+		for _, agency := range group.agencies {
+			VehicleCountMatch.WithLabelValues(agency.ID, serverID).Set(float64(match))
+		}
 	}
 
-	VehicleCountMatch.WithLabelValues(server.AgencyID, strconv.Itoa(server.ID)).Set(float64(match))
+	if len(feeds) > 0 {
+		recordFeedFreshness(mergeRealtime(feeds), server, time.Now())
+	}
 
-	return nil
+	return errors.Join(errs...)
 }
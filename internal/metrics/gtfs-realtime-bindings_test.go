@@ -1,13 +1,32 @@
 package metrics
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
 	"watchdog.onebusaway.org/internal/models"
 )
 
+// vehiclePositionsFeed builds a vehicle positions feed with the given vehicle IDs.
+func vehiclePositionsFeed(vehicleIDs ...string) *gtfsrt.FeedMessage {
+	feed := &gtfsrt.FeedMessage{Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")}}
+	for _, id := range vehicleIDs {
+		feed.Entity = append(feed.Entity, &gtfsrt.FeedEntity{
+			Id: proto.String(id),
+			Vehicle: &gtfsrt.VehiclePosition{
+				Vehicle: &gtfsrt.VehicleDescriptor{Id: proto.String(id)},
+			},
+		})
+	}
+	return feed
+}
+
 func TestCountVehiclePositions(t *testing.T) {
 	t.Run("Valid GTFS-RT response", func(t *testing.T) {
 		mockServer := setupGtfsRtServer(t, "gtfs_rt_feed_vehicles.pb")
@@ -20,7 +39,7 @@ func TestCountVehiclePositions(t *testing.T) {
 			GtfsRtApiValue:     "test-key",
 		}
 
-		count, err := CountVehiclePositions(server)
+		count, err := CountVehiclePositions(context.Background(), nil, server)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
@@ -35,7 +54,7 @@ func TestCountVehiclePositions(t *testing.T) {
 			VehiclePositionUrl: "http://nonexistent.local/gtfs-rt",
 		}
 
-		_, err := CountVehiclePositions(server)
+		_, err := CountVehiclePositions(context.Background(), nil, server)
 		if err == nil {
 			t.Fatal("Expected an error, got nil")
 		}
@@ -47,7 +66,7 @@ func TestCountVehiclePositions(t *testing.T) {
 			VehiclePositionUrl: "://invalid-url",
 		}
 
-		_, err := CountVehiclePositions(server)
+		_, err := CountVehiclePositions(context.Background(), nil, server)
 		if err == nil {
 			t.Fatal("Expected an error due to invalid URL, got nil")
 		}
@@ -67,7 +86,7 @@ func TestVehiclesForAgencyAPI(t *testing.T) {
 			AgencyID:   "test-agency",
 		}
 
-		count, err := VehiclesForAgencyAPI(server)
+		count, err := VehiclesForAgencyAPI(context.Background(), server)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
@@ -89,7 +108,7 @@ func TestVehiclesForAgencyAPI(t *testing.T) {
 			AgencyID:   "test-agency",
 		}
 
-		count, err := VehiclesForAgencyAPI(server)
+		count, err := VehiclesForAgencyAPI(context.Background(), server)
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
@@ -111,7 +130,7 @@ func TestVehiclesForAgencyAPI(t *testing.T) {
 			AgencyID:   "test-agency",
 		}
 
-		_, err := VehiclesForAgencyAPI(server)
+		_, err := VehiclesForAgencyAPI(context.Background(), server)
 		if err == nil {
 			t.Fatal("Expected an error but got nil")
 		}
@@ -129,7 +148,7 @@ func TestCheckVehicleCountMatch(t *testing.T) {
 
 		testServer := createTestServer(obaServer.URL, "Test Server", 999, "test-key", gtfsRtServer.URL, "test-api-value", "test-api-key", "1")
 
-		err := CheckVehicleCountMatch(testServer)
+		err := CheckVehicleCountMatch(context.Background(), nil, testServer)
 		if err != nil {
 			t.Fatalf("CheckVehicleCountMatch failed: %v", err)
 		}
@@ -151,7 +170,7 @@ func TestCheckVehicleCountMatch(t *testing.T) {
 
 		testServer := createTestServer("http://example.com", "Test Server", 999, "test-key", gtfsRtServer.URL, "test-api-value", "test-api-key", "1")
 
-		err := CheckVehicleCountMatch(testServer)
+		err := CheckVehicleCountMatch(context.Background(), nil, testServer)
 		if err == nil {
 			t.Fatal("Expected an error but got nil")
 		}
@@ -167,10 +186,69 @@ func TestCheckVehicleCountMatch(t *testing.T) {
 
 		testServer := createTestServer(obaServer.URL, "Test Server", 999, "test-key", gtfsRtServer.URL, "test-api-value", "test-api-key", "1")
 
-		err := CheckVehicleCountMatch(testServer)
+		err := CheckVehicleCountMatch(context.Background(), nil, testServer)
 		if err == nil {
 			t.Fatal("Expected an error but got nil")
 		}
 		t.Log("Received expected error:", err)
 	})
 }
+
+func TestCheckVehicleCountMatchMultipleAgencies(t *testing.T) {
+	sharedFeed := setupGtfsRtFeedServer(t, vehiclePositionsFeed("a", "b", "c"))
+	ownFeed := setupGtfsRtFeedServer(t, vehiclePositionsFeed("x"))
+
+	// Agencies 1 and 3 share a feed with three vehicles; agency 40 has its own feed with one.
+	vehicles := map[string]int{"1": 2, "3": 1, "40": 2}
+	obaServer := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agencyID := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".json")
+		list := make([]string, vehicles[agencyID])
+		for i := range list {
+			list[i] = fmt.Sprintf(`{"vehicleId":"%s_%d"}`, agencyID, i)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[%s]}}`, strings.Join(list, ","))
+	}))
+
+	server := models.ObaServer{
+		ID:                 1301,
+		ObaBaseURL:         obaServer.URL,
+		ObaApiKey:          "test-key",
+		VehiclePositionUrl: sharedFeed.URL,
+		Agencies: []models.Agency{
+			{ID: "1"},
+			{ID: "3"},
+			{ID: "40", VehiclePositionUrl: ownFeed.URL},
+		},
+	}
+
+	if err := CheckVehicleCountMatch(context.Background(), nil, server); err != nil {
+		t.Fatalf("CheckVehicleCountMatch failed: %v", err)
+	}
+
+	for agencyID, want := range map[string]float64{"1": 1, "3": 1, "40": 0} {
+		match, err := getMetricValue(VehicleCountMatch, map[string]string{"agency_id": agencyID, "server_id": "1301"})
+		if err != nil {
+			t.Fatalf("Failed to get VehicleCountMatch for agency %s: %v", agencyID, err)
+		}
+		if match != want {
+			t.Errorf("Expected vehicle count match %v for agency %s, got %v", want, agencyID, match)
+		}
+	}
+
+	count, err := getMetricValue(VehicleCountAPI, map[string]string{"agency_id": "40", "server_id": "1301"})
+	if err != nil {
+		t.Fatalf("Failed to get VehicleCountAPI: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 vehicles from the API for agency 40, got %v", count)
+	}
+
+	total, err := CountVehiclePositions(context.Background(), nil, server)
+	if err != nil {
+		t.Fatalf("CountVehiclePositions failed: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("Expected 4 vehicle positions across both feeds, got %d", total)
+	}
+}
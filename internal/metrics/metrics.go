@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -26,6 +28,11 @@ var (
 		Name: "gtfs_bundle_days_until_latest_expiration",
 		Help: "Number of days until the latest GTFS bundle expiration",
 	}, []string{"server_id"})
+
+	BundleServiceCoverageGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_days_of_service_coverage",
+		Help: "Number of days until the last date on which any trip of the GTFS bundle runs",
+	}, []string{"server_id"})
 )
 
 var (
@@ -41,8 +48,23 @@ var (
 
 	AgenciesMatch = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "oba_agencies_match",
-		Help: "Whether the number of agencies in the static GTFS file matches the agencies-with-coverage endpoint (1 = match, 0 = no match)",
+		Help: "Whether the agency IDs in the static GTFS file match the agencies-with-coverage endpoint (1 = match, 0 = no match)",
 	}, []string{"server_id"})
+
+	AgencyMissingFromCoverage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_agency_missing_from_coverage",
+		Help: "Whether an agency of the static GTFS file is missing from the agencies-with-coverage endpoint (1 = missing, 0 = listed)",
+	}, []string{"agency_id", "server_id"})
+
+	AgencyMissingFromStaticGtfs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_agency_missing_from_static_gtfs",
+		Help: "Whether an agency of the agencies-with-coverage endpoint is missing from the static GTFS file (1 = missing, 0 = present)",
+	}, []string{"agency_id", "server_id"})
+
+	AgencyInCoverage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_agency_in_coverage",
+		Help: "Whether a configured agency is listed by the agencies-with-coverage endpoint (1 = listed, 0 = missing)",
+	}, []string{"agency_id", "server_id"})
 )
 
 var (
@@ -61,3 +83,336 @@ var (
 		Help: "Whether the number of vehicles in the API response matches the number of vehicles in the static GTFS-RT file (1 = match, 0 = no match)",
 	}, []string{"agency_id", "server_id"})
 )
+
+var (
+	VehicleMatchScore = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vehicle_reconciliation_match_score",
+		Help: "Share of vehicles present in both the GTFS-RT feed and the OBA API with agreeing position and trip (1 = all agree)",
+	}, []string{"server_id"})
+
+	VehiclesMissingFromAPI = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vehicle_reconciliation_missing_from_api",
+		Help: "Number of vehicles in the GTFS-RT feed that the OBA API does not report",
+	}, []string{"server_id"})
+
+	VehiclesMissingFromGtfsRt = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vehicle_reconciliation_missing_from_gtfs_rt",
+		Help: "Number of vehicles reported by the OBA API that are not in the GTFS-RT feed",
+	}, []string{"server_id"})
+
+	VehiclePositionMismatches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vehicle_reconciliation_position_mismatches",
+		Help: "Number of vehicles whose GTFS-RT and OBA API positions are further apart than the server's tolerance",
+	}, []string{"server_id"})
+
+	VehicleTripMismatches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vehicle_reconciliation_trip_mismatches",
+		Help: "Number of vehicles assigned to different trips in the GTFS-RT feed and the OBA API",
+	}, []string{"server_id"})
+)
+
+var (
+	ArrivalsRealtimeRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_arrivals_realtime_ratio",
+		Help: "Share of arrivals at the probed stops that carry a realtime prediction",
+	}, []string{"server_id"})
+
+	ArrivalsEmptyResponseRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_arrivals_empty_response_ratio",
+		Help: "Share of probed stops for which arrivals-and-departures-for-stop returned no arrivals",
+	}, []string{"server_id"})
+
+	ArrivalsProbeLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_arrivals_probe_latency_seconds",
+		Help: "Mean latency of the arrivals-and-departures-for-stop requests of the last probe",
+	}, []string{"server_id"})
+)
+
+var (
+	TripUpdatesCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trip_updates_count_gtfs_rt",
+		Help: "Number of trip updates in the GTFS-RT feed",
+	}, []string{"server_id"})
+
+	TripUpdatesDelayRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trip_updates_delay_ratio",
+		Help: "Share of stop time updates in the GTFS-RT trip updates feed that carry a delay",
+	}, []string{"server_id"})
+
+	TripUpdatesAbsoluteTimeRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trip_updates_absolute_time_ratio",
+		Help: "Share of stop time updates in the GTFS-RT trip updates feed that carry an absolute time",
+	}, []string{"server_id"})
+
+	TripUpdatesUnknownTrips = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trip_updates_unknown_trip_ids",
+		Help: "Number of trip updates whose trip ID does not exist in the static GTFS bundle",
+	}, []string{"server_id"})
+)
+
+var (
+	TripsInProgress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trips_scheduled_in_progress",
+		Help: "Number of trips of the static GTFS bundle scheduled to be in progress",
+	}, []string{"server_id"})
+
+	TripCoverageRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_trip_coverage_ratio",
+		Help: "Share of the trips scheduled to be in progress that appear in the GTFS-RT vehicle positions, trip updates or any feed",
+	}, []string{"server_id", "source"})
+
+	RouteTripCoverageRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_route_trip_coverage_ratio",
+		Help: "Share of the trips of a route scheduled to be in progress that have a vehicle position or trip update",
+	}, []string{"server_id", "route_id"})
+)
+
+var (
+	ServiceAlertsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_service_alerts_active",
+		Help: "Number of active alerts in the GTFS-RT service alerts feeds by cause and effect",
+	}, []string{"server_id", "cause", "effect"})
+
+	ServiceAlertsUnknownEntities = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_service_alerts_unknown_entities",
+		Help: "Number of stops, routes and trips informed by service alerts that are missing from the static GTFS bundle",
+	}, []string{"server_id", "entity_type"})
+
+	ServiceAlertsAPICount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_service_alerts_count",
+		Help: "Number of distinct situations the OBA API references in vehicles-for-agency",
+	}, []string{"server_id"})
+)
+
+var (
+	RealtimeFeedAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_vehicle_positions_feed_age_seconds",
+		Help: "Seconds since the timestamp in the GTFS-RT vehicle positions feed header",
+	}, []string{"server_id"})
+
+	RealtimeOldestVehicleAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_vehicle_positions_oldest_age_seconds",
+		Help: "Age in seconds of the oldest vehicle position in the GTFS-RT feed",
+	}, []string{"server_id"})
+
+	RealtimeMedianVehicleAge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_vehicle_positions_median_age_seconds",
+		Help: "Median age in seconds of the vehicle positions in the GTFS-RT feed",
+	}, []string{"server_id"})
+
+	RealtimeStaleVehicles = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_vehicle_positions_stale_count",
+		Help: "Number of vehicle positions older than the server's stale threshold",
+	}, []string{"server_id"})
+)
+
+var (
+	BundleValidationFindings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_validation_findings",
+		Help: "Number of occurrences of each validation finding in the current GTFS bundle",
+	}, []string{"server_id", "severity", "code"})
+)
+
+var (
+	RealtimeFeedUnchanged = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_feed_seconds_since_content_change",
+		Help: "Seconds since the content of the longest unchanged GTFS-RT feed of each type last changed",
+	}, []string{"server_id", "feed_type"})
+)
+
+var (
+	VehiclesImplausible = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_vehicles_implausible",
+		Help: "Number of vehicles in the GTFS-RT vehicle positions feeds with an implausible position, by reason",
+	}, []string{"server_id", "reason"})
+)
+
+var (
+	RealtimeValidationFindings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "realtime_validation_findings",
+		Help: "Number of occurrences of each GTFS-RT conformance finding across the realtime feeds of a server",
+	}, []string{"server_id", "severity", "code"})
+)
+
+var (
+	BundleDiffRoutesAdded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_routes_added",
+		Help: "Number of routes added by the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffRoutesRemoved = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_routes_removed",
+		Help: "Number of routes removed by the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffRoutesRemovedRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_routes_removed_ratio",
+		Help: "Share of the routes of the previous GTFS bundle removed by the last bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffStopCountDelta = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_stop_count_delta",
+		Help: "Change in the number of stops caused by the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffTripsPerDayDelta = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_trips_per_day_delta",
+		Help: "Change in the mean number of trips scheduled per day over the next week caused by the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffServiceEndShiftDays = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_service_end_shift_days",
+		Help: "Number of days the end of the service date range moved with the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleDiffAgenciesChanged = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_diff_agencies_changed",
+		Help: "Number of agencies added or removed by the last GTFS bundle change",
+	}, []string{"server_id"})
+
+	BundleLastChange = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_last_change_timestamp_seconds",
+		Help: "Unix time at which the content of the GTFS bundle last changed",
+	}, []string{"server_id"})
+)
+
+var (
+	StaticCacheBundles = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gtfs_static_cache_bundles",
+		Help: "Number of distinct parsed GTFS bundles held in memory",
+	})
+
+	StaticCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gtfs_static_cache_bytes",
+		Help: "Estimated memory held by parsed GTFS bundles (uncompressed size of their files)",
+	})
+)
+
+var (
+	BundleDownloadDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_download_duration_seconds",
+		Help: "Duration of the last GTFS bundle download",
+	}, []string{"server_id"})
+
+	BundleSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_size_bytes",
+		Help: "Size of the cached GTFS bundle",
+	}, []string{"server_id"})
+
+	BundleLastSuccessfulDownload = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gtfs_bundle_last_successful_download_timestamp_seconds",
+		Help: "Unix time of the last GTFS bundle download that succeeded or confirmed the cached bundle is current",
+	}, []string{"server_id"})
+
+	BundleDownloadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gtfs_bundle_download_failures_total",
+		Help: "Number of failed GTFS bundle downloads",
+	}, []string{"server_id"})
+)
+
+var (
+	ServerAvailability = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_api_availability_percent",
+		Help: "Share of time the OBA API answered the ping check over a rolling window",
+	}, []string{"server_id", "window"})
+
+	ServerOutages = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_api_outages",
+		Help: "Number of outages of the OBA API over a rolling window",
+	}, []string{"server_id", "window"})
+
+	ServerMeanTimeToRecovery = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_api_mean_time_to_recovery_seconds",
+		Help: "Mean duration of the OBA API outages that recovered within a rolling window",
+	}, []string{"server_id", "window"})
+
+	ServerLongestOutage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oba_api_longest_outage_seconds",
+		Help: "Longest outage of the OBA API within a rolling window",
+	}, []string{"server_id", "window"})
+)
+
+var (
+	ObaApiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oba_api_request_duration_seconds",
+		Help:    "Latency of OBA API requests, including retried attempts",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"server_id", "endpoint"})
+
+	ObaApiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "oba_api_errors_total",
+		Help: "Failed OBA API requests by class: timeout, dns, tls, connection, http_4xx, http_5xx or decode",
+	}, []string{"server_id", "endpoint", "class"})
+)
+
+// serverVecs are the metric vectors labelled by server_id.
+var serverVecs = []interface {
+	DeletePartialMatch(labels prometheus.Labels) int
+}{
+	ObaApiStatus,
+	BundleEarliestExpirationGauge,
+	BundleLatestExpirationGauge,
+	BundleServiceCoverageGauge,
+	AgenciesInStaticGtfs,
+	AgenciesInCoverageEndpoint,
+	AgenciesMatch,
+	AgencyMissingFromCoverage,
+	AgencyMissingFromStaticGtfs,
+	AgencyInCoverage,
+	RealtimeVehiclePositions,
+	VehicleCountAPI,
+	VehicleCountMatch,
+	VehicleMatchScore,
+	VehiclesMissingFromAPI,
+	VehiclesMissingFromGtfsRt,
+	VehiclePositionMismatches,
+	VehicleTripMismatches,
+	ArrivalsRealtimeRatio,
+	ArrivalsEmptyResponseRatio,
+	ArrivalsProbeLatency,
+	TripUpdatesCount,
+	TripUpdatesDelayRatio,
+	TripUpdatesAbsoluteTimeRatio,
+	TripUpdatesUnknownTrips,
+	TripsInProgress,
+	TripCoverageRatio,
+	RouteTripCoverageRatio,
+	ServiceAlertsActive,
+	ServiceAlertsUnknownEntities,
+	ServiceAlertsAPICount,
+	RealtimeFeedAge,
+	RealtimeOldestVehicleAge,
+	RealtimeMedianVehicleAge,
+	RealtimeStaleVehicles,
+	BundleValidationFindings,
+	RealtimeFeedUnchanged,
+	VehiclesImplausible,
+	RealtimeValidationFindings,
+	BundleDiffRoutesAdded,
+	BundleDiffRoutesRemoved,
+	BundleDiffRoutesRemovedRatio,
+	BundleDiffStopCountDelta,
+	BundleDiffTripsPerDayDelta,
+	BundleDiffServiceEndShiftDays,
+	BundleDiffAgenciesChanged,
+	BundleLastChange,
+	BundleDownloadDuration,
+	BundleSize,
+	BundleLastSuccessfulDownload,
+	BundleDownloadFailures,
+	ServerAvailability,
+	ServerOutages,
+	ServerMeanTimeToRecovery,
+	ServerLongestOutage,
+	ObaApiRequestDuration,
+	ObaApiErrors,
+}
+
+// DeleteServerSeries deletes every series of the server, so that a server removed from
+// the configuration is no longer exported and no metric rule matches it.
+func DeleteServerSeries(serverID int) {
+	labels := prometheus.Labels{"server_id": strconv.Itoa(serverID)}
+	for _, vec := range serverVecs {
+		vec.DeletePartialMatch(labels)
+	}
+}
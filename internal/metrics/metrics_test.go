@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDeleteServerSeries(t *testing.T) {
+	ObaApiStatus.WithLabelValues("2601", "https://example.com").Set(1)
+	VehicleCountMatch.WithLabelValues("1", "2601").Set(1)
+	BundleDownloadFailures.WithLabelValues("2601").Inc()
+	ObaApiStatus.WithLabelValues("2602", "https://example.com").Set(1)
+
+	DeleteServerSeries(2601)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := false
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() != "server_id" {
+					continue
+				}
+				if label.GetValue() == "2601" {
+					t.Errorf("Expected no %s series of the removed server", family.GetName())
+				}
+				kept = kept || label.GetValue() == "2602"
+			}
+		}
+	}
+	if !kept {
+		t.Error("Expected the series of other servers to be kept")
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	onebusaway "github.com/OneBusAway/go-sdk"
+	"github.com/OneBusAway/go-sdk/option"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// Error classes counted in ObaApiErrors.
+const (
+	errorClassTimeout    = "timeout"
+	errorClassDNS        = "dns"
+	errorClassTLS        = "tls"
+	errorClassConnection = "connection"
+	errorClass4xx        = "http_4xx"
+	errorClass5xx        = "http_5xx"
+	errorClassDecode     = "decode"
+)
+
+// newObaClient creates an OBA API client for server that records the latency and
+// errors of every request it makes.
+func newObaClient(server models.ObaServer) *onebusaway.Client {
+	return onebusaway.NewClient(
+		option.WithAPIKey(server.ObaApiKey),
+		option.WithBaseURL(server.ObaBaseURL),
+		option.WithMiddleware(instrumentObaRequests(server)),
+	)
+}
+
+// instrumentObaRequests returns middleware timing each request attempt, including
+// retries, and counting failed attempts by class.
+func instrumentObaRequests(server models.ObaServer) option.Middleware {
+	serverID := strconv.Itoa(server.ID)
+
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		endpoint := obaEndpoint(req.URL.Path)
+
+		start := time.Now()
+		resp, err := next(req)
+		ObaApiRequestDuration.WithLabelValues(serverID, endpoint).Observe(time.Since(start).Seconds())
+
+		switch {
+		case err != nil:
+			ObaApiErrors.WithLabelValues(serverID, endpoint, classifyTransportError(err)).Inc()
+		case resp.StatusCode >= 500:
+			ObaApiErrors.WithLabelValues(serverID, endpoint, errorClass5xx).Inc()
+		case resp.StatusCode >= 400:
+			ObaApiErrors.WithLabelValues(serverID, endpoint, errorClass4xx).Inc()
+		}
+
+		return resp, err
+	}
+}
+
+// recordObaDecodeError counts err if the SDK failed to decode a successful response.
+// Transport and HTTP errors are already counted by the client middleware.
+func recordObaDecodeError(server models.ObaServer, endpoint string, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		ObaApiErrors.WithLabelValues(strconv.Itoa(server.ID), endpoint, errorClassDecode).Inc()
+	}
+}
+
+// obaEndpoint turns a request path like /api/where/vehicles-for-agency/1.json into
+// the endpoint name "vehicles-for-agency", keeping IDs out of the metric labels.
+func obaEndpoint(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "api/where/")
+	name, _, _ := strings.Cut(path, "/")
+	name = strings.TrimSuffix(name, ".json")
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+func classifyTransportError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorClassTimeout
+	case errors.As(err, &dnsErr):
+		return errorClassDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return errorClassTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errorClassTimeout
+	default:
+		return errorClassConnection
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestObaEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"/api/where/current-time.json":           "current-time",
+		"/api/where/vehicles-for-agency/40.json": "vehicles-for-agency",
+		"/api/where/agencies-with-coverage.json": "agencies-with-coverage",
+		"/":                                      "unknown",
+	}
+
+	for path, want := range tests {
+		if got := obaEndpoint(path); got != want {
+			t.Errorf("obaEndpoint(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Deadline", fmt.Errorf("request failed: %w", context.DeadlineExceeded), errorClassTimeout},
+		{"DNS", &net.DNSError{Err: "no such host", Name: "oba.invalid"}, errorClassDNS},
+		{"TLS", fmt.Errorf("tls: %w", x509.UnknownAuthorityError{}), errorClassTLS},
+		{"Refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, errorClassConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyTransportError(tt.err); got != tt.want {
+				t.Errorf("classifyTransportError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentObaRequests(t *testing.T) {
+	server := createTestServer("http://oba.example.com", "Instrumented", 801, "key", "", "", "", "1")
+	middleware := instrumentObaRequests(server)
+	req := httptest.NewRequest(http.MethodGet, "http://oba.example.com/api/where/current-time.json", nil)
+
+	respond := func(status int) func(*http.Request) (*http.Response, error) {
+		return func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}
+	}
+
+	middleware(req, respond(http.StatusOK))
+	middleware(req, respond(http.StatusServiceUnavailable))
+	middleware(req, respond(http.StatusUnauthorized))
+	middleware(req, func(*http.Request) (*http.Response, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: "oba.example.com"}
+	})
+
+	var m dto.Metric
+	if err := ObaApiRequestDuration.WithLabelValues("801", "current-time").(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 4 {
+		t.Errorf("Expected 4 observed requests, got %d", got)
+	}
+
+	for class, want := range map[string]float64{errorClass5xx: 1, errorClass4xx: 1, errorClassDNS: 1, errorClassTimeout: 0} {
+		if got := testutil.ToFloat64(ObaApiErrors.WithLabelValues("801", "current-time", class)); got != want {
+			t.Errorf("Expected %v %s errors, got %v", want, class, got)
+		}
+	}
+}
+
+func TestServerPingRecordsLatencyAndDecodeErrors(t *testing.T) {
+	ts := setupObaServer(t, `{"code":200,"data":`, http.StatusOK)
+	defer ts.Close()
+
+	server := createTestServer(ts.URL, "Broken JSON", 802, "test-key", "", "", "", "1")
+	if err := ServerPing(context.Background(), server); err == nil {
+		t.Fatal("Expected an error for a truncated response")
+	}
+
+	if got := testutil.ToFloat64(ObaApiErrors.WithLabelValues("802", "current-time", errorClassDecode)); got != 1 {
+		t.Errorf("Expected 1 decode error, got %v", got)
+	}
+	if n := testutil.CollectAndCount(ObaApiRequestDuration, "oba_api_request_duration_seconds"); n == 0 {
+		t.Error("Expected the request latency to be observed")
+	}
+}
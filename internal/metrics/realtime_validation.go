@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/validation"
+)
+
+// CheckRealtimeValidation fetches every GTFS-RT feed of the server, validates it
+// against the GTFS-RT specification and the cached static bundle, and exports the
+// number of occurrences of each finding, summed over the feeds. It returns one report
+// per feed; a feed that cannot be fetched or decoded fails the check.
+func CheckRealtimeValidation(ctx context.Context, staticData *gtfs.Static, server models.ObaServer, now time.Time) ([]*validation.RealtimeReport, error) {
+	feeds := server.RealtimeFeeds()
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("no realtime feeds configured for server %d", server.ID)
+	}
+
+	reports := make([]*validation.RealtimeReport, 0, len(feeds))
+	for _, feed := range feeds {
+		data, err := fetchGtfsRtData(ctx, feed)
+		if err != nil {
+			return nil, err
+		}
+		message, err := validation.ParseRealtime(data)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, validation.ValidateRealtime(feed.RedactedURL(), message, staticData, now))
+	}
+
+	serverID := strconv.Itoa(server.ID)
+	RealtimeValidationFindings.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+	counts := make(map[[2]string]int)
+	for _, report := range reports {
+		for _, finding := range report.Findings {
+			counts[[2]string{string(finding.Severity), finding.Code}] += finding.Count
+		}
+	}
+	for key, count := range counts {
+		RealtimeValidationFindings.WithLabelValues(serverID, key[0], key[1]).Set(float64(count))
+	}
+
+	return reports, nil
+}
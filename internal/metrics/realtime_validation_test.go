@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/validation"
+)
+
+func TestCheckRealtimeValidation(t *testing.T) {
+	staticData := loadStaticFixture(t, "gtfs.zip")
+	now := time.Unix(1736700000, 0)
+
+	tripID, routeID := staticData.Trips[0].ID, staticData.Routes[0].Id
+	vehicles := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0"), Timestamp: proto.Uint64(uint64(now.Unix()))},
+		Entity: []*gtfsrt.FeedEntity{
+			{Id: proto.String("1"), Vehicle: &gtfsrt.VehiclePosition{Trip: &gtfsrt.TripDescriptor{TripId: proto.String(tripID)}}},
+			{Id: proto.String("2"), Vehicle: &gtfsrt.VehiclePosition{}},
+		},
+	})
+	tripUpdates := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+		Entity: []*gtfsrt.FeedEntity{
+			{Id: proto.String("1"), TripUpdate: &gtfsrt.TripUpdate{Trip: &gtfsrt.TripDescriptor{TripId: proto.String("no-such-trip"), RouteId: proto.String(routeID)}}},
+			{Id: proto.String("1"), TripUpdate: &gtfsrt.TripUpdate{Trip: &gtfsrt.TripDescriptor{TripId: proto.String(tripID)}}},
+		},
+	})
+
+	server := models.ObaServer{
+		ID:                 2301,
+		VehiclePositionUrl: vehicles.URL,
+		TripUpdateUrl:      tripUpdates.URL,
+		AgencyID:           "40",
+	}
+
+	reports, err := CheckRealtimeValidation(context.Background(), staticData, server, now)
+	if err != nil {
+		t.Fatalf("CheckRealtimeValidation failed: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Feed != vehicles.URL || reports[1].Feed != tripUpdates.URL {
+		t.Fatalf("Expected a report per feed, got %+v", reports)
+	}
+	if reports[0].Count(validation.SeverityError) != 0 || reports[0].Count(validation.SeverityWarning) != 1 {
+		t.Errorf("Expected one warning for the vehicle positions feed, got %+v", reports[0].Findings)
+	}
+
+	expected := map[[2]string]float64{
+		{"warning", validation.CodeVehicleWithoutTrip}:   1,
+		{"error", validation.CodeMissingHeaderTimestamp}: 1,
+		{"error", validation.CodeDuplicateEntityID}:      1,
+		{"error", validation.CodeUnknownTrip}:            1,
+	}
+	for key, want := range expected {
+		got, err := getMetricValue(RealtimeValidationFindings, map[string]string{"server_id": "2301", "severity": key[0], "code": key[1]})
+		if err != nil || got != want {
+			t.Errorf("Expected %v %s findings, got %v (%v)", want, key[1], got, err)
+		}
+	}
+
+	t.Run("NoFeeds", func(t *testing.T) {
+		_, err := CheckRealtimeValidation(context.Background(), staticData, models.ObaServer{ID: 2302}, now)
+		if err == nil {
+			t.Error("Expected an error without realtime feeds")
+		}
+	})
+
+	t.Run("UndecodableFeed", func(t *testing.T) {
+		broken := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("not a protobuf"))
+		}))
+		_, err := CheckRealtimeValidation(context.Background(), staticData, models.ObaServer{ID: 2303, VehiclePositionUrl: broken.URL}, now)
+		if err == nil {
+			t.Error("Expected an error for a feed that cannot be decoded")
+		}
+	})
+}
@@ -2,31 +2,28 @@ package metrics
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
-	onebusaway "github.com/OneBusAway/go-sdk"
-	"github.com/OneBusAway/go-sdk/option"
 	"github.com/getsentry/sentry-go"
 	"watchdog.onebusaway.org/internal/models"
 )
 
-func ServerPing(server models.ObaServer) {
-	client := onebusaway.NewClient(
-		option.WithAPIKey(server.ObaApiKey),
-		option.WithBaseURL(server.ObaBaseURL),
-	)
+// ServerPing calls the current-time endpoint of the server and records whether it answered.
+func ServerPing(ctx context.Context, server models.ObaServer) error {
+	client := newObaClient(server)
 
-	ctx := context.Background()
 	response, err := client.CurrentTime.Get(ctx)
 
 	if err != nil {
+		recordObaDecodeError(server, "current-time", err)
 		sentry.CaptureException(err)
 		// Update status metric
 		ObaApiStatus.WithLabelValues(
 			strconv.Itoa(server.ID),
 			server.ObaBaseURL,
 		).Set(0)
-		return
+		return err
 	}
 
 	// Check response validity
@@ -35,10 +32,12 @@ func ServerPing(server models.ObaServer) {
 			strconv.Itoa(server.ID),
 			server.ObaBaseURL,
 		).Set(1)
-	} else {
-		ObaApiStatus.WithLabelValues(
-			strconv.Itoa(server.ID),
-			server.ObaBaseURL,
-		).Set(0)
+		return nil
 	}
+
+	ObaApiStatus.WithLabelValues(
+		strconv.Itoa(server.ID),
+		server.ObaBaseURL,
+	).Set(0)
+	return fmt.Errorf("current-time response is missing readableTime")
 }
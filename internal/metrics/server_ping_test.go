@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"net/http"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ func TestCheckServer(t *testing.T) {
 
 		testServer := createTestServer(ts.URL, "Test Server", 999, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		ServerPing(testServer)
+		ServerPing(context.Background(), testServer)
 		time.Sleep(100 * time.Millisecond)
 
 		metricValue, err := getMetricValue(ObaApiStatus, map[string]string{
@@ -35,7 +36,7 @@ func TestCheckServer(t *testing.T) {
 
 		testServer := createTestServer(ts.URL, "Test Server No Time", 998, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		ServerPing(testServer)
+		ServerPing(context.Background(), testServer)
 		time.Sleep(100 * time.Millisecond)
 
 		metricValue, err := getMetricValue(ObaApiStatus, map[string]string{
@@ -54,7 +55,7 @@ func TestCheckServer(t *testing.T) {
 	t.Run("HTTP request failure", func(t *testing.T) {
 		testServer := createTestServer("http://invalid.url", "Test Server Invalid", 997, "test-key", "http://example.com", "test-api-value", "test-api-key", "1")
 
-		ServerPing(testServer)
+		ServerPing(context.Background(), testServer)
 		time.Sleep(100 * time.Millisecond)
 
 		metricValue, err := getMetricValue(ObaApiStatus, map[string]string{
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	onebusaway "github.com/OneBusAway/go-sdk"
+	"github.com/jamespfennell/gtfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// ServiceAlertsSummary holds the figures derived from the GTFS-RT service alerts feeds of a server.
+type ServiceAlertsSummary struct {
+	Alerts int
+	Active int
+	// ActiveByCauseEffect counts the active alerts by lower case cause and effect.
+	ActiveByCauseEffect map[[2]string]int
+	// Unknown entity references, by kind, to stops, routes and trips missing from the static bundle.
+	UnknownStops  int
+	UnknownRoutes int
+	UnknownTrips  int
+	// UnknownAlertIDs lists the alerts with at least one unknown reference.
+	UnknownAlertIDs []string
+	// APISituations is the number of distinct situations the OBA API references for the agencies of the server.
+	APISituations int
+}
+
+// CheckServiceAlerts fetches the GTFS-RT service alerts feeds of the server, counts
+// the active alerts by cause and effect, looks up the stops, routes and trips they
+// inform in the cached static bundle and compares the number of alerts with the
+// situations the OBA API references in vehicles-for-agency. Unknown references and a
+// differing count are logged and exported but do not fail the check.
+func CheckServiceAlerts(ctx context.Context, feedContent *FeedContentTracker, staticData *gtfs.Static, logger *slog.Logger, server models.ObaServer, now time.Time) (ServiceAlertsSummary, error) {
+	feeds := server.ServiceAlertsFeeds()
+	if len(feeds) == 0 {
+		return ServiceAlertsSummary{}, fmt.Errorf("no service alerts URL configured for server %d", server.ID)
+	}
+
+	var alerts []gtfs.Alert
+	for _, feed := range feeds {
+		realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, feed)
+		if err != nil {
+			return ServiceAlertsSummary{}, fmt.Errorf("failed to fetch service alerts: %v", err)
+		}
+		alerts = append(alerts, realtimeData.Alerts...)
+	}
+
+	summary := summarizeServiceAlerts(alerts, staticData, now)
+
+	situations, err := apiSituationCount(ctx, server)
+	if err != nil {
+		return summary, fmt.Errorf("failed to fetch situations from the OBA API: %v", err)
+	}
+	summary.APISituations = situations
+
+	if len(summary.UnknownAlertIDs) > 0 {
+		logger.Warn("Service alerts reference stops, routes or trips missing from the static bundle",
+			"server_id", server.ID,
+			"alerts", sampleIDs(summary.UnknownAlertIDs, 5),
+			"stops", summary.UnknownStops,
+			"routes", summary.UnknownRoutes,
+			"trips", summary.UnknownTrips,
+		)
+	}
+	if summary.Active > 0 && summary.APISituations == 0 {
+		logger.Warn("Service alerts feed has active alerts but the OBA API reports none",
+			"server_id", server.ID,
+			"active", summary.Active,
+		)
+	}
+
+	serverID := strconv.Itoa(server.ID)
+	ServiceAlertsActive.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+	for key, count := range summary.ActiveByCauseEffect {
+		ServiceAlertsActive.WithLabelValues(serverID, key[0], key[1]).Set(float64(count))
+	}
+	ServiceAlertsUnknownEntities.WithLabelValues(serverID, "stop").Set(float64(summary.UnknownStops))
+	ServiceAlertsUnknownEntities.WithLabelValues(serverID, "route").Set(float64(summary.UnknownRoutes))
+	ServiceAlertsUnknownEntities.WithLabelValues(serverID, "trip").Set(float64(summary.UnknownTrips))
+	ServiceAlertsAPICount.WithLabelValues(serverID).Set(float64(summary.APISituations))
+
+	return summary, nil
+}
+
+// summarizeServiceAlerts counts the alerts active at now and the references of all
+// alerts that are missing from the static bundle.
+func summarizeServiceAlerts(alerts []gtfs.Alert, staticData *gtfs.Static, now time.Time) ServiceAlertsSummary {
+	stopIDs := make(map[string]bool, len(staticData.Stops))
+	for _, stop := range staticData.Stops {
+		stopIDs[stop.Id] = true
+	}
+	routeIDs := make(map[string]bool, len(staticData.Routes))
+	for _, route := range staticData.Routes {
+		routeIDs[route.Id] = true
+	}
+	tripIDs := make(map[string]bool, len(staticData.Trips))
+	for _, trip := range staticData.Trips {
+		tripIDs[trip.ID] = true
+	}
+
+	summary := ServiceAlertsSummary{
+		Alerts:              len(alerts),
+		ActiveByCauseEffect: make(map[[2]string]int),
+	}
+	for _, alert := range alerts {
+		if alertActive(alert, now) {
+			summary.Active++
+			key := [2]string{strings.ToLower(alert.Cause.String()), strings.ToLower(alert.Effect.String())}
+			summary.ActiveByCauseEffect[key]++
+		}
+
+		unknown := false
+		for _, entity := range alert.InformedEntities {
+			if entity.StopID != nil && !stopIDs[*entity.StopID] {
+				summary.UnknownStops++
+				unknown = true
+			}
+			if entity.RouteID != nil && !routeIDs[*entity.RouteID] {
+				summary.UnknownRoutes++
+				unknown = true
+			}
+			if entity.TripID != nil && entity.TripID.ID != "" && !tripIDs[entity.TripID.ID] {
+				summary.UnknownTrips++
+				unknown = true
+			}
+		}
+		if unknown {
+			summary.UnknownAlertIDs = append(summary.UnknownAlertIDs, alert.ID)
+		}
+	}
+
+	return summary
+}
+
+// alertActive reports whether one of the active periods of the alert contains now.
+// Alerts without active periods are always active.
+func alertActive(alert gtfs.Alert, now time.Time) bool {
+	if len(alert.ActivePeriods) == 0 {
+		return true
+	}
+	for _, period := range alert.ActivePeriods {
+		if period.StartsAt != nil && now.Before(*period.StartsAt) {
+			continue
+		}
+		if period.EndsAt != nil && !now.Before(*period.EndsAt) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// apiSituationCount counts the distinct situations referenced by vehicles-for-agency
+// for the agencies of the server. OBA has no endpoint listing all situations of an
+// agency, so alerts that affect no trip with a vehicle are not included.
+func apiSituationCount(ctx context.Context, server models.ObaServer) (int, error) {
+	client := newObaClient(server)
+
+	situations := make(map[string]bool)
+	for _, agency := range server.AgencyList() {
+		response, err := client.VehiclesForAgency.List(ctx, agency.ID, onebusaway.VehiclesForAgencyListParams{})
+		if err != nil {
+			recordObaDecodeError(server, "vehicles-for-agency", err)
+			return 0, err
+		}
+		if response == nil {
+			continue
+		}
+		for _, situation := range response.Data.References.Situations {
+			situations[situation.ID] = true
+		}
+	}
+	return len(situations), nil
+}
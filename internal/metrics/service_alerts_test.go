@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func alertEntity(id string, cause gtfsrt.Alert_Cause, effect gtfsrt.Alert_Effect, period *gtfsrt.TimeRange, entities ...*gtfsrt.EntitySelector) *gtfsrt.FeedEntity {
+	alert := &gtfsrt.Alert{
+		Cause:          cause.Enum(),
+		Effect:         effect.Enum(),
+		InformedEntity: entities,
+	}
+	if period != nil {
+		alert.ActivePeriod = []*gtfsrt.TimeRange{period}
+	}
+	return &gtfsrt.FeedEntity{Id: proto.String(id), Alert: alert}
+}
+
+func TestCheckServiceAlerts(t *testing.T) {
+	staticData := loadStaticFixture(t, "gtfs.zip")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Unix(1736700000, 0)
+
+	stopID, routeID, tripID := staticData.Stops[0].Id, staticData.Routes[0].Id, staticData.Trips[0].ID
+	feed := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+		Entity: []*gtfsrt.FeedEntity{
+			alertEntity("detour", gtfsrt.Alert_CONSTRUCTION, gtfsrt.Alert_DETOUR, nil,
+				&gtfsrt.EntitySelector{RouteId: proto.String(routeID)},
+				&gtfsrt.EntitySelector{StopId: proto.String(stopID)},
+			),
+			alertEntity("cancelled", gtfsrt.Alert_STRIKE, gtfsrt.Alert_NO_SERVICE,
+				&gtfsrt.TimeRange{Start: proto.Uint64(uint64(now.Unix() - 60)), End: proto.Uint64(uint64(now.Unix() + 60))},
+				&gtfsrt.EntitySelector{Trip: &gtfsrt.TripDescriptor{TripId: proto.String(tripID)}},
+			),
+			alertEntity("stale", gtfsrt.Alert_WEATHER, gtfsrt.Alert_SIGNIFICANT_DELAYS,
+				&gtfsrt.TimeRange{End: proto.Uint64(uint64(now.Unix() - 60))},
+				&gtfsrt.EntitySelector{StopId: proto.String("no-such-stop")},
+				&gtfsrt.EntitySelector{RouteId: proto.String("no-such-route")},
+				&gtfsrt.EntitySelector{Trip: &gtfsrt.TripDescriptor{TripId: proto.String("no-such-trip")}},
+			),
+		},
+	})
+
+	situations := `{"id":"1_detour"},{"id":"1_cancelled"}`
+	obaServer := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[],"references":{"agencies":[],"routes":[],"situations":[%s],"stops":[],"stopTimes":[],"trips":[]}}}`, situations)
+	}))
+
+	server := models.ObaServer{
+		ID:               2101,
+		ObaBaseURL:       obaServer.URL,
+		ObaApiKey:        "test-key",
+		ServiceAlertsUrl: feed.URL,
+		AgencyID:         "1",
+	}
+
+	summary, err := CheckServiceAlerts(context.Background(), nil, staticData, logger, server, now)
+	if err != nil {
+		t.Fatalf("CheckServiceAlerts failed: %v", err)
+	}
+
+	if summary.Alerts != 3 || summary.Active != 2 {
+		t.Errorf("Expected 3 alerts of which 2 are active, got %+v", summary)
+	}
+	if summary.UnknownStops != 1 || summary.UnknownRoutes != 1 || summary.UnknownTrips != 1 {
+		t.Errorf("Expected one unknown stop, route and trip, got %+v", summary)
+	}
+	if len(summary.UnknownAlertIDs) != 1 || summary.UnknownAlertIDs[0] != "stale" {
+		t.Errorf("Expected only the stale alert to have unknown references, got %v", summary.UnknownAlertIDs)
+	}
+	if summary.APISituations != 2 {
+		t.Errorf("Expected 2 situations from the API, got %d", summary.APISituations)
+	}
+
+	detours, _ := getMetricValue(ServiceAlertsActive, map[string]string{"server_id": "2101", "cause": "construction", "effect": "detour"})
+	if detours != 1 {
+		t.Errorf("Expected 1 active construction detour, got %v", detours)
+	}
+	unknownTrips, _ := getMetricValue(ServiceAlertsUnknownEntities, map[string]string{"server_id": "2101", "entity_type": "trip"})
+	if unknownTrips != 1 {
+		t.Errorf("Expected 1 unknown trip, got %v", unknownTrips)
+	}
+
+	t.Run("NoFeed", func(t *testing.T) {
+		if _, err := CheckServiceAlerts(context.Background(), nil, staticData, logger, models.ObaServer{ID: 2102}, now); err == nil {
+			t.Error("Expected an error without a service alerts URL")
+		}
+	})
+}
@@ -5,10 +5,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/bundle"
 	"watchdog.onebusaway.org/internal/models"
 )
 
@@ -25,6 +30,37 @@ func getFixturePath(t *testing.T, fixturePath string) string {
 	return absPath
 }
 
+var (
+	bundleFixturesMu sync.Mutex
+	bundleFixtures   = map[string]*bundle.Bundle{}
+)
+
+// loadBundleFixture parses a static GTFS fixture once and shares it between tests.
+func loadBundleFixture(t *testing.T, fixturePath string) *bundle.Bundle {
+	t.Helper()
+
+	bundleFixturesMu.Lock()
+	defer bundleFixturesMu.Unlock()
+
+	if b, ok := bundleFixtures[fixturePath]; ok {
+		return b
+	}
+
+	b, err := bundle.Load(getFixturePath(t, fixturePath))
+	if err != nil {
+		t.Fatalf("Failed to parse static GTFS fixture %s: %v", fixturePath, err)
+	}
+	bundleFixtures[fixturePath] = b
+
+	return b
+}
+
+// loadStaticFixture returns the parsed static data of a shared GTFS fixture.
+func loadStaticFixture(t *testing.T, fixturePath string) *gtfs.Static {
+	t.Helper()
+	return loadBundleFixture(t, fixturePath).Static
+}
+
 func createTestServer(url, name string, id int, apiKey string, vehiclePositionUrl string, gtfsRtApiKey string, gtfsRtApiValue string, agencyID string) models.ObaServer {
 	return models.ObaServer{
 		Name:               name,
@@ -87,6 +123,21 @@ func setupGtfsRtServer(t *testing.T, fixturePath string) *httptest.Server {
 	}))
 }
 
+// setupGtfsRtFeedServer serves the given feed message encoded as protobuf.
+func setupGtfsRtFeedServer(t *testing.T, feed *gtfsrt.FeedMessage) *httptest.Server {
+	t.Helper()
+
+	data, err := proto.Marshal(feed)
+	if err != nil {
+		t.Fatalf("Failed to marshal GTFS-RT feed: %v", err)
+	}
+
+	return setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	}))
+}
+
 func setupTestServer(t *testing.T, handler http.Handler) *httptest.Server {
 	t.Helper()
 	ts := httptest.NewServer(handler)
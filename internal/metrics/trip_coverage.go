@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// RouteTripCoverage counts the trips of one route that are in progress and how many
+// of them have realtime data.
+type RouteTripCoverage struct {
+	RouteID        string
+	Scheduled      int
+	WithVehicle    int
+	WithTripUpdate int
+	// Covered counts the trips with a vehicle position, a trip update or both.
+	Covered int
+}
+
+// Ratio is the share of the trips in progress with realtime data, or -1 without trips.
+func (c RouteTripCoverage) Ratio() float64 {
+	if c.Scheduled == 0 {
+		return -1
+	}
+	return float64(c.Covered) / float64(c.Scheduled)
+}
+
+// TripCoverage is the realtime coverage of all trips in progress, and of each route.
+type TripCoverage struct {
+	RouteTripCoverage
+	// Routes holds the routes with trips in progress, sorted by route ID.
+	Routes []RouteTripCoverage
+}
+
+// CheckTripCoverage computes which trips of the cached static bundle are scheduled to
+// be in progress at now and how many of them appear in the vehicle positions and trip
+// updates feeds of the server, in total and per route. It returns an error when the
+// share of trips with realtime data is below the server's trip coverage threshold.
+func CheckTripCoverage(ctx context.Context, feedContent *FeedContentTracker, b *bundle.Bundle, server models.ObaServer, now time.Time) (TripCoverage, error) {
+	vehicleTrips, tripUpdateTrips, err := realtimeTripIDs(ctx, feedContent, server)
+	if err != nil {
+		return TripCoverage{}, err
+	}
+
+	coverage := computeTripCoverage(ScheduledTripsInProgress(b, now), vehicleTrips, tripUpdateTrips)
+
+	serverID := strconv.Itoa(server.ID)
+	TripsInProgress.WithLabelValues(serverID).Set(float64(coverage.Scheduled))
+	RouteTripCoverageRatio.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+	if coverage.Scheduled == 0 {
+		TripCoverageRatio.DeletePartialMatch(prometheus.Labels{"server_id": serverID})
+		return coverage, nil
+	}
+
+	scheduled := float64(coverage.Scheduled)
+	TripCoverageRatio.WithLabelValues(serverID, "vehicle_positions").Set(float64(coverage.WithVehicle) / scheduled)
+	TripCoverageRatio.WithLabelValues(serverID, "trip_updates").Set(float64(coverage.WithTripUpdate) / scheduled)
+	TripCoverageRatio.WithLabelValues(serverID, "any").Set(coverage.Ratio())
+	for _, route := range coverage.Routes {
+		RouteTripCoverageRatio.WithLabelValues(serverID, route.RouteID).Set(route.Ratio())
+	}
+
+	if threshold := server.TripCoverageThreshold(); coverage.Ratio() < threshold {
+		var uncovered []string
+		for _, route := range coverage.Routes {
+			if route.Covered == 0 {
+				uncovered = append(uncovered, route.RouteID)
+			}
+		}
+		err := fmt.Errorf("realtime data covers %.0f%% of %d trips in progress, below %.0f%%",
+			coverage.Ratio()*100, coverage.Scheduled, threshold*100)
+		if len(uncovered) > 0 {
+			err = fmt.Errorf("%v; routes without realtime data: %s", err, sampleIDs(uncovered, 10))
+		}
+		return coverage, err
+	}
+	return coverage, nil
+}
+
+// realtimeTripIDs returns the trip IDs with a vehicle position and the trip IDs with a
+// trip update across the feeds of the server. Feeds used for both are fetched once.
+func realtimeTripIDs(ctx context.Context, feedContent *FeedContentTracker, server models.ObaServer) (vehicleTrips, tripUpdateTrips map[string]bool, err error) {
+	vehicleFeeds := server.VehiclePositionFeeds()
+	tripUpdateFeeds := server.TripUpdateFeeds()
+	if len(vehicleFeeds) == 0 && len(tripUpdateFeeds) == 0 {
+		return nil, nil, fmt.Errorf("no realtime feeds configured for server %d", server.ID)
+	}
+
+	feeds := make(map[models.GtfsRtFeed]*gtfs.Realtime)
+	fetch := func(feed models.GtfsRtFeed) (*gtfs.Realtime, error) {
+		if realtimeData, ok := feeds[feed]; ok {
+			return realtimeData, nil
+		}
+		realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, feed)
+		if err != nil {
+			return nil, err
+		}
+		feeds[feed] = realtimeData
+		return realtimeData, nil
+	}
+
+	vehicleTrips = make(map[string]bool)
+	for _, feed := range vehicleFeeds {
+		realtimeData, err := fetch(feed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch vehicle positions: %v", err)
+		}
+		for _, vehicle := range realtimeData.Vehicles {
+			if vehicle.Trip != nil && vehicle.Trip.ID.ID != "" {
+				vehicleTrips[vehicle.Trip.ID.ID] = true
+			}
+		}
+	}
+
+	tripUpdateTrips = make(map[string]bool)
+	for _, feed := range tripUpdateFeeds {
+		realtimeData, err := fetch(feed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch trip updates: %v", err)
+		}
+		for _, trip := range realtimeData.Trips {
+			if trip.IsEntityInMessage && trip.ID.ID != "" {
+				tripUpdateTrips[trip.ID.ID] = true
+			}
+		}
+	}
+
+	return vehicleTrips, tripUpdateTrips, nil
+}
+
+// computeTripCoverage matches the trips in progress with the realtime trip IDs.
+func computeTripCoverage(trips []*gtfs.ScheduledTrip, vehicleTrips, tripUpdateTrips map[string]bool) TripCoverage {
+	var coverage TripCoverage
+	routes := make(map[string]*RouteTripCoverage)
+	for _, trip := range trips {
+		routeID := ""
+		if trip.Route != nil {
+			routeID = trip.Route.Id
+		}
+		route, ok := routes[routeID]
+		if !ok {
+			route = &RouteTripCoverage{RouteID: routeID}
+			routes[routeID] = route
+		}
+
+		hasVehicle, hasTripUpdate := vehicleTrips[trip.ID], tripUpdateTrips[trip.ID]
+		for _, c := range []*RouteTripCoverage{&coverage.RouteTripCoverage, route} {
+			c.Scheduled++
+			if hasVehicle {
+				c.WithVehicle++
+			}
+			if hasTripUpdate {
+				c.WithTripUpdate++
+			}
+			if hasVehicle || hasTripUpdate {
+				c.Covered++
+			}
+		}
+	}
+
+	for _, route := range routes {
+		coverage.Routes = append(coverage.Routes, *route)
+	}
+	sort.Slice(coverage.Routes, func(i, j int) bool { return coverage.Routes[i].RouteID < coverage.Routes[j].RouteID })
+	return coverage
+}
+
+// ScheduledTripsInProgress returns the trips of the bundle that are scheduled to run at
+// now: between their first departure and last arrival, or within a frequency window,
+// on a service day on which their service is active. Service days are taken in the
+// timezone of the bundle's first agency, and trips of the previous service day that run
+// past midnight are included.
+func ScheduledTripsInProgress(b *bundle.Bundle, now time.Time) []*gtfs.ScheduledTrip {
+	staticData := b.Static
+	timezone := time.UTC
+	if len(staticData.Agencies) > 0 {
+		if location, err := time.LoadLocation(staticData.Agencies[0].Timezone); err == nil {
+			timezone = location
+		}
+	}
+
+	local := now.In(timezone)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, timezone)
+	serviceDays := []time.Time{today, today.AddDate(0, 0, -1)}
+
+	active := make(map[string][]bool, len(staticData.Services))
+	for _, service := range staticData.Services {
+		days := make([]bool, len(serviceDays))
+		for i, day := range serviceDays {
+			days[i] = bundle.ServiceActiveOn(service, b.Calendars, day)
+		}
+		active[service.Id] = days
+	}
+
+	var trips []*gtfs.ScheduledTrip
+	for i := range staticData.Trips {
+		trip := &staticData.Trips[i]
+		if trip.Service == nil || len(trip.StopTimes) == 0 {
+			continue
+		}
+		start, end := tripSpan(trip)
+		for j, day := range serviceDays {
+			if !active[trip.Service.Id][j] {
+				continue
+			}
+			elapsed := local.Sub(serviceDayOrigin(day))
+			if elapsed >= start && elapsed <= end {
+				trips = append(trips, trip)
+				break
+			}
+		}
+	}
+	return trips
+}
+
+// serviceDayOrigin returns the time the times of stop_times.txt are relative to on the
+// service day: noon minus 12 hours, which is midnight except on days with a daylight
+// saving time change.
+func serviceDayOrigin(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, day.Location()).Add(-12 * time.Hour)
+}
+
+// tripSpan returns the time of day the trip starts and ends. For frequency based trips
+// the span runs from the start of the first window until the last trip started in the
+// last window has finished.
+func tripSpan(trip *gtfs.ScheduledTrip) (time.Duration, time.Duration) {
+	first, last := trip.StopTimes[0], trip.StopTimes[len(trip.StopTimes)-1]
+	start, end := first.DepartureTime, last.ArrivalTime
+	if end < start {
+		end = start
+	}
+	if len(trip.Frequencies) == 0 {
+		return start, end
+	}
+
+	duration := end - start
+	start, end = trip.Frequencies[0].StartTime, trip.Frequencies[0].EndTime
+	for _, frequency := range trip.Frequencies[1:] {
+		start = min(start, frequency.StartTime)
+		end = max(end, frequency.EndTime)
+	}
+	return start, end + duration
+}
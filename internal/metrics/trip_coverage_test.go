@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/bundle"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// coverageBundle returns a bundle with weekday trips on two routes, one of them running
+// past midnight. Tuesday, March 4th 2025 has no service.
+func coverageBundle(t *testing.T) *bundle.Bundle {
+	t.Helper()
+
+	timezone, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(month time.Month, day int) time.Time { return time.Date(2025, month, day, 0, 0, 0, 0, timezone) }
+
+	service := &gtfs.Service{
+		Id:     "WK",
+		Monday: true, Tuesday: true, Wednesday: true, Thursday: true, Friday: true,
+		StartDate:    date(time.January, 1),
+		EndDate:      date(time.December, 31),
+		RemovedDates: []time.Time{date(time.March, 4)},
+	}
+	r1, r2 := &gtfs.Route{Id: "R1"}, &gtfs.Route{Id: "R2"}
+	trip := func(id string, route *gtfs.Route, start, end time.Duration) gtfs.ScheduledTrip {
+		return gtfs.ScheduledTrip{
+			ID:      id,
+			Route:   route,
+			Service: service,
+			StopTimes: []gtfs.ScheduledStopTime{
+				{ArrivalTime: start, DepartureTime: start, StopSequence: 1},
+				{ArrivalTime: end, DepartureTime: end, StopSequence: 2},
+			},
+		}
+	}
+
+	return &bundle.Bundle{Static: &gtfs.Static{
+		Agencies: []gtfs.Agency{{Id: "1", Timezone: "America/Los_Angeles"}},
+		Routes:   []gtfs.Route{*r1, *r2},
+		Services: []gtfs.Service{*service},
+		Trips: []gtfs.ScheduledTrip{
+			trip("T1", r1, 8*time.Hour, 9*time.Hour),
+			trip("T2", r1, 8*time.Hour+30*time.Minute, 9*time.Hour+30*time.Minute),
+			trip("T3", r2, 8*time.Hour+15*time.Minute, 8*time.Hour+45*time.Minute),
+			trip("T4", r2, 23*time.Hour+30*time.Minute, 25*time.Hour+30*time.Minute),
+			trip("T5", r1, 10*time.Hour, 11*time.Hour),
+		},
+	}}
+}
+
+func tripIDs(trips []*gtfs.ScheduledTrip) string {
+	ids := make([]string, len(trips))
+	for i, trip := range trips {
+		ids[i] = trip.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestScheduledTripsInProgress(t *testing.T) {
+	b := coverageBundle(t)
+	timezone, _ := time.LoadLocation("America/Los_Angeles")
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected string
+	}{
+		{"MondayMorning", time.Date(2025, 3, 3, 8, 40, 0, 0, timezone), "T1,T2,T3"},
+		{"PastMidnight", time.Date(2025, 3, 4, 0, 30, 0, 0, timezone), "T4"},
+		{"RemovedDate", time.Date(2025, 3, 4, 8, 40, 0, 0, timezone), ""},
+		{"UTCInput", time.Date(2025, 3, 3, 16, 40, 0, 0, time.UTC), "T1,T2,T3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tripIDs(ScheduledTripsInProgress(b, tt.now)); got != tt.expected {
+				t.Errorf("Expected trips %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestScheduledTripsInProgressOnDaylightSavingChanges(t *testing.T) {
+	timezone, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &gtfs.Service{
+		Id:     "DAILY",
+		Monday: true, Tuesday: true, Wednesday: true, Thursday: true, Friday: true, Saturday: true, Sunday: true,
+		StartDate: time.Date(2025, time.January, 1, 0, 0, 0, 0, timezone),
+		EndDate:   time.Date(2025, time.December, 31, 0, 0, 0, 0, timezone),
+	}
+	b := &bundle.Bundle{Static: &gtfs.Static{
+		Agencies: []gtfs.Agency{{Id: "1", Timezone: "America/Los_Angeles"}},
+		Services: []gtfs.Service{*service},
+		Trips: []gtfs.ScheduledTrip{{
+			ID:      "T1",
+			Service: service,
+			StopTimes: []gtfs.ScheduledStopTime{
+				{ArrivalTime: 8 * time.Hour, DepartureTime: 8 * time.Hour, StopSequence: 1},
+				{ArrivalTime: 9 * time.Hour, DepartureTime: 9 * time.Hour, StopSequence: 2},
+			},
+		}},
+	}}
+
+	// Clocks moved forward on March 9th 2025 and back on November 2nd 2025, and the
+	// trip still runs from 8:00 to 9:00 on the clock.
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected string
+	}{
+		{"SpringForwardBeforeTrip", time.Date(2025, 3, 9, 7, 50, 0, 0, timezone), ""},
+		{"SpringForwardDuringTrip", time.Date(2025, 3, 9, 8, 10, 0, 0, timezone), "T1"},
+		{"SpringForwardAfterTrip", time.Date(2025, 3, 9, 9, 10, 0, 0, timezone), ""},
+		{"FallBackBeforeTrip", time.Date(2025, 11, 2, 7, 50, 0, 0, timezone), ""},
+		{"FallBackDuringTrip", time.Date(2025, 11, 2, 8, 50, 0, 0, timezone), "T1"},
+		{"FallBackAfterTrip", time.Date(2025, 11, 2, 9, 10, 0, 0, timezone), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tripIDs(ScheduledTripsInProgress(b, tt.now)); got != tt.expected {
+				t.Errorf("Expected trips %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckTripCoverage(t *testing.T) {
+	b := coverageBundle(t)
+	timezone, _ := time.LoadLocation("America/Los_Angeles")
+	now := time.Date(2025, 3, 3, 8, 40, 0, 0, timezone)
+
+	vehicles := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+		Entity: []*gtfsrt.FeedEntity{{
+			Id: proto.String("v1"),
+			Vehicle: &gtfsrt.VehiclePosition{
+				Vehicle: &gtfsrt.VehicleDescriptor{Id: proto.String("v1")},
+				Trip:    &gtfsrt.TripDescriptor{TripId: proto.String("T1")},
+			},
+		}},
+	})
+	tripUpdates := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+		Entity: []*gtfsrt.FeedEntity{tripUpdateEntity("u1", "T2")},
+	})
+
+	server := models.ObaServer{ID: 2201, VehiclePositionUrl: vehicles.URL, TripUpdateUrl: tripUpdates.URL}
+
+	coverage, err := CheckTripCoverage(context.Background(), nil, b, server, now)
+	if err != nil {
+		t.Fatalf("CheckTripCoverage failed: %v", err)
+	}
+	if coverage.Scheduled != 3 || coverage.WithVehicle != 1 || coverage.WithTripUpdate != 1 || coverage.Covered != 2 {
+		t.Errorf("Unexpected coverage %+v", coverage.RouteTripCoverage)
+	}
+	if len(coverage.Routes) != 2 || coverage.Routes[0].Ratio() != 1 || coverage.Routes[1].Ratio() != 0 {
+		t.Errorf("Expected R1 to be covered and R2 not, got %+v", coverage.Routes)
+	}
+
+	anyRatio, _ := getMetricValue(TripCoverageRatio, map[string]string{"server_id": "2201", "source": "any"})
+	if anyRatio != 2.0/3.0 {
+		t.Errorf("Expected a coverage ratio of 2/3, got %v", anyRatio)
+	}
+	routeRatio, _ := getMetricValue(RouteTripCoverageRatio, map[string]string{"server_id": "2201", "route_id": "R2"})
+	if routeRatio != 0 {
+		t.Errorf("Expected no coverage of R2, got %v", routeRatio)
+	}
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		server := server
+		server.TripCoverageMinRatio = 0.9
+
+		_, err := CheckTripCoverage(context.Background(), nil, b, server, now)
+		if err == nil || !strings.Contains(err.Error(), "routes without realtime data: R2") {
+			t.Errorf("Expected an error naming route R2, got %v", err)
+		}
+	})
+
+	t.Run("NoFeeds", func(t *testing.T) {
+		if _, err := CheckTripCoverage(context.Background(), nil, b, models.ObaServer{ID: 2202}, now); err == nil {
+			t.Error("Expected an error without realtime feeds")
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// TripUpdatesSummary holds the figures derived from a single GTFS-RT trip updates feed.
+type TripUpdatesSummary struct {
+	TripUpdates       int
+	StopTimeUpdates   int
+	WithDelay         int
+	WithAbsoluteTime  int
+	UnknownTripIDs    int
+	UnknownTripIDList []string
+}
+
+// CheckTripUpdates fetches the GTFS-RT trip updates feeds of the server's agencies,
+// compares the trips they reference with the cached static bundle and exports the results.
+func CheckTripUpdates(ctx context.Context, feedContent *FeedContentTracker, staticData *gtfs.Static, logger *slog.Logger, server models.ObaServer) (TripUpdatesSummary, error) {
+	feeds := server.TripUpdateFeeds()
+	if len(feeds) == 0 {
+		return TripUpdatesSummary{}, fmt.Errorf("no trip updates URL configured for server %d", server.ID)
+	}
+
+	var summary TripUpdatesSummary
+	for _, feed := range feeds {
+		realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, feed)
+		if err != nil {
+			return TripUpdatesSummary{}, fmt.Errorf("failed to fetch trip updates: %v", err)
+		}
+		summary.add(summarizeTripUpdates(realtimeData, staticData))
+	}
+
+	if summary.UnknownTripIDs > 0 {
+		logger.Warn("Trip updates reference trips missing from the static bundle",
+			"server_id", server.ID,
+			"count", summary.UnknownTripIDs,
+		)
+	}
+
+	serverID := strconv.Itoa(server.ID)
+	TripUpdatesCount.WithLabelValues(serverID).Set(float64(summary.TripUpdates))
+	TripUpdatesUnknownTrips.WithLabelValues(serverID).Set(float64(summary.UnknownTripIDs))
+
+	delayRatio, timeRatio := 0.0, 0.0
+	if summary.StopTimeUpdates > 0 {
+		delayRatio = float64(summary.WithDelay) / float64(summary.StopTimeUpdates)
+		timeRatio = float64(summary.WithAbsoluteTime) / float64(summary.StopTimeUpdates)
+	}
+	TripUpdatesDelayRatio.WithLabelValues(serverID).Set(delayRatio)
+	TripUpdatesAbsoluteTimeRatio.WithLabelValues(serverID).Set(timeRatio)
+
+	return summary, nil
+}
+
+// add accumulates the figures of another feed into s.
+func (s *TripUpdatesSummary) add(other TripUpdatesSummary) {
+	s.TripUpdates += other.TripUpdates
+	s.StopTimeUpdates += other.StopTimeUpdates
+	s.WithDelay += other.WithDelay
+	s.WithAbsoluteTime += other.WithAbsoluteTime
+	s.UnknownTripIDs += other.UnknownTripIDs
+	s.UnknownTripIDList = append(s.UnknownTripIDList, other.UnknownTripIDList...)
+}
+
+// summarizeTripUpdates counts the trip updates in the realtime feed and how their
+// stop time events are expressed. Trips only referenced by vehicle positions are ignored.
+func summarizeTripUpdates(realtimeData *gtfs.Realtime, staticData *gtfs.Static) TripUpdatesSummary {
+	staticTripIDs := make(map[string]struct{}, len(staticData.Trips))
+	for _, trip := range staticData.Trips {
+		staticTripIDs[trip.ID] = struct{}{}
+	}
+
+	var summary TripUpdatesSummary
+	for _, trip := range realtimeData.Trips {
+		if !trip.IsEntityInMessage {
+			continue
+		}
+		summary.TripUpdates++
+
+		if trip.ID.ID != "" {
+			if _, ok := staticTripIDs[trip.ID.ID]; !ok {
+				summary.UnknownTripIDs++
+				summary.UnknownTripIDList = append(summary.UnknownTripIDList, trip.ID.ID)
+			}
+		}
+
+		for _, update := range trip.StopTimeUpdates {
+			summary.StopTimeUpdates++
+			arrival, departure := update.GetArrival(), update.GetDeparture()
+			if arrival.Delay != nil || departure.Delay != nil {
+				summary.WithDelay++
+			}
+			if arrival.Time != nil || departure.Time != nil {
+				summary.WithAbsoluteTime++
+			}
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func tripUpdateEntity(id, tripID string, updates ...*gtfsrt.TripUpdate_StopTimeUpdate) *gtfsrt.FeedEntity {
+	return &gtfsrt.FeedEntity{
+		Id: proto.String(id),
+		TripUpdate: &gtfsrt.TripUpdate{
+			Trip:           &gtfsrt.TripDescriptor{TripId: proto.String(tripID)},
+			StopTimeUpdate: updates,
+		},
+	}
+}
+
+func TestCheckTripUpdates(t *testing.T) {
+	staticData := loadStaticFixture(t, "gtfs.zip")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		feed := &gtfsrt.FeedMessage{
+			Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+			Entity: []*gtfsrt.FeedEntity{
+				tripUpdateEntity("1", "1601-TDome1900",
+					&gtfsrt.TripUpdate_StopTimeUpdate{
+						StopSequence: proto.Uint32(1),
+						Arrival:      &gtfsrt.TripUpdate_StopTimeEvent{Delay: proto.Int32(60)},
+					},
+					&gtfsrt.TripUpdate_StopTimeUpdate{
+						StopSequence: proto.Uint32(2),
+						Arrival:      &gtfsrt.TripUpdate_StopTimeEvent{Time: proto.Int64(1736700000)},
+					},
+				),
+				tripUpdateEntity("2", "does-not-exist",
+					&gtfsrt.TripUpdate_StopTimeUpdate{
+						StopSequence: proto.Uint32(1),
+						Departure:    &gtfsrt.TripUpdate_StopTimeEvent{Delay: proto.Int32(0), Time: proto.Int64(1736700000)},
+					},
+				),
+			},
+		}
+		ts := setupGtfsRtFeedServer(t, feed)
+
+		server := models.ObaServer{ID: 901, TripUpdateUrl: ts.URL}
+
+		summary, err := CheckTripUpdates(context.Background(), nil, staticData, logger, server)
+		if err != nil {
+			t.Fatalf("CheckTripUpdates failed: %v", err)
+		}
+
+		if summary.TripUpdates != 2 {
+			t.Errorf("Expected 2 trip updates, got %d", summary.TripUpdates)
+		}
+		if summary.UnknownTripIDs != 1 || summary.UnknownTripIDList[0] != "does-not-exist" {
+			t.Errorf("Expected one unknown trip ID, got %v", summary.UnknownTripIDList)
+		}
+
+		count, err := getMetricValue(TripUpdatesCount, map[string]string{"server_id": "901"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("Expected trip updates count metric to be 2, got %v", count)
+		}
+
+		delayRatio, err := getMetricValue(TripUpdatesDelayRatio, map[string]string{"server_id": "901"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeRatio, err := getMetricValue(TripUpdatesAbsoluteTimeRatio, map[string]string{"server_id": "901"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delayRatio != 2.0/3.0 || timeRatio != 2.0/3.0 {
+			t.Errorf("Expected delay and time ratios of 2/3, got %v and %v", delayRatio, timeRatio)
+		}
+
+		unknown, err := getMetricValue(TripUpdatesUnknownTrips, map[string]string{"server_id": "901"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unknown != 1 {
+			t.Errorf("Expected unknown trip IDs metric to be 1, got %v", unknown)
+		}
+	})
+
+	t.Run("No trip updates URL", func(t *testing.T) {
+		_, err := CheckTripUpdates(context.Background(), nil, staticData, logger, models.ObaServer{ID: 902})
+		if err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	})
+
+	t.Run("Feed error status", func(t *testing.T) {
+		ts := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		_, err := CheckTripUpdates(context.Background(), nil, staticData, logger, models.ObaServer{ID: 903, TripUpdateUrl: ts.URL})
+		if err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	})
+}
@@ -0,0 +1,295 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+const (
+	// metersPerDegree is the length of a degree of latitude, on the sphere used by distanceMeters.
+	metersPerDegree = 6371000.0 * math.Pi / 180
+	// serviceAreaMarginMeters widens the bounding box of the bundle's stops, so that
+	// vehicles at depots and layovers just outside of it are not reported.
+	serviceAreaMarginMeters = 5000.0
+	// minTeleportDistanceMeters keeps GPS jitter between polls a few seconds apart from
+	// being reported as an impossible speed.
+	minTeleportDistanceMeters = 1000.0
+)
+
+// VehiclePlausibility lists the vehicles of the vehicle positions feeds of a server
+// whose reported position cannot be right.
+type VehiclePlausibility struct {
+	// Vehicles counts the vehicles with an ID and a position.
+	Vehicles int
+	// Teleporting vehicles moved faster than the server's speed limit since the previous poll.
+	Teleporting []string
+	// OutOfArea vehicles are outside the bounding box of the stops of the static bundle.
+	OutOfArea []string
+	// NullIsland vehicles are at 0,0.
+	NullIsland []string
+	// OffShape vehicles are further from the shape of their trip than the server's
+	// tolerance. Detours put vehicles off their shape, so they do not fail the check.
+	OffShape []string
+}
+
+// Implausible counts the distinct vehicles that teleported, are out of the service area or at 0,0.
+func (p VehiclePlausibility) Implausible() int {
+	ids := make(map[string]bool)
+	for _, list := range [][]string{p.Teleporting, p.OutOfArea, p.NullIsland} {
+		for _, id := range list {
+			ids[id] = true
+		}
+	}
+	return len(ids)
+}
+
+// vehicleSample is where a vehicle was at a point in time.
+type vehicleSample struct {
+	lat, lon float64
+	at       time.Time
+}
+
+// VehicleHistory keeps the vehicle positions of the previous poll of every server,
+// keyed by feed URL and vehicle ID. It is safe for concurrent use.
+type VehicleHistory struct {
+	mu      sync.Mutex
+	servers map[int]map[string]vehicleSample
+}
+
+// NewVehicleHistory creates an empty VehicleHistory.
+func NewVehicleHistory() *VehicleHistory {
+	return &VehicleHistory{servers: make(map[int]map[string]vehicleSample)}
+}
+
+// swap stores the samples of the latest poll of the server and returns those of the previous one.
+func (h *VehicleHistory) swap(serverID int, samples map[string]vehicleSample) map[string]vehicleSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.servers[serverID]
+	h.servers[serverID] = samples
+	return previous
+}
+
+// Retain removes the positions of all servers not listed in serverIDs.
+func (h *VehicleHistory) Retain(serverIDs []int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	models.RetainServers(h.servers, serverIDs)
+}
+
+// CheckVehiclePlausibility fetches the vehicle positions feeds of the server and flags
+// vehicles at 0,0, outside the bounding box of the stops of the static bundle, further
+// from the shape of their trip than the server's tolerance, or that moved faster than
+// the server's speed limit since the poll recorded in history. Positions are timed by
+// the vehicle timestamp, or by now when the feed has none. It returns an error naming
+// some of the vehicles when any vehicle teleported, is out of the area or at 0,0.
+func CheckVehiclePlausibility(ctx context.Context, feedContent *FeedContentTracker, staticData *gtfs.Static, history *VehicleHistory, server models.ObaServer, now time.Time) (VehiclePlausibility, error) {
+	feeds := server.VehiclePositionFeeds()
+	if len(feeds) == 0 {
+		return VehiclePlausibility{}, fmt.Errorf("no vehicle positions URL configured for server %d", server.ID)
+	}
+
+	var vehicles []plausibilityVehicle
+	for _, feed := range feeds {
+		realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, feed)
+		if err != nil {
+			return VehiclePlausibility{}, fmt.Errorf("failed to fetch vehicle positions: %v", err)
+		}
+		vehicles = append(vehicles, plausibilityVehicles(feed, realtimeData, now)...)
+	}
+
+	samples := make(map[string]vehicleSample, len(vehicles))
+	for _, vehicle := range vehicles {
+		if !vehicle.nullIsland() {
+			samples[vehicle.key] = vehicle.sample
+		}
+	}
+	previous := history.swap(server.ID, samples)
+
+	result := assessVehicles(vehicles, previous, staticData, server)
+
+	serverID := strconv.Itoa(server.ID)
+	VehiclesImplausible.WithLabelValues(serverID, "teleporting").Set(float64(len(result.Teleporting)))
+	VehiclesImplausible.WithLabelValues(serverID, "out_of_area").Set(float64(len(result.OutOfArea)))
+	VehiclesImplausible.WithLabelValues(serverID, "null_island").Set(float64(len(result.NullIsland)))
+	VehiclesImplausible.WithLabelValues(serverID, "off_shape").Set(float64(len(result.OffShape)))
+
+	if result.Implausible() > 0 {
+		return result, fmt.Errorf("%d of %d vehicles have implausible positions: %s", result.Implausible(), result.Vehicles, result.describe())
+	}
+	return result, nil
+}
+
+// describe lists the implausible vehicles of each kind, naming a few of them.
+func (p VehiclePlausibility) describe() string {
+	return describeKinds([]idKind{
+		{"teleporting", p.Teleporting},
+		{"out of area", p.OutOfArea},
+		{"at 0,0", p.NullIsland},
+		{"off shape", p.OffShape},
+	})
+}
+
+// plausibilityVehicle is a vehicle of a vehicle positions feed that has an ID and a position.
+type plausibilityVehicle struct {
+	// key identifies the vehicle across polls: vehicle IDs are only unique within a feed.
+	key    string
+	id     string
+	tripID string
+	sample vehicleSample
+}
+
+func (v plausibilityVehicle) nullIsland() bool {
+	return v.sample.lat == 0 && v.sample.lon == 0
+}
+
+// plausibilityVehicles returns the vehicles of the feed with an ID and a position.
+func plausibilityVehicles(feed models.GtfsRtFeed, realtimeData *gtfs.Realtime, now time.Time) []plausibilityVehicle {
+	vehicles := make([]plausibilityVehicle, 0, len(realtimeData.Vehicles))
+	for _, vehicle := range realtimeData.Vehicles {
+		id := vehicle.GetID().ID
+		position := vehicle.Position
+		if id == "" || position == nil || position.Latitude == nil || position.Longitude == nil {
+			continue
+		}
+		v := plausibilityVehicle{
+			key: feed.URL + "\x00" + id,
+			id:  id,
+			sample: vehicleSample{
+				lat: float64(*position.Latitude),
+				lon: float64(*position.Longitude),
+				at:  now,
+			},
+		}
+		if vehicle.Trip != nil {
+			v.tripID = vehicle.Trip.ID.ID
+		}
+		if vehicle.Timestamp != nil {
+			v.sample.at = *vehicle.Timestamp
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles
+}
+
+// assessVehicles checks the position of every vehicle against the static bundle and
+// against its position in previous.
+func assessVehicles(vehicles []plausibilityVehicle, previous map[string]vehicleSample, staticData *gtfs.Static, server models.ObaServer) VehiclePlausibility {
+	result := VehiclePlausibility{Vehicles: len(vehicles)}
+	area, hasArea := stopBounds(staticData)
+	speedLimit, shapeTolerance := server.VehicleSpeedLimit(), server.VehicleShapeTolerance()
+
+	shapes := make(map[string]*gtfs.Shape)
+	for i := range staticData.Trips {
+		if trip := &staticData.Trips[i]; trip.Shape != nil && len(trip.Shape.Points) > 0 {
+			shapes[trip.ID] = trip.Shape
+		}
+	}
+
+	for _, vehicle := range vehicles {
+		current := vehicle.sample
+		if vehicle.nullIsland() {
+			result.NullIsland = append(result.NullIsland, vehicle.id)
+			continue
+		}
+		if hasArea && !area.contains(current.lat, current.lon) {
+			result.OutOfArea = append(result.OutOfArea, vehicle.id)
+		}
+		if before, ok := previous[vehicle.key]; ok {
+			elapsed := current.at.Sub(before.at).Seconds()
+			distance := distanceMeters(before.lat, before.lon, current.lat, current.lon)
+			if elapsed > 0 && distance > minTeleportDistanceMeters && distance/elapsed > speedLimit {
+				result.Teleporting = append(result.Teleporting, vehicle.id)
+			}
+		}
+		if shape, ok := shapes[vehicle.tripID]; ok && distanceToShape(current.lat, current.lon, shape) > shapeTolerance {
+			result.OffShape = append(result.OffShape, vehicle.id)
+		}
+	}
+
+	sort.Strings(result.Teleporting)
+	sort.Strings(result.OutOfArea)
+	sort.Strings(result.NullIsland)
+	sort.Strings(result.OffShape)
+	return result
+}
+
+// bounds is a latitude and longitude bounding box.
+type bounds struct {
+	minLat, maxLat, minLon, maxLon float64
+}
+
+func (b bounds) contains(lat, lon float64) bool {
+	return lat >= b.minLat && lat <= b.maxLat && lon >= b.minLon && lon <= b.maxLon
+}
+
+// stopBounds returns the bounding box of the stops with a location, widened by
+// serviceAreaMarginMeters. Stops at 0,0 are ignored.
+func stopBounds(staticData *gtfs.Static) (bounds, bool) {
+	var b bounds
+	found := false
+	for _, stop := range staticData.Stops {
+		if stop.Latitude == nil || stop.Longitude == nil || (*stop.Latitude == 0 && *stop.Longitude == 0) {
+			continue
+		}
+		lat, lon := *stop.Latitude, *stop.Longitude
+		if !found {
+			b = bounds{minLat: lat, maxLat: lat, minLon: lon, maxLon: lon}
+			found = true
+			continue
+		}
+		b.minLat, b.maxLat = min(b.minLat, lat), max(b.maxLat, lat)
+		b.minLon, b.maxLon = min(b.minLon, lon), max(b.maxLon, lon)
+	}
+	if !found {
+		return bounds{}, false
+	}
+
+	latMargin := serviceAreaMarginMeters / metersPerDegree
+	// Degrees of longitude are shortest at the latitude furthest from the equator.
+	widest := max(math.Abs(b.minLat), math.Abs(b.maxLat))
+	lonMargin := serviceAreaMarginMeters / (metersPerDegree * math.Max(math.Cos(widest*math.Pi/180), 0.01))
+	b.minLat, b.maxLat = b.minLat-latMargin, b.maxLat+latMargin
+	b.minLon, b.maxLon = b.minLon-lonMargin, b.maxLon+lonMargin
+	return b, true
+}
+
+// distanceToShape returns the distance in meters from the point to the nearest segment
+// of the shape. Distances are computed on a plane tangent at the point, which is
+// accurate enough at the scale of a shape tolerance.
+func distanceToShape(lat, lon float64, shape *gtfs.Shape) float64 {
+	cosLat := math.Cos(lat * math.Pi / 180)
+	project := func(point gtfs.ShapePoint) (float64, float64) {
+		return (point.Longitude - lon) * cosLat * metersPerDegree, (point.Latitude - lat) * metersPerDegree
+	}
+
+	points := shape.Points
+	x, y := project(points[0])
+	nearest := math.Hypot(x, y)
+	for _, point := range points[1:] {
+		nextX, nextY := project(point)
+		nearest = min(nearest, distanceToSegment(x, y, nextX, nextY))
+		x, y = nextX, nextY
+	}
+	return nearest
+}
+
+// distanceToSegment returns the distance from the origin to the segment from a to b.
+func distanceToSegment(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func positionEntity(vehicleID, tripID string, lat, lon float32, timestamp time.Time) *gtfsrt.FeedEntity {
+	vehicle := &gtfsrt.VehiclePosition{
+		Vehicle:   &gtfsrt.VehicleDescriptor{Id: proto.String(vehicleID)},
+		Position:  &gtfsrt.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(lon)},
+		Timestamp: proto.Uint64(uint64(timestamp.Unix())),
+	}
+	if tripID != "" {
+		vehicle.Trip = &gtfsrt.TripDescriptor{TripId: proto.String(tripID)}
+	}
+	return &gtfsrt.FeedEntity{Id: proto.String(vehicleID), Vehicle: vehicle}
+}
+
+func TestCheckVehiclePlausibility(t *testing.T) {
+	coordinate := func(v float64) *float64 { return &v }
+	shape := &gtfs.Shape{ID: "SH1", Points: []gtfs.ShapePoint{
+		{Latitude: 47.60, Longitude: -122.33},
+		{Latitude: 47.62, Longitude: -122.30},
+	}}
+	staticData := &gtfs.Static{
+		Stops: []gtfs.Stop{
+			{Id: "S1", Latitude: coordinate(47.60), Longitude: coordinate(-122.33)},
+			{Id: "S2", Latitude: coordinate(47.62), Longitude: coordinate(-122.30)},
+			{Id: "N1"},
+		},
+		Trips: []gtfs.ScheduledTrip{{ID: "T1", Shape: shape}},
+	}
+	now := time.Unix(1736700000, 0)
+
+	var message *gtfsrt.FeedMessage
+	feed := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := proto.Marshal(message)
+		if err != nil {
+			t.Errorf("Failed to marshal GTFS-RT feed: %v", err)
+		}
+		w.Write(data)
+	}))
+	server := models.ObaServer{ID: 2401, VehiclePositionUrl: feed.URL}
+	history := NewVehicleHistory()
+	header := &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")}
+
+	message = &gtfsrt.FeedMessage{Header: header, Entity: []*gtfsrt.FeedEntity{
+		positionEntity("steady", "T1", 47.60, -122.33, now.Add(-30*time.Second)),
+		positionEntity("jumper", "T1", 47.60, -122.33, now.Add(-30*time.Second)),
+		positionEntity("detour", "T1", 47.61, -122.25, now.Add(-30*time.Second)),
+		positionEntity("nowhere", "", 40.0, -100.0, now.Add(-30*time.Second)),
+		positionEntity("null", "T1", 0, 0, now.Add(-30*time.Second)),
+	}}
+
+	result, err := CheckVehiclePlausibility(context.Background(), nil, staticData, history, server, now)
+	if err == nil || !strings.Contains(err.Error(), "2 of 5 vehicles") {
+		t.Errorf("Expected 2 implausible vehicles, got %v", err)
+	}
+	if len(result.Teleporting) != 0 || !reflect.DeepEqual(result.OutOfArea, []string{"nowhere"}) ||
+		!reflect.DeepEqual(result.NullIsland, []string{"null"}) || !reflect.DeepEqual(result.OffShape, []string{"detour"}) {
+		t.Errorf("Unexpected first poll %+v", result)
+	}
+
+	// jumper covers about 3 km in 30 seconds, steady moves along its shape.
+	message = &gtfsrt.FeedMessage{Header: header, Entity: []*gtfsrt.FeedEntity{
+		positionEntity("steady", "T1", 47.601, -122.3285, now),
+		positionEntity("jumper", "T1", 47.62, -122.30, now),
+	}}
+
+	result, err = CheckVehiclePlausibility(context.Background(), nil, staticData, history, server, now.Add(30*time.Second))
+	if err == nil {
+		t.Error("Expected the teleporting vehicle to fail the check")
+	}
+	if !reflect.DeepEqual(result.Teleporting, []string{"jumper"}) || len(result.OutOfArea) != 0 || len(result.OffShape) != 0 {
+		t.Errorf("Unexpected second poll %+v", result)
+	}
+
+	labels := map[string]string{"server_id": "2401", "reason": "teleporting"}
+	if got, err := getMetricValue(VehiclesImplausible, labels); err != nil || got != 1 {
+		t.Errorf("Expected 1 teleporting vehicle, got %v (%v)", got, err)
+	}
+	labels["reason"] = "null_island"
+	if got, err := getMetricValue(VehiclesImplausible, labels); err != nil || got != 0 {
+		t.Errorf("Expected no vehicles at 0,0, got %v (%v)", got, err)
+	}
+
+	// A faster speed limit accepts the same movement.
+	server.VehicleMaxSpeedKmh = 500
+	message.Entity = []*gtfsrt.FeedEntity{positionEntity("jumper", "T1", 47.60, -122.33, now.Add(30*time.Second))}
+	if _, err := CheckVehiclePlausibility(context.Background(), nil, staticData, history, server, now.Add(time.Minute)); err != nil {
+		t.Errorf("Expected no implausible vehicles below 500 km/h, got %v", err)
+	}
+}
+
+func TestDistanceToShape(t *testing.T) {
+	shape := &gtfs.Shape{Points: []gtfs.ShapePoint{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 1},
+	}}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     float64
+	}{
+		{"OnShape", 0, 0.5, 0},
+		{"BesideSegment", 0.01, 0.5, 0.01 * metersPerDegree},
+		{"BeyondEnd", 0, 1.01, 0.01 * metersPerDegree},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distanceToShape(tt.lat, tt.lon, shape); math.Abs(got-tt.want) > 1 {
+				t.Errorf("Expected %.0f m, got %.0f m", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	onebusaway "github.com/OneBusAway/go-sdk"
+	"github.com/jamespfennell/gtfs"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// VehicleReconciliation is the result of joining the vehicles of the GTFS-RT vehicle
+// positions feeds with those reported by the OBA API, by vehicle ID.
+type VehicleReconciliation struct {
+	GtfsRtVehicles int
+	APIVehicles    int
+	// Matched counts vehicles present on both sides whose position and trip agree.
+	Matched            int
+	MissingFromAPI     []string
+	MissingFromGtfsRt  []string
+	PositionMismatches []string
+	TripMismatches     []string
+}
+
+// Score is the share of all vehicles seen on either side that matched. It is 1 when
+// neither side reports any vehicle.
+func (r VehicleReconciliation) Score() float64 {
+	total := r.Matched + len(r.PositionMismatches) + len(r.TripMismatches) + len(r.MissingFromAPI) + len(r.MissingFromGtfsRt)
+	if total == 0 {
+		return 1
+	}
+	return float64(r.Matched) / float64(total)
+}
+
+func (r *VehicleReconciliation) add(other VehicleReconciliation) {
+	r.GtfsRtVehicles += other.GtfsRtVehicles
+	r.APIVehicles += other.APIVehicles
+	r.Matched += other.Matched
+	r.MissingFromAPI = append(r.MissingFromAPI, other.MissingFromAPI...)
+	r.MissingFromGtfsRt = append(r.MissingFromGtfsRt, other.MissingFromGtfsRt...)
+	r.PositionMismatches = append(r.PositionMismatches, other.PositionMismatches...)
+	r.TripMismatches = append(r.TripMismatches, other.TripMismatches...)
+}
+
+// reconciledVehicle is a vehicle from either source, with agency prefixes removed from its IDs.
+type reconciledVehicle struct {
+	ID          string
+	TripID      string
+	HasPosition bool
+	Lat, Lon    float64
+}
+
+// CheckVehicleReconciliation joins the vehicles of every vehicle positions feed of the
+// server with the vehicles the OBA API reports for the agencies it covers. It exports
+// the result and returns an error when the match score is below the server's threshold.
+func CheckVehicleReconciliation(ctx context.Context, feedContent *FeedContentTracker, server models.ObaServer) (VehicleReconciliation, error) {
+	client := newObaClient(server)
+
+	var result VehicleReconciliation
+	for _, group := range vehicleFeedGroups(server) {
+		realtimeData, err := fetchGtfsRtFeed(ctx, feedContent, server.ID, group.feed)
+		if err != nil {
+			return VehicleReconciliation{}, fmt.Errorf("failed to fetch vehicle positions from GTFS-RT: %v", err)
+		}
+
+		var apiVehicles []reconciledVehicle
+		for _, agency := range group.agencies {
+			list, err := fetchAgencyVehicles(ctx, client, server, agency.ID)
+			if err != nil {
+				return VehicleReconciliation{}, fmt.Errorf("failed to fetch vehicles from API: %v", err)
+			}
+			apiVehicles = append(apiVehicles, apiReconciledVehicles(list, agency.ID)...)
+		}
+
+		result.add(reconcileVehicles(gtfsRtReconciledVehicles(realtimeData), apiVehicles, server.VehiclePositionTolerance()))
+	}
+
+	sort.Strings(result.MissingFromAPI)
+	sort.Strings(result.MissingFromGtfsRt)
+	sort.Strings(result.PositionMismatches)
+	sort.Strings(result.TripMismatches)
+
+	serverID := strconv.Itoa(server.ID)
+	VehicleMatchScore.WithLabelValues(serverID).Set(result.Score())
+	VehiclesMissingFromAPI.WithLabelValues(serverID).Set(float64(len(result.MissingFromAPI)))
+	VehiclesMissingFromGtfsRt.WithLabelValues(serverID).Set(float64(len(result.MissingFromGtfsRt)))
+	VehiclePositionMismatches.WithLabelValues(serverID).Set(float64(len(result.PositionMismatches)))
+	VehicleTripMismatches.WithLabelValues(serverID).Set(float64(len(result.TripMismatches)))
+
+	if score, threshold := result.Score(), server.VehicleMatchThreshold(); score < threshold {
+		return result, fmt.Errorf("vehicle match score %.2f is below %.2f: %s", score, threshold, result.describe())
+	}
+	return result, nil
+}
+
+// describe lists the disagreements of the reconciliation, naming a few vehicles of each kind.
+func (r VehicleReconciliation) describe() string {
+	return describeKinds([]idKind{
+		{"missing from API", r.MissingFromAPI},
+		{"missing from GTFS-RT", r.MissingFromGtfsRt},
+		{"position mismatch", r.PositionMismatches},
+		{"trip mismatch", r.TripMismatches},
+	})
+}
+
+// idKind is a named group of IDs, such as the vehicles with one kind of problem.
+type idKind struct {
+	name string
+	ids  []string
+}
+
+// describeKinds counts the IDs of each non-empty kind and names a few of them.
+func describeKinds(kinds []idKind) string {
+	var parts []string
+	for _, kind := range kinds {
+		if len(kind.ids) > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s (%s)", len(kind.ids), kind.name, sampleIDs(kind.ids, 5)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sampleIDs joins the first n IDs, noting how many were left out.
+func sampleIDs(ids []string, n int) string {
+	if len(ids) <= n {
+		return strings.Join(ids, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(ids[:n], ", "), len(ids)-n)
+}
+
+// reconcileVehicles joins the vehicles of both sources by ID. Positions are only
+// compared when both sides report one.
+func reconcileVehicles(gtfsRtVehicles, apiVehicles []reconciledVehicle, toleranceMeters float64) VehicleReconciliation {
+	result := VehicleReconciliation{GtfsRtVehicles: len(gtfsRtVehicles), APIVehicles: len(apiVehicles)}
+
+	byID := make(map[string]reconciledVehicle, len(apiVehicles))
+	for _, vehicle := range apiVehicles {
+		byID[vehicle.ID] = vehicle
+	}
+
+	seen := make(map[string]bool, len(gtfsRtVehicles))
+	for _, vehicle := range gtfsRtVehicles {
+		seen[vehicle.ID] = true
+		apiVehicle, ok := byID[vehicle.ID]
+		switch {
+		case !ok:
+			result.MissingFromAPI = append(result.MissingFromAPI, vehicle.ID)
+		case vehicle.HasPosition && apiVehicle.HasPosition &&
+			distanceMeters(vehicle.Lat, vehicle.Lon, apiVehicle.Lat, apiVehicle.Lon) > toleranceMeters:
+			result.PositionMismatches = append(result.PositionMismatches, vehicle.ID)
+		case vehicle.TripID != apiVehicle.TripID:
+			result.TripMismatches = append(result.TripMismatches, vehicle.ID)
+		default:
+			result.Matched++
+		}
+	}
+
+	for _, vehicle := range apiVehicles {
+		if !seen[vehicle.ID] {
+			result.MissingFromGtfsRt = append(result.MissingFromGtfsRt, vehicle.ID)
+		}
+	}
+
+	return result
+}
+
+// gtfsRtReconciledVehicles returns the vehicles of the feed that carry an ID.
+func gtfsRtReconciledVehicles(realtimeData *gtfs.Realtime) []reconciledVehicle {
+	vehicles := make([]reconciledVehicle, 0, len(realtimeData.Vehicles))
+	for _, vehicle := range realtimeData.Vehicles {
+		id := vehicle.GetID().ID
+		if id == "" {
+			continue
+		}
+		reconciled := reconciledVehicle{ID: id}
+		if vehicle.Trip != nil {
+			reconciled.TripID = vehicle.Trip.ID.ID
+		}
+		if position := vehicle.Position; position != nil && position.Latitude != nil && position.Longitude != nil {
+			reconciled.HasPosition = true
+			reconciled.Lat = float64(*position.Latitude)
+			reconciled.Lon = float64(*position.Longitude)
+		}
+		vehicles = append(vehicles, reconciled)
+	}
+	return vehicles
+}
+
+// apiReconciledVehicles converts the OBA API vehicles of an agency, removing the
+// agency prefix OBA adds to vehicle and trip IDs. A 0,0 location means no position.
+func apiReconciledVehicles(list []onebusaway.VehiclesForAgencyListResponseDataList, agencyID string) []reconciledVehicle {
+	prefix := agencyID + "_"
+	vehicles := make([]reconciledVehicle, 0, len(list))
+	for _, vehicle := range list {
+		reconciled := reconciledVehicle{
+			ID:     strings.TrimPrefix(vehicle.VehicleID, prefix),
+			TripID: strings.TrimPrefix(vehicle.TripID, prefix),
+			Lat:    vehicle.Location.Lat,
+			Lon:    vehicle.Location.Lon,
+		}
+		reconciled.HasPosition = reconciled.Lat != 0 || reconciled.Lon != 0
+		vehicles = append(vehicles, reconciled)
+	}
+	return vehicles
+}
+
+// distanceMeters returns the great-circle distance between two WGS-84 coordinates.
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func TestReconcileVehicles(t *testing.T) {
+	gtfsRtVehicles := []reconciledVehicle{
+		{ID: "1", TripID: "t1", HasPosition: true, Lat: 47.6062, Lon: -122.3321},
+		{ID: "2", TripID: "t2", HasPosition: true, Lat: 47.6062, Lon: -122.3321},
+		{ID: "3", TripID: "t3", HasPosition: true, Lat: 47.6062, Lon: -122.3321},
+		{ID: "4", TripID: "t4"},
+		{ID: "5", TripID: "t5"},
+	}
+	apiVehicles := []reconciledVehicle{
+		// About 50m away: within tolerance.
+		{ID: "1", TripID: "t1", HasPosition: true, Lat: 47.6066, Lon: -122.3321},
+		// About 1.1km away.
+		{ID: "2", TripID: "t2", HasPosition: true, Lat: 47.6162, Lon: -122.3321},
+		{ID: "3", TripID: "other", HasPosition: true, Lat: 47.6062, Lon: -122.3321},
+		// Positions are not compared when one side has none.
+		{ID: "4", TripID: "t4", HasPosition: true, Lat: 47.7, Lon: -122.4},
+		{ID: "6", TripID: "t6"},
+	}
+
+	result := reconcileVehicles(gtfsRtVehicles, apiVehicles, 200)
+
+	if result.Matched != 2 {
+		t.Errorf("Expected 2 matched vehicles, got %d", result.Matched)
+	}
+	if !reflect.DeepEqual(result.PositionMismatches, []string{"2"}) {
+		t.Errorf("Expected position mismatch for vehicle 2, got %v", result.PositionMismatches)
+	}
+	if !reflect.DeepEqual(result.TripMismatches, []string{"3"}) {
+		t.Errorf("Expected trip mismatch for vehicle 3, got %v", result.TripMismatches)
+	}
+	if !reflect.DeepEqual(result.MissingFromAPI, []string{"5"}) {
+		t.Errorf("Expected vehicle 5 missing from the API, got %v", result.MissingFromAPI)
+	}
+	if !reflect.DeepEqual(result.MissingFromGtfsRt, []string{"6"}) {
+		t.Errorf("Expected vehicle 6 missing from GTFS-RT, got %v", result.MissingFromGtfsRt)
+	}
+	if score := result.Score(); score != 2.0/6.0 {
+		t.Errorf("Expected a score of 2/6, got %v", score)
+	}
+
+	if score := (VehicleReconciliation{}).Score(); score != 1 {
+		t.Errorf("Expected a score of 1 without vehicles, got %v", score)
+	}
+}
+
+func TestCheckVehicleReconciliation(t *testing.T) {
+	vehicle := func(id, tripID string, lat, lon float32) *gtfsrt.FeedEntity {
+		return &gtfsrt.FeedEntity{
+			Id: proto.String(id),
+			Vehicle: &gtfsrt.VehiclePosition{
+				Vehicle:  &gtfsrt.VehicleDescriptor{Id: proto.String(id)},
+				Trip:     &gtfsrt.TripDescriptor{TripId: proto.String(tripID)},
+				Position: &gtfsrt.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(lon)},
+			},
+		}
+	}
+	feed := setupGtfsRtFeedServer(t, &gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+		Entity: []*gtfsrt.FeedEntity{
+			vehicle("100", "t1", 47.6062, -122.3321),
+			vehicle("101", "t2", 47.6062, -122.3321),
+			vehicle("102", "t3", 47.6062, -122.3321),
+		},
+	})
+
+	apiVehicle := func(id, tripID string, lat, lon float64) string {
+		return fmt.Sprintf(`{"vehicleId":"1_%s","tripId":"1_%s","location":{"lat":%v,"lon":%v}}`, id, tripID, lat, lon)
+	}
+	var apiVehicles []string
+	obaServer := setupTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"code":200,"currentTime":1234567890000,"text":"OK","version":2,"data":{"list":[%s]}}`, strings.Join(apiVehicles, ","))
+	}))
+
+	server := models.ObaServer{
+		ID:                 1501,
+		ObaBaseURL:         obaServer.URL,
+		ObaApiKey:          "test-key",
+		VehiclePositionUrl: feed.URL,
+		AgencyID:           "1",
+	}
+
+	t.Run("AllAgree", func(t *testing.T) {
+		apiVehicles = []string{
+			apiVehicle("100", "t1", 47.6062, -122.3321),
+			apiVehicle("101", "t2", 47.6063, -122.3321),
+			apiVehicle("102", "t3", 47.6062, -122.3322),
+		}
+
+		result, err := CheckVehicleReconciliation(context.Background(), nil, server)
+		if err != nil {
+			t.Fatalf("CheckVehicleReconciliation failed: %v", err)
+		}
+		if result.Matched != 3 {
+			t.Errorf("Expected 3 matched vehicles, got %+v", result)
+		}
+
+		score, err := getMetricValue(VehicleMatchScore, map[string]string{"server_id": "1501"})
+		if err != nil {
+			t.Fatalf("Failed to get VehicleMatchScore: %v", err)
+		}
+		if score != 1 {
+			t.Errorf("Expected a match score of 1, got %v", score)
+		}
+	})
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		apiVehicles = []string{
+			apiVehicle("100", "t1", 47.6062, -122.3321),
+			apiVehicle("101", "t9", 47.6062, -122.3321),
+			apiVehicle("103", "t4", 47.6062, -122.3321),
+		}
+
+		_, err := CheckVehicleReconciliation(context.Background(), nil, server)
+		if err == nil {
+			t.Fatal("Expected an error for a low match score")
+		}
+		for _, want := range []string{"1 missing from API (102)", "1 missing from GTFS-RT (103)", "1 trip mismatch (101)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected the error to contain %q, got %q", want, err)
+			}
+		}
+
+		missing, _ := getMetricValue(VehiclesMissingFromAPI, map[string]string{"server_id": "1501"})
+		if missing != 1 {
+			t.Errorf("Expected 1 vehicle missing from the API, got %v", missing)
+		}
+	})
+
+	t.Run("ToleratedByServerSettings", func(t *testing.T) {
+		lenient := server
+		lenient.VehicleMatchMinScore = 0.2
+
+		if _, err := CheckVehicleReconciliation(context.Background(), nil, lenient); err != nil {
+			t.Errorf("Expected the lower minimum score to pass, got %v", err)
+		}
+	})
+}
+
+func TestDistanceMeters(t *testing.T) {
+	// One degree of latitude is about 111km.
+	if d := distanceMeters(47, -122, 48, -122); d < 111000 || d > 111400 {
+		t.Errorf("Expected about 111km, got %v", d)
+	}
+	if d := distanceMeters(47.6, -122.3, 47.6, -122.3); d != 0 {
+		t.Errorf("Expected 0 for the same point, got %v", d)
+	}
+}
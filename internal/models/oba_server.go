@@ -1,7 +1,12 @@
 package models
 
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
 // ObaServer represents a OneBusAway server configuration
-// TODO: Some server have multiple Agencies, so we should have a list of Agencies
 type ObaServer struct {
 	Name               string `json:"name"`
 	ID                 int    `json:"id"`
@@ -10,9 +15,257 @@ type ObaServer struct {
 	GtfsUrl            string `json:"gtfs_url"`
 	TripUpdateUrl      string `json:"trip_update_url"`
 	VehiclePositionUrl string `json:"vehicle_position_url"`
+	ServiceAlertsUrl   string `json:"service_alerts_url,omitempty"`
 	GtfsRtApiKey       string `json:"gtfs_rt_api_key"`
 	GtfsRtApiValue     string `json:"gtfs_rt_api_value"`
 	AgencyID           string `json:"agency_id"`
+	// Agencies lists the agencies served by the server. When empty, the server is
+	// treated as serving the single agency AgencyID.
+	Agencies []Agency `json:"agencies,omitempty"`
+	// VehicleStaleThresholdSeconds is the age after which a vehicle position is
+	// considered stale. Zero means DefaultVehicleStaleThreshold.
+	VehicleStaleThresholdSeconds int `json:"vehicle_stale_threshold_seconds,omitempty"`
+	// FeedFrozenThresholdSeconds is how long the content of a vehicle positions or trip
+	// updates feed may stay identical before the feed is considered frozen. Zero means
+	// DefaultFeedFrozenThreshold.
+	FeedFrozenThresholdSeconds int `json:"feed_frozen_threshold_seconds,omitempty"`
+	// VehiclePositionToleranceMeters is the distance up to which the GTFS-RT and OBA API
+	// positions of a vehicle are considered the same. Zero means DefaultVehiclePositionTolerance.
+	VehiclePositionToleranceMeters float64 `json:"vehicle_position_tolerance_meters,omitempty"`
+	// VehicleMatchMinScore is the share of vehicles that must agree between the GTFS-RT
+	// feed and the OBA API for the reconciliation to pass. Zero means DefaultVehicleMatchMinScore.
+	VehicleMatchMinScore float64 `json:"vehicle_match_min_score,omitempty"`
+	// TripCoverageMinRatio is the share of trips in progress that must have realtime
+	// data. Zero means DefaultTripCoverageMinRatio.
+	TripCoverageMinRatio float64 `json:"trip_coverage_min_ratio,omitempty"`
+	// VehicleMaxSpeedKmh is the speed above which the movement of a vehicle between two
+	// polls is considered impossible. Zero means DefaultVehicleMaxSpeedKmh.
+	VehicleMaxSpeedKmh float64 `json:"vehicle_max_speed_kmh,omitempty"`
+	// VehicleShapeToleranceMeters is the distance from the shape of its trip beyond which
+	// a vehicle is considered off its route. Zero means DefaultVehicleShapeTolerance.
+	VehicleShapeToleranceMeters float64 `json:"vehicle_shape_tolerance_meters,omitempty"`
+	// ArrivalsProbe selects the stops probed with arrivals-and-departures-for-stop.
+	ArrivalsProbe ArrivalsProbeSettings `json:"arrivals_probe,omitempty"`
+	// Checks overrides the schedule of individual checks, keyed by check name.
+	Checks map[string]CheckSettings `json:"checks,omitempty"`
+}
+
+// Agency is an agency served by an OBA server. Empty GTFS-RT fields fall back to the
+// server's values.
+type Agency struct {
+	ID                 string `json:"id"`
+	VehiclePositionUrl string `json:"vehicle_position_url,omitempty"`
+	TripUpdateUrl      string `json:"trip_update_url,omitempty"`
+	ServiceAlertsUrl   string `json:"service_alerts_url,omitempty"`
+	GtfsRtApiKey       string `json:"gtfs_rt_api_key,omitempty"`
+	GtfsRtApiValue     string `json:"gtfs_rt_api_value,omitempty"`
+}
+
+// GtfsRtFeed is a GTFS-RT endpoint and the header used to authenticate against it.
+type GtfsRtFeed struct {
+	URL      string
+	ApiKey   string
+	ApiValue string
+}
+
+// RedactedURL returns the URL of the feed with credentials redacted, see ObaServer.Redacted.
+func (f GtfsRtFeed) RedactedURL() string {
+	return redactURL(f.URL)
+}
+
+// VehiclePositionFeed returns the vehicle positions feed of the agency.
+func (a Agency) VehiclePositionFeed() GtfsRtFeed {
+	return GtfsRtFeed{URL: a.VehiclePositionUrl, ApiKey: a.GtfsRtApiKey, ApiValue: a.GtfsRtApiValue}
+}
+
+// TripUpdateFeed returns the trip updates feed of the agency.
+func (a Agency) TripUpdateFeed() GtfsRtFeed {
+	return GtfsRtFeed{URL: a.TripUpdateUrl, ApiKey: a.GtfsRtApiKey, ApiValue: a.GtfsRtApiValue}
+}
+
+// ServiceAlertsFeed returns the service alerts feed of the agency.
+func (a Agency) ServiceAlertsFeed() GtfsRtFeed {
+	return GtfsRtFeed{URL: a.ServiceAlertsUrl, ApiKey: a.GtfsRtApiKey, ApiValue: a.GtfsRtApiValue}
+}
+
+// ArrivalsProbeSettings selects the stops whose arrivals are probed. When StopIDs is
+// empty, SampleSize stops are picked at random from the static bundle on every run.
+type ArrivalsProbeSettings struct {
+	// StopIDs are OBA stop IDs, including the agency prefix (e.g. "1_75403").
+	StopIDs    []string `json:"stop_ids,omitempty"`
+	SampleSize int      `json:"sample_size,omitempty"`
+}
+
+// DefaultArrivalsProbeSampleSize is the number of stops sampled when a server configures neither stops nor a sample size.
+const DefaultArrivalsProbeSampleSize = 5
+
+// SampleSizeOrDefault returns the number of stops to sample.
+func (p ArrivalsProbeSettings) SampleSizeOrDefault() int {
+	if p.SampleSize <= 0 {
+		return DefaultArrivalsProbeSampleSize
+	}
+	return p.SampleSize
+}
+
+// CheckSettings overrides how often and how long a check runs for a server.
+// Zero values fall back to the check's defaults.
+type CheckSettings struct {
+	IntervalSeconds int  `json:"interval_seconds,omitempty"`
+	TimeoutSeconds  int  `json:"timeout_seconds,omitempty"`
+	Disabled        bool `json:"disabled,omitempty"`
+}
+
+// DefaultVehicleStaleThreshold is used when a server does not configure its own threshold.
+const DefaultVehicleStaleThreshold = 5 * time.Minute
+
+// VehicleStaleThreshold returns the age after which a vehicle position of this server is stale.
+func (s ObaServer) VehicleStaleThreshold() time.Duration {
+	if s.VehicleStaleThresholdSeconds <= 0 {
+		return DefaultVehicleStaleThreshold
+	}
+	return time.Duration(s.VehicleStaleThresholdSeconds) * time.Second
+}
+
+// DefaultFeedFrozenThreshold is used when a server does not configure its own threshold.
+const DefaultFeedFrozenThreshold = 5 * time.Minute
+
+// FeedFrozenThreshold returns how long the content of a GTFS-RT feed of this server may stay unchanged.
+func (s ObaServer) FeedFrozenThreshold() time.Duration {
+	if s.FeedFrozenThresholdSeconds <= 0 {
+		return DefaultFeedFrozenThreshold
+	}
+	return time.Duration(s.FeedFrozenThresholdSeconds) * time.Second
+}
+
+const (
+	// DefaultVehiclePositionTolerance is used when a server does not configure its own tolerance.
+	DefaultVehiclePositionTolerance = 200.0
+	// DefaultVehicleMatchMinScore is used when a server does not configure its own minimum.
+	DefaultVehicleMatchMinScore = 0.9
+	// DefaultTripCoverageMinRatio is used when a server does not configure its own minimum.
+	DefaultTripCoverageMinRatio = 0.5
+	// DefaultVehicleMaxSpeedKmh is used when a server does not configure its own maximum.
+	DefaultVehicleMaxSpeedKmh = 150.0
+	// DefaultVehicleShapeTolerance is used when a server does not configure its own tolerance.
+	DefaultVehicleShapeTolerance = 500.0
+)
+
+// VehiclePositionTolerance returns the distance in meters up to which vehicle positions agree.
+func (s ObaServer) VehiclePositionTolerance() float64 {
+	if s.VehiclePositionToleranceMeters <= 0 {
+		return DefaultVehiclePositionTolerance
+	}
+	return s.VehiclePositionToleranceMeters
+}
+
+// VehicleMatchThreshold returns the minimum vehicle match score of this server.
+func (s ObaServer) VehicleMatchThreshold() float64 {
+	if s.VehicleMatchMinScore <= 0 {
+		return DefaultVehicleMatchMinScore
+	}
+	return s.VehicleMatchMinScore
+}
+
+// TripCoverageThreshold returns the minimum share of trips in progress with realtime data.
+func (s ObaServer) TripCoverageThreshold() float64 {
+	if s.TripCoverageMinRatio <= 0 {
+		return DefaultTripCoverageMinRatio
+	}
+	return s.TripCoverageMinRatio
+}
+
+// VehicleSpeedLimit returns the highest plausible vehicle speed in meters per second.
+func (s ObaServer) VehicleSpeedLimit() float64 {
+	if s.VehicleMaxSpeedKmh <= 0 {
+		return DefaultVehicleMaxSpeedKmh / 3.6
+	}
+	return s.VehicleMaxSpeedKmh / 3.6
+}
+
+// VehicleShapeTolerance returns the distance in meters a vehicle may be from the shape of its trip.
+func (s ObaServer) VehicleShapeTolerance() float64 {
+	if s.VehicleShapeToleranceMeters <= 0 {
+		return DefaultVehicleShapeTolerance
+	}
+	return s.VehicleShapeToleranceMeters
+}
+
+// AgencyList returns the agencies of the server with every empty GTFS-RT field
+// filled in from the server. A server without Agencies yields the single agency AgencyID.
+func (s ObaServer) AgencyList() []Agency {
+	if len(s.Agencies) == 0 {
+		return []Agency{{
+			ID:                 s.AgencyID,
+			VehiclePositionUrl: s.VehiclePositionUrl,
+			TripUpdateUrl:      s.TripUpdateUrl,
+			ServiceAlertsUrl:   s.ServiceAlertsUrl,
+			GtfsRtApiKey:       s.GtfsRtApiKey,
+			GtfsRtApiValue:     s.GtfsRtApiValue,
+		}}
+	}
+
+	agencies := make([]Agency, 0, len(s.Agencies))
+	for _, agency := range s.Agencies {
+		if agency.VehiclePositionUrl == "" {
+			agency.VehiclePositionUrl = s.VehiclePositionUrl
+		}
+		if agency.TripUpdateUrl == "" {
+			agency.TripUpdateUrl = s.TripUpdateUrl
+		}
+		if agency.ServiceAlertsUrl == "" {
+			agency.ServiceAlertsUrl = s.ServiceAlertsUrl
+		}
+		if agency.GtfsRtApiKey == "" && agency.GtfsRtApiValue == "" {
+			agency.GtfsRtApiKey = s.GtfsRtApiKey
+			agency.GtfsRtApiValue = s.GtfsRtApiValue
+		}
+		agencies = append(agencies, agency)
+	}
+	return agencies
+}
+
+// TripUpdateFeeds returns the distinct trip updates feeds of the server's agencies.
+func (s ObaServer) TripUpdateFeeds() []GtfsRtFeed {
+	return s.distinctFeeds(Agency.TripUpdateFeed)
+}
+
+// VehiclePositionFeeds returns the distinct vehicle positions feeds of the server's agencies.
+func (s ObaServer) VehiclePositionFeeds() []GtfsRtFeed {
+	return s.distinctFeeds(Agency.VehiclePositionFeed)
+}
+
+// ServiceAlertsFeeds returns the distinct service alerts feeds of the server's agencies.
+func (s ObaServer) ServiceAlertsFeeds() []GtfsRtFeed {
+	return s.distinctFeeds(Agency.ServiceAlertsFeed)
+}
+
+// RealtimeFeeds returns the distinct GTFS-RT feeds of the server's agencies, of any kind.
+// A feed that serves more than one kind of entity is returned once.
+func (s ObaServer) RealtimeFeeds() []GtfsRtFeed {
+	var feeds []GtfsRtFeed
+	seen := make(map[GtfsRtFeed]bool)
+	for _, feed := range append(append(s.VehiclePositionFeeds(), s.TripUpdateFeeds()...), s.ServiceAlertsFeeds()...) {
+		if !seen[feed] {
+			seen[feed] = true
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds
+}
+
+// distinctFeeds returns the configured feeds selected by feedOf, each once.
+func (s ObaServer) distinctFeeds(feedOf func(Agency) GtfsRtFeed) []GtfsRtFeed {
+	var feeds []GtfsRtFeed
+	seen := make(map[GtfsRtFeed]bool)
+	for _, agency := range s.AgencyList() {
+		feed := feedOf(agency)
+		if feed.URL == "" || seen[feed] {
+			continue
+		}
+		seen[feed] = true
+		feeds = append(feeds, feed)
+	}
+	return feeds
 }
 
 // NewObaServer creates a new ObaServer instance with the provided configuration
@@ -30,3 +283,64 @@ func NewObaServer(name string, id int, baseURL, apiKey, gtfsURL, tripUpdateURL,
 		AgencyID:           agencyID,
 	}
 }
+
+// RedactedValue is the placeholder that replaces secrets in Redacted.
+const RedactedValue = "REDACTED"
+
+// Redacted returns a copy of the server with its API keys, and any URL query
+// parameters that look like credentials, replaced by RedactedValue. Passwords in
+// URLs are removed.
+func (s ObaServer) Redacted() ObaServer {
+	if s.ObaApiKey != "" {
+		s.ObaApiKey = RedactedValue
+	}
+	if s.GtfsRtApiValue != "" {
+		s.GtfsRtApiValue = RedactedValue
+	}
+	s.ObaBaseURL = redactURL(s.ObaBaseURL)
+	s.GtfsUrl = redactURL(s.GtfsUrl)
+	s.TripUpdateUrl = redactURL(s.TripUpdateUrl)
+	s.VehiclePositionUrl = redactURL(s.VehiclePositionUrl)
+	s.ServiceAlertsUrl = redactURL(s.ServiceAlertsUrl)
+
+	if s.Agencies != nil {
+		agencies := make([]Agency, len(s.Agencies))
+		for i, agency := range s.Agencies {
+			if agency.GtfsRtApiValue != "" {
+				agency.GtfsRtApiValue = RedactedValue
+			}
+			agency.VehiclePositionUrl = redactURL(agency.VehiclePositionUrl)
+			agency.TripUpdateUrl = redactURL(agency.TripUpdateUrl)
+			agency.ServiceAlertsUrl = redactURL(agency.ServiceAlertsUrl)
+			agencies[i] = agency
+		}
+		s.Agencies = agencies
+	}
+	return s
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.RawQuery == "" && u.User == nil {
+		// Nothing to redact; keep the URL exactly as configured.
+		return rawURL
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			lower := strings.ToLower(name)
+			if strings.Contains(lower, "key") || strings.Contains(lower, "token") || strings.Contains(lower, "secret") {
+				query.Set(name, RedactedValue)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
@@ -0,0 +1,20 @@
+package models
+
+import "maps"
+
+// ServerIDSet returns the set of the given server IDs.
+func ServerIDSet(serverIDs []int) map[int]bool {
+	set := make(map[int]bool, len(serverIDs))
+	for _, id := range serverIDs {
+		set[id] = true
+	}
+	return set
+}
+
+// RetainServers deletes the entries of servers, a map keyed by server ID, whose server
+// is not listed in serverIDs. Stores that keep state per server use it to forget the
+// servers removed from the configuration.
+func RetainServers[V any](servers map[int]V, serverIDs []int) {
+	keep := ServerIDSet(serverIDs)
+	maps.DeleteFunc(servers, func(id int, _ V) bool { return !keep[id] })
+}
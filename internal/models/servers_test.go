@@ -0,0 +1,17 @@
+package models
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestRetainServers(t *testing.T) {
+	servers := map[int]string{1: "a", 2: "b", 3: "c"}
+
+	RetainServers(servers, []int{1, 3, 4})
+
+	want := map[int]string{1: "a", 3: "c"}
+	if !maps.Equal(servers, want) {
+		t.Errorf("Expected %v, got %v", want, servers)
+	}
+}
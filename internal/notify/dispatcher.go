@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultBackoff is the delay before the first retry. It doubles with every attempt.
+const DefaultBackoff = 2 * time.Second
+
+// sendTimeout bounds a single delivery attempt.
+const sendTimeout = 30 * time.Second
+
+type receiver struct {
+	notifier    Notifier
+	maxAttempts int
+}
+
+// Dispatcher routes alert messages to the notifiers named in the alert's receivers,
+// retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	logger  *slog.Logger
+	backoff time.Duration
+
+	mu        sync.RWMutex
+	receivers map[string]receiver
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher for the notifiers described by configs.
+func NewDispatcher(configs []Config, logger *slog.Logger) (*Dispatcher, error) {
+	d := &Dispatcher{
+		logger:  logger,
+		backoff: DefaultBackoff,
+	}
+	if err := d.SetNotifiers(configs); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SetNotifiers replaces the notifiers of the dispatcher.
+func (d *Dispatcher) SetNotifiers(configs []Config) error {
+	receivers := make(map[string]receiver, len(configs))
+	for _, cfg := range configs {
+		n, err := New(cfg)
+		if err != nil {
+			return err
+		}
+		receivers[cfg.Name] = receiver{notifier: n, maxAttempts: cfg.maxAttempts()}
+	}
+
+	d.mu.Lock()
+	d.receivers = receivers
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Dispatch sends msg to every receiver of the alert in the background.
+func (d *Dispatcher) Dispatch(msg Message) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, name := range msg.Alert.Receivers {
+		r, ok := d.receivers[name]
+		if !ok {
+			d.logger.Warn("Alert routed to unknown notifier", "notifier", name, "rule", msg.Alert.Rule)
+			continue
+		}
+
+		d.wg.Add(1)
+		go func(name string, r receiver) {
+			defer d.wg.Done()
+			if err := d.send(r, msg); err != nil {
+				d.logger.Error("Failed to send notification",
+					"notifier", name,
+					"rule", msg.Alert.Rule,
+					"server_id", msg.Alert.ServerID,
+					"error", err,
+				)
+			}
+		}(name, r)
+	}
+}
+
+// Wait blocks until every dispatched message has been delivered or given up on.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) send(r receiver, msg Message) error {
+	var err error
+	delay := d.backoff
+	for attempt := 1; attempt <= r.maxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = r.notifier.Notify(ctx, msg)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempt < r.maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", r.maxAttempts, err)
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	d, err := NewDispatcher([]Config{{Name: "ops", Type: TypeWebhook, URL: ts.URL}}, logger)
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	d.backoff = time.Millisecond
+
+	d.Dispatch(testMessage(alerts.StateFiring))
+	d.Wait()
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Expected 3 attempts, got %d", got)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	d, err := NewDispatcher([]Config{{Name: "ops", Type: TypeWebhook, URL: ts.URL, MaxAttempts: 2}}, logger)
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	d.backoff = time.Millisecond
+
+	d.Dispatch(testMessage(alerts.StateFiring))
+	d.Wait()
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 attempts, got %d", got)
+	}
+}
+
+func TestDispatcherRoutesToReceivers(t *testing.T) {
+	var opsCalls, otherCalls atomic.Int32
+	ops := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { opsCalls.Add(1) }))
+	defer ops.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { otherCalls.Add(1) }))
+	defer other.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	d, err := NewDispatcher([]Config{
+		{Name: "ops", Type: TypeSlack, URL: ops.URL},
+		{Name: "other", Type: TypeSlack, URL: other.URL},
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+
+	d.Dispatch(testMessage(alerts.StateFiring))
+	d.Wait()
+
+	if opsCalls.Load() != 1 || otherCalls.Load() != 0 {
+		t.Errorf("Expected only the ops receiver to be notified, got ops=%d other=%d", opsCalls.Load(), otherCalls.Load())
+	}
+}
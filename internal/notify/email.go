@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type emailNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+	tmpl *template.Template
+}
+
+func newEmailNotifier(cfg Config, tmpl *template.Template) *emailNotifier {
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return &emailNotifier{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+		auth: auth,
+		from: cfg.From,
+		to:   cfg.To,
+		tmpl: tmpl,
+	}
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, msg Message) error {
+	text, err := render(n.tmpl, msg)
+	if err != nil {
+		return err
+	}
+
+	subject, body, _ := strings.Cut(text, "\n")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.TrimSpace(body), "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return n.send(ctx, []byte(b.String()))
+}
+
+// send delivers message like smtp.SendMail, which takes no context and has no timeout.
+// The connection is dialed with ctx and the whole SMTP conversation is bound by the
+// deadline of ctx, so a hung mail server cannot block the delivery forever.
+func (n *emailNotifier) send(ctx context.Context, message []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(n.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+func TestEmailNotifier(t *testing.T) {
+	server := setupSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.addr)
+	portNum, _ := strconv.Atoi(port)
+
+	n, err := New(Config{
+		Name:         "mail",
+		Type:         TypeEmail,
+		SMTPHost:     host,
+		SMTPPort:     portNum,
+		SMTPUsername: "watchdog",
+		SMTPPassword: "secret",
+		From:         "watchdog@example.com",
+		To:           []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), testMessage(alerts.StateFiring)); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("Expected 1 mail, got %d", len(mails))
+	}
+	for _, want := range []string{
+		"Subject: FIRING [critical] ping-down on Test Server",
+		"To: ops@example.com",
+		"Check: ping",
+	} {
+		if !strings.Contains(mails[0], want) {
+			t.Errorf("Expected %q in mail:\n%s", want, mails[0])
+		}
+	}
+}
+
+func TestEmailNotifierTimesOut(t *testing.T) {
+	// The server accepts connections but never greets the client.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	})
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	n, err := New(Config{
+		Name:     "mail",
+		Type:     TypeEmail,
+		SMTPHost: host,
+		SMTPPort: portNum,
+		From:     "watchdog@example.com",
+		To:       []string{"ops@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := n.Notify(ctx, testMessage(alerts.StateFiring)); err == nil {
+		t.Fatal("Expected an error from a server that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Notify to give up at the context deadline, took %s", elapsed)
+	}
+}
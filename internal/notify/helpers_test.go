@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+func testMessage(state alerts.State) Message {
+	return Message{
+		ServerName: "Test Server",
+		Alert: alerts.Alert{
+			Rule:        "ping-down",
+			ServerID:    1,
+			Severity:    alerts.SeverityCritical,
+			Check:       "ping",
+			State:       state,
+			Message:     "check ping failed: connection refused",
+			ActiveSince: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Receivers:   []string{"ops"},
+		},
+	}
+}
+
+// smtpServer is a minimal SMTP server that records the mails it receives.
+type smtpServer struct {
+	addr string
+
+	mu    sync.Mutex
+	mails []string
+}
+
+func (s *smtpServer) Mails() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.mails...)
+}
+
+func setupSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	server := &smtpServer{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 authenticated")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.mails = append(s.mails, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package notify delivers alert notifications to webhooks, Slack and email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+// Notifier types that can be configured.
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeEmail   = "email"
+)
+
+// Message is a notification about an alert that started firing or was resolved.
+type Message struct {
+	// ServerName is the name of the OBA server the alert is about.
+	ServerName string
+	Alert      alerts.Alert
+}
+
+// Resolved reports whether the message announces that the alert was resolved.
+func (m Message) Resolved() bool {
+	return m.Alert.State == alerts.StateResolved
+}
+
+// Notifier sends messages to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Config configures a notifier. Alert rules route to notifiers by Name.
+type Config struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// URL is the endpoint of webhook and Slack notifiers.
+	URL string `json:"url,omitempty"`
+	// Headers are added to webhook requests, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SMTP settings of email notifiers.
+	SMTPHost     string   `json:"smtp_host,omitempty"`
+	SMTPPort     int      `json:"smtp_port,omitempty"`
+	SMTPUsername string   `json:"smtp_username,omitempty"`
+	SMTPPassword string   `json:"smtp_password,omitempty"`
+	From         string   `json:"from,omitempty"`
+	To           []string `json:"to,omitempty"`
+
+	// Template is a text/template rendered with a Message. It replaces the default
+	// message text. For email the first line is used as the subject.
+	Template string `json:"template,omitempty"`
+
+	// MaxAttempts is how often delivery is tried before giving up. Zero means DefaultMaxAttempts.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+// DefaultMaxAttempts is used when a notifier does not configure MaxAttempts.
+const DefaultMaxAttempts = 3
+
+const defaultTemplate = `{{if .Resolved}}RESOLVED{{else}}FIRING{{end}} [{{.Alert.Severity}}] {{.Alert.Rule}} on {{.ServerName}}
+Server: {{.ServerName}} (id {{.Alert.ServerID}})
+{{with .Alert.Check}}Check: {{.}}
+{{end}}{{with .Alert.Metric}}Metric: {{.}}
+{{end}}{{.Alert.Message}}
+`
+
+// Validate reports configuration mistakes in the notifier.
+func (c Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("notifier is missing a name")
+	}
+
+	switch c.Type {
+	case TypeWebhook, TypeSlack:
+		if c.URL == "" {
+			return fmt.Errorf("notifier %q is missing a url", c.Name)
+		}
+	case TypeEmail:
+		if c.SMTPHost == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("notifier %q needs smtp_host, from and to", c.Name)
+		}
+	default:
+		return fmt.Errorf("notifier %q has unknown type %q", c.Name, c.Type)
+	}
+
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("notifier %q has a negative max_attempts", c.Name)
+	}
+
+	if _, err := c.template(); err != nil {
+		return fmt.Errorf("notifier %q has an invalid template: %v", c.Name, err)
+	}
+
+	return nil
+}
+
+// ValidateConfigs validates every notifier, checks that names are unique and that
+// every receiver of the alert rules names a notifier.
+func ValidateConfigs(configs []Config, rules []alerts.Rule) error {
+	names := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if names[cfg.Name] {
+			return fmt.Errorf("duplicate notifier name %q", cfg.Name)
+		}
+		names[cfg.Name] = true
+	}
+
+	for _, rule := range rules {
+		for _, receiver := range rule.Receivers {
+			if !names[receiver] {
+				return fmt.Errorf("alert rule %q routes to unknown notifier %q", rule.Name, receiver)
+			}
+		}
+	}
+
+	return nil
+}
+
+// New creates the notifier described by cfg.
+func New(cfg Config) (Notifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := cfg.template()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case TypeWebhook:
+		return &webhookNotifier{url: cfg.URL, headers: cfg.Headers, tmpl: tmpl}, nil
+	case TypeSlack:
+		return &slackNotifier{url: cfg.URL, tmpl: tmpl}, nil
+	default:
+		return newEmailNotifier(cfg, tmpl), nil
+	}
+}
+
+func (c Config) template() (*template.Template, error) {
+	text := c.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+	return template.New(c.Name).Parse(text)
+}
+
+func (c Config) maxAttempts() int {
+	if c.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return c.MaxAttempts
+}
+
+func render(tmpl *template.Template, msg Message) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, msg); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
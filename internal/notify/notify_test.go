@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"Webhook", Config{Name: "hook", Type: TypeWebhook, URL: "https://example.com"}, false},
+		{"Slack", Config{Name: "slack", Type: TypeSlack, URL: "https://example.com"}, false},
+		{"Email", Config{Name: "mail", Type: TypeEmail, SMTPHost: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}, false},
+		{"MissingName", Config{Type: TypeWebhook, URL: "https://example.com"}, true},
+		{"UnknownType", Config{Name: "x", Type: "pager"}, true},
+		{"WebhookWithoutURL", Config{Name: "hook", Type: TypeWebhook}, true},
+		{"EmailWithoutRecipients", Config{Name: "mail", Type: TypeEmail, SMTPHost: "smtp.example.com", From: "a@example.com"}, true},
+		{"InvalidTemplate", Config{Name: "hook", Type: TypeWebhook, URL: "https://example.com", Template: "{{.Alert"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigs(t *testing.T) {
+	configs := []Config{{Name: "ops", Type: TypeSlack, URL: "https://example.com"}}
+
+	if err := ValidateConfigs(configs, []alerts.Rule{{Name: "r", Check: "ping", Receivers: []string{"ops"}}}); err != nil {
+		t.Errorf("Expected valid configuration, got %v", err)
+	}
+	if err := ValidateConfigs(configs, []alerts.Rule{{Name: "r", Check: "ping", Receivers: []string{"oncall"}}}); err == nil {
+		t.Error("Expected an error for an unknown receiver")
+	}
+	if err := ValidateConfigs(append(configs, configs[0]), nil); err == nil {
+		t.Error("Expected an error for duplicate notifier names")
+	}
+}
+
+func TestRenderDefaultTemplate(t *testing.T) {
+	tmpl, err := Config{Name: "test"}.template()
+	if err != nil {
+		t.Fatalf("Failed to parse default template: %v", err)
+	}
+
+	text, err := render(tmpl, testMessage(alerts.StateFiring))
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	for _, want := range []string{"FIRING [critical] ping-down on Test Server", "Check: ping", "connection refused"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	text, err = render(tmpl, testMessage(alerts.StateResolved))
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.HasPrefix(text, "RESOLVED [critical] ping-down on Test Server") {
+		t.Errorf("Expected a resolved message, got:\n%s", text)
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	tmpl, err := Config{Name: "test", Template: "{{.ServerName}}: {{.Alert.Check}} is {{.Alert.State}}"}.template()
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
+	}
+
+	text, err := render(tmpl, testMessage(alerts.StateFiring))
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if text != "Test Server: ping is firing" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
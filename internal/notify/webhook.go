@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookPayload is the JSON document posted by webhook notifiers.
+type webhookPayload struct {
+	Status     alerts.State `json:"status"`
+	ServerName string       `json:"server_name"`
+	Text       string       `json:"text"`
+	Alert      alerts.Alert `json:"alert"`
+}
+
+type webhookNotifier struct {
+	url     string
+	headers map[string]string
+	tmpl    *template.Template
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, msg Message) error {
+	text, err := render(n.tmpl, msg)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, n.url, n.headers, webhookPayload{
+		Status:     msg.Alert.State,
+		ServerName: msg.ServerName,
+		Text:       text,
+		Alert:      msg.Alert,
+	})
+}
+
+// slackNotifier posts to a Slack incoming webhook, or any service accepting its format.
+type slackNotifier struct {
+	url  string
+	tmpl *template.Template
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, msg Message) error {
+	text, err := render(n.tmpl, msg)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, n.url, nil, map[string]string{"text": text})
+}
+
+func postJSON(ctx context.Context, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification to %s returned status: %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"watchdog.onebusaway.org/internal/alerts"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload webhookPayload
+	var authHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	n, err := New(Config{Name: "hook", Type: TypeWebhook, URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), testMessage(alerts.StateFiring)); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if authHeader != "Bearer secret" {
+		t.Errorf("Expected configured header, got %q", authHeader)
+	}
+	if payload.Status != alerts.StateFiring || payload.ServerName != "Test Server" || payload.Alert.Check != "ping" {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if !strings.Contains(payload.Text, "ping-down") {
+		t.Errorf("Expected rendered text, got %q", payload.Text)
+	}
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var payload map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+
+	n, err := New(Config{Name: "slack", Type: TypeSlack, URL: ts.URL})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), testMessage(alerts.StateResolved)); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	if !strings.HasPrefix(payload["text"], "RESOLVED") {
+		t.Errorf("Expected a resolved Slack message, got %q", payload["text"])
+	}
+}
+
+func TestWebhookNotifierErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	n, _ := New(Config{Name: "hook", Type: TypeWebhook, URL: ts.URL})
+	if err := n.Notify(context.Background(), testMessage(alerts.StateFiring)); err == nil {
+		t.Error("Expected an error for a 502 response")
+	}
+}
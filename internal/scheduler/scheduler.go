@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// jitterFraction is the maximum share of an interval added to each wait so that
+// checks started together drift apart instead of hitting the servers at once.
+const jitterFraction = 0.1
+
+// maxFirstRunDelay bounds the random delay before the first run of a check, so that
+// checks with long intervals still report soon after startup or a config change.
+const maxFirstRunDelay = 5 * time.Second
+
+// Check is a unit of monitoring work that is run periodically against every server.
+type Check struct {
+	// Name identifies the check in results and in the per-server "checks" config.
+	Name string
+	// Interval and Timeout are the defaults used when a server does not override them.
+	Interval time.Duration
+	Timeout  time.Duration
+	// Enabled reports whether the check applies to the server. A nil Enabled means always.
+	Enabled func(server models.ObaServer) bool
+	// Run performs the check. It may return a measurement, such as the number of days
+	// until a bundle expires, which is reported in the Result. A nil value means none.
+	Run func(ctx context.Context, server models.ObaServer) (*float64, error)
+}
+
+// Value returns a pointer to v, for use as the measurement returned by Check.Run.
+func Value(v float64) *float64 {
+	return &v
+}
+
+// WithoutValue adapts a check function that reports no measurement to Check.Run.
+func WithoutValue(run func(ctx context.Context, server models.ObaServer) error) func(context.Context, models.ObaServer) (*float64, error) {
+	return func(ctx context.Context, server models.ObaServer) (*float64, error) {
+		return nil, run(ctx, server)
+	}
+}
+
+// EnabledFor reports whether the check runs against server, taking the server's
+// "checks" config into account.
+func (c Check) EnabledFor(server models.ObaServer) bool {
+	if c.Enabled != nil && !c.Enabled(server) {
+		return false
+	}
+	return !server.Checks[c.Name].Disabled
+}
+
+// IntervalFor returns how often the check runs against server, taking the server's
+// "checks" config into account.
+func (c Check) IntervalFor(server models.ObaServer) time.Duration {
+	if seconds := server.Checks[c.Name].IntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return c.Interval
+}
+
+// TimeoutFor returns how long a run of the check against server may take, taking the
+// server's "checks" config into account.
+func (c Check) TimeoutFor(server models.ObaServer) time.Duration {
+	if seconds := server.Checks[c.Name].TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return c.Timeout
+}
+
+// Result describes a single execution of a check against a server.
+type Result struct {
+	ServerID  int
+	Check     string
+	StartedAt time.Time
+	Duration  time.Duration
+	Err       error
+	// Value is the measurement returned by the check, if any.
+	Value *float64
+}
+
+// Scheduler runs every check for every server on its own interval and deadline.
+type Scheduler struct {
+	checks   []Check
+	onResult func(Result)
+
+	mu      sync.Mutex
+	servers map[int]*serverJobs
+	wg      sync.WaitGroup
+}
+
+type serverJobs struct {
+	server models.ObaServer
+	cancel context.CancelFunc
+}
+
+// New creates a Scheduler for the given checks. onResult, if not nil, is called
+// after every check execution, possibly from several goroutines at once.
+func New(checks []Check, onResult func(Result)) *Scheduler {
+	return &Scheduler{
+		checks:   checks,
+		onResult: onResult,
+		servers:  make(map[int]*serverJobs),
+	}
+}
+
+// Sync starts checks for new servers, stops checks for servers that are no longer
+// present and restarts checks for servers whose configuration changed.
+func (s *Scheduler) Sync(servers []models.ObaServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[int]models.ObaServer, len(servers))
+	for _, server := range servers {
+		wanted[server.ID] = server
+	}
+
+	for id, jobs := range s.servers {
+		server, ok := wanted[id]
+		if ok && reflect.DeepEqual(server, jobs.server) {
+			continue
+		}
+		jobs.cancel()
+		delete(s.servers, id)
+	}
+
+	for id, server := range wanted {
+		if _, ok := s.servers[id]; ok {
+			continue
+		}
+		s.servers[id] = s.start(server)
+	}
+}
+
+// Stop cancels all running checks and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	for id, jobs := range s.servers {
+		jobs.cancel()
+		delete(s.servers, id)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) start(server models.ObaServer) *serverJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	for _, check := range s.checks {
+		if !check.EnabledFor(server) {
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.loop(ctx, server, check, check.IntervalFor(server), check.TimeoutFor(server))
+	}
+
+	return &serverJobs{server: server, cancel: cancel}
+}
+
+// loop runs check against server every interval until ctx is cancelled. The first
+// run happens after a short random delay, see firstRunDelay, to spread checks out.
+func (s *Scheduler) loop(ctx context.Context, server models.ObaServer, check Check, interval, timeout time.Duration) {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(firstRunDelay(interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		s.runOnce(ctx, server, check, timeout)
+		timer.Reset(interval + randomDuration(time.Duration(float64(interval)*jitterFraction)))
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, server models.ObaServer, check Check, timeout time.Duration) {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	value, err := check.Run(checkCtx, server)
+
+	// Results of runs interrupted by Sync or Stop say nothing about the server.
+	if ctx.Err() != nil {
+		return
+	}
+
+	if s.onResult != nil {
+		s.onResult(Result{
+			ServerID:  server.ID,
+			Check:     check.Name,
+			StartedAt: startedAt,
+			Duration:  time.Since(startedAt),
+			Err:       err,
+			Value:     value,
+		})
+	}
+}
+
+// firstRunDelay returns a random delay of up to the jitter of interval, and at most
+// maxFirstRunDelay.
+func firstRunDelay(interval time.Duration) time.Duration {
+	return randomDuration(min(time.Duration(float64(interval)*jitterFraction), maxFirstRunDelay))
+}
+
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
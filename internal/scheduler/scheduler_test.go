@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// resultCollector records the results reported by a Scheduler.
+type resultCollector struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+func (c *resultCollector) add(result Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, result)
+}
+
+func (c *resultCollector) count(serverID int, check string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, result := range c.results {
+		if result.ServerID == serverID && result.Check == check {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *resultCollector) last(serverID int, check string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := len(c.results) - 1; i >= 0; i-- {
+		if c.results[i].ServerID == serverID && c.results[i].Check == check {
+			return c.results[i], true
+		}
+	}
+	return Result{}, false
+}
+
+func noop(ctx context.Context, server models.ObaServer) (*float64, error) {
+	return nil, nil
+}
+
+func TestSchedulerRunsChecksOnTheirOwnInterval(t *testing.T) {
+	collector := &resultCollector{}
+	s := New([]Check{
+		{Name: "fast", Interval: 10 * time.Millisecond, Timeout: time.Second, Run: noop},
+		{Name: "slow", Interval: time.Hour, Timeout: time.Second, Run: noop},
+	}, collector.add)
+
+	s.Sync([]models.ObaServer{{ID: 1}})
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	if n := collector.count(1, "fast"); n < 3 {
+		t.Errorf("Expected the fast check to run several times, ran %d times", n)
+	}
+	if n := collector.count(1, "slow"); n > 1 {
+		t.Errorf("Expected the slow check to run at most once, ran %d times", n)
+	}
+}
+
+func TestFirstRunDelay(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if delay := firstRunDelay(time.Hour); delay < 0 || delay > maxFirstRunDelay {
+			t.Fatalf("Expected an hourly check to start within %v, got %v", maxFirstRunDelay, delay)
+		}
+		if delay := firstRunDelay(10 * time.Second); delay > time.Second {
+			t.Fatalf("Expected the first delay to stay within the jitter of the interval, got %v", delay)
+		}
+	}
+}
+
+func TestSchedulerAppliesTimeout(t *testing.T) {
+	collector := &resultCollector{}
+	s := New([]Check{{
+		Name:     "hang",
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Hour,
+		Run: WithoutValue(func(ctx context.Context, server models.ObaServer) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	}}, collector.add)
+
+	// The per-server setting overrides the default timeout of an hour.
+	s.Sync([]models.ObaServer{{ID: 1, Checks: map[string]models.CheckSettings{
+		"hang": {TimeoutSeconds: 1},
+	}}})
+	time.Sleep(1500 * time.Millisecond)
+	s.Stop()
+
+	result, ok := collector.last(1, "hang")
+	if !ok {
+		t.Fatal("Expected the hanging check to report a result")
+	}
+	if !errors.Is(result.Err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline exceeded error, got %v", result.Err)
+	}
+	if result.Duration < time.Second || result.Duration > 1400*time.Millisecond {
+		t.Errorf("Expected the check to be cut off after about a second, took %v", result.Duration)
+	}
+}
+
+func TestSchedulerSlowServerDoesNotBlockOthers(t *testing.T) {
+	collector := &resultCollector{}
+	s := New([]Check{{
+		Name:     "ping",
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Second,
+		Run: func(ctx context.Context, server models.ObaServer) (*float64, error) {
+			if server.ID == 1 {
+				<-ctx.Done()
+			}
+			return nil, nil
+		},
+	}}, collector.add)
+
+	s.Sync([]models.ObaServer{{ID: 1}, {ID: 2}})
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	if n := collector.count(2, "ping"); n < 3 {
+		t.Errorf("Expected server 2 to be checked while server 1 hangs, ran %d times", n)
+	}
+}
+
+func TestSchedulerSync(t *testing.T) {
+	collector := &resultCollector{}
+	s := New([]Check{
+		{Name: "ping", Interval: 10 * time.Millisecond, Timeout: time.Second, Run: noop},
+		{
+			Name:     "trip_updates",
+			Interval: 10 * time.Millisecond,
+			Timeout:  time.Second,
+			Enabled:  func(server models.ObaServer) bool { return server.TripUpdateUrl != "" },
+			Run:      noop,
+		},
+	}, collector.add)
+	defer s.Stop()
+
+	s.Sync([]models.ObaServer{{ID: 1}, {ID: 2, Checks: map[string]models.CheckSettings{"ping": {Disabled: true}}}})
+	time.Sleep(60 * time.Millisecond)
+
+	if collector.count(1, "ping") == 0 {
+		t.Error("Expected server 1 to be pinged")
+	}
+	if collector.count(1, "trip_updates") != 0 {
+		t.Error("Expected trip updates check to be skipped for a server without a trip updates URL")
+	}
+	if collector.count(2, "ping") != 0 {
+		t.Error("Expected disabled ping check not to run for server 2")
+	}
+
+	// Removing server 1 stops its checks.
+	s.Sync([]models.ObaServer{{ID: 2}})
+	time.Sleep(30 * time.Millisecond)
+	before := collector.count(1, "ping")
+	time.Sleep(60 * time.Millisecond)
+	if after := collector.count(1, "ping"); after != before {
+		t.Errorf("Expected no more checks for removed server 1, got %d more", after-before)
+	}
+
+	// Server 2 changed, so its ping check is now scheduled.
+	if collector.count(2, "ping") == 0 {
+		t.Error("Expected server 2 to be pinged after its config changed")
+	}
+}
+
+func TestCheckEnabledFor(t *testing.T) {
+	check := Check{
+		Name:    "trip_updates",
+		Enabled: func(server models.ObaServer) bool { return server.TripUpdateUrl != "" },
+	}
+
+	if check.EnabledFor(models.ObaServer{ID: 1}) {
+		t.Error("Expected check to be disabled without a trip update URL")
+	}
+	if !check.EnabledFor(models.ObaServer{ID: 1, TripUpdateUrl: "https://example.com"}) {
+		t.Error("Expected check to be enabled with a trip update URL")
+	}
+
+	disabled := models.ObaServer{
+		ID:            1,
+		TripUpdateUrl: "https://example.com",
+		Checks:        map[string]models.CheckSettings{"trip_updates": {Disabled: true}},
+	}
+	if check.EnabledFor(disabled) {
+		t.Error("Expected check disabled in the server config to be disabled")
+	}
+}
+
+func TestCheckIntervalAndTimeoutFor(t *testing.T) {
+	check := Check{Name: "ping", Interval: 15 * time.Second, Timeout: 10 * time.Second}
+
+	server := models.ObaServer{ID: 1}
+	if check.IntervalFor(server) != 15*time.Second || check.TimeoutFor(server) != 10*time.Second {
+		t.Errorf("Expected the check defaults, got %v and %v", check.IntervalFor(server), check.TimeoutFor(server))
+	}
+
+	server.Checks = map[string]models.CheckSettings{"ping": {IntervalSeconds: 60, TimeoutSeconds: 30}}
+	if check.IntervalFor(server) != time.Minute || check.TimeoutFor(server) != 30*time.Second {
+		t.Errorf("Expected the server overrides, got %v and %v", check.IntervalFor(server), check.TimeoutFor(server))
+	}
+}
+
+func TestSchedulerReportsValue(t *testing.T) {
+	collector := &resultCollector{}
+	s := New([]Check{
+		{Name: "measure", Interval: 10 * time.Millisecond, Timeout: time.Second, Run: func(ctx context.Context, server models.ObaServer) (*float64, error) {
+			return Value(42), nil
+		}},
+		{Name: "plain", Interval: 10 * time.Millisecond, Timeout: time.Second, Run: noop},
+	}, collector.add)
+
+	s.Sync([]models.ObaServer{{ID: 1}})
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+
+	result, ok := collector.last(1, "measure")
+	if !ok || result.Value == nil || *result.Value != 42 {
+		t.Errorf("Expected the reported value 42, got %+v", result)
+	}
+	if result, ok := collector.last(1, "plain"); ok && result.Value != nil {
+		t.Errorf("Expected no value for a check that does not report one, got %v", *result.Value)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+// ValidateServers checks that a server list can replace the running configuration:
+// it must not be empty and every server needs a unique positive ID, which keys its
+// checks, metrics and cached bundle.
+func ValidateServers(servers []models.ObaServer) error {
+	if len(servers) == 0 {
+		return fmt.Errorf("no servers configured")
+	}
+
+	seen := make(map[int]bool, len(servers))
+	for i, server := range servers {
+		if server.ID <= 0 {
+			return fmt.Errorf("server %d (%q) has no valid id", i, server.Name)
+		}
+		if seen[server.ID] {
+			return fmt.Errorf("server id %d is used more than once", server.ID)
+		}
+		seen[server.ID] = true
+	}
+	return nil
+}
+
+// ServerChanges lists the IDs of the servers that differ between two configurations.
+type ServerChanges struct {
+	Added   []int
+	Removed []int
+	Changed []int
+}
+
+// Empty reports whether the configurations have the same servers.
+func (c ServerChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// DiffServers compares the servers of the running configuration with a new one. A server
+// changed when any of its settings differ, the same comparison the scheduler uses to
+// decide whether to restart its checks.
+func DiffServers(previous, next []models.ObaServer) ServerChanges {
+	before := make(map[int]models.ObaServer, len(previous))
+	for _, server := range previous {
+		before[server.ID] = server
+	}
+
+	var changes ServerChanges
+	after := make(map[int]bool, len(next))
+	for _, server := range next {
+		after[server.ID] = true
+		old, ok := before[server.ID]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, server.ID)
+		case !reflect.DeepEqual(old, server):
+			changes.Changed = append(changes.Changed, server.ID)
+		}
+	}
+	for id := range before {
+		if !after[id] {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+
+	sort.Ints(changes.Added)
+	sort.Ints(changes.Removed)
+	sort.Ints(changes.Changed)
+	return changes
+}
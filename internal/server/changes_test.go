@@ -0,0 +1,53 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+func TestValidateServers(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []models.ObaServer
+		wantErr bool
+	}{
+		{"Valid", []models.ObaServer{{ID: 1, ObaBaseURL: "https://a.example.com"}, {ID: 2, ObaBaseURL: "https://b.example.com"}}, false},
+		{"Empty", nil, true},
+		{"MissingID", []models.ObaServer{{ObaBaseURL: "https://a.example.com"}}, true},
+		{"DuplicateID", []models.ObaServer{{ID: 1, ObaBaseURL: "https://a.example.com"}, {ID: 1, ObaBaseURL: "https://b.example.com"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServers(tt.servers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateServers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiffServers(t *testing.T) {
+	previous := []models.ObaServer{
+		{ID: 1, Name: "Unchanged"},
+		{ID: 2, Name: "Removed"},
+		{ID: 3, Name: "Before"},
+	}
+	next := []models.ObaServer{
+		{ID: 4, Name: "Added"},
+		{ID: 3, Name: "After"},
+		{ID: 1, Name: "Unchanged"},
+	}
+
+	changes := DiffServers(previous, next)
+	expected := ServerChanges{Added: []int{4}, Removed: []int{2}, Changed: []int{3}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+
+	if !DiffServers(previous, previous).Empty() {
+		t.Error("Expected no changes between identical configurations")
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"watchdog.onebusaway.org/internal/alerts"
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/notify"
+)
+
+// ConfigFile is the JSON configuration document loaded with --config-file or --config-url.
+type ConfigFile struct {
+	Servers    []models.ObaServer `json:"servers"`
+	AlertRules []alerts.Rule      `json:"alert_rules,omitempty"`
+	Notifiers  []notify.Config    `json:"notifiers,omitempty"`
+}
+
+// ParseConfigFile parses a configuration document. The document is either an object
+// with "servers" and optional "alert_rules" and "notifiers", or, for older configurations, a plain
+// array of servers.
+func ParseConfigFile(data []byte) (*ConfigFile, error) {
+	var cfg ConfigFile
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &cfg.Servers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+		return &cfg, nil
+	}
+
+	if err := json.Unmarshal(trimmed, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	if err := alerts.ValidateRules(cfg.AlertRules); err != nil {
+		return nil, fmt.Errorf("invalid alert rules: %v", err)
+	}
+
+	if err := notify.ValidateConfigs(cfg.Notifiers, cfg.AlertRules); err != nil {
+		return nil, fmt.Errorf("invalid notifiers: %v", err)
+	}
+
+	return &cfg, nil
+}
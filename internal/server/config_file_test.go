@@ -0,0 +1,82 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	t.Run("LegacyServerArray", func(t *testing.T) {
+		cfg, err := ParseConfigFile([]byte(`[{"name": "Test Server", "id": 1}]`))
+		if err != nil {
+			t.Fatalf("ParseConfigFile failed: %v", err)
+		}
+		if len(cfg.Servers) != 1 || cfg.Servers[0].ID != 1 {
+			t.Errorf("Expected server 1, got %+v", cfg.Servers)
+		}
+		if len(cfg.AlertRules) != 0 {
+			t.Errorf("Expected no alert rules, got %d", len(cfg.AlertRules))
+		}
+	})
+
+	t.Run("ServersAndAlertRules", func(t *testing.T) {
+		cfg, err := ParseConfigFile([]byte(`{
+			"servers": [{"name": "Test Server", "id": 1}],
+			"alert_rules": [
+				{"name": "ping-down", "check": "ping", "severity": "critical", "for_seconds": 60},
+				{"name": "bundle-expiring", "metric": "gtfs_bundle_days_until_earliest_expiration", "operator": "<", "threshold": 7}
+			]
+		}`))
+		if err != nil {
+			t.Fatalf("ParseConfigFile failed: %v", err)
+		}
+		if len(cfg.Servers) != 1 {
+			t.Errorf("Expected 1 server, got %d", len(cfg.Servers))
+		}
+		if len(cfg.AlertRules) != 2 {
+			t.Fatalf("Expected 2 alert rules, got %d", len(cfg.AlertRules))
+		}
+		if cfg.AlertRules[0].ForSeconds != 60 || cfg.AlertRules[1].Threshold != 7 {
+			t.Errorf("Alert rules not parsed correctly: %+v", cfg.AlertRules)
+		}
+	})
+
+	t.Run("InvalidAlertRule", func(t *testing.T) {
+		_, err := ParseConfigFile([]byte(`{
+			"servers": [{"name": "Test Server", "id": 1}],
+			"alert_rules": [{"name": "broken", "metric": "x", "operator": "~"}]
+		}`))
+		if err == nil {
+			t.Error("Expected an error for an invalid alert rule")
+		}
+	})
+
+	t.Run("Notifiers", func(t *testing.T) {
+		cfg, err := ParseConfigFile([]byte(`{
+			"servers": [{"name": "Test Server", "id": 1}],
+			"alert_rules": [{"name": "ping-down", "check": "ping", "receivers": ["ops"]}],
+			"notifiers": [{"name": "ops", "type": "slack", "url": "https://hooks.example.com/x"}]
+		}`))
+		if err != nil {
+			t.Fatalf("ParseConfigFile failed: %v", err)
+		}
+		if len(cfg.Notifiers) != 1 || cfg.Notifiers[0].Type != "slack" {
+			t.Errorf("Notifiers not parsed correctly: %+v", cfg.Notifiers)
+		}
+	})
+
+	t.Run("UnknownReceiver", func(t *testing.T) {
+		_, err := ParseConfigFile([]byte(`{
+			"servers": [{"name": "Test Server", "id": 1}],
+			"alert_rules": [{"name": "ping-down", "check": "ping", "receivers": ["ops"]}]
+		}`))
+		if err == nil {
+			t.Error("Expected an error for a rule routed to an unknown notifier")
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		if _, err := ParseConfigFile([]byte(`{invalid}`)); err == nil {
+			t.Error("Expected an error for invalid JSON")
+		}
+	})
+}
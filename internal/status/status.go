@@ -0,0 +1,217 @@
+// Package status keeps the latest result of every check for every server.
+package status
+
+import (
+	"sync"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+	"watchdog.onebusaway.org/internal/scheduler"
+)
+
+// Status summarizes the health of a check or a server.
+type Status string
+
+const (
+	// StatusOK means the check passed on its last run, or every check of a server did.
+	StatusOK Status = "ok"
+	// StatusFailing means the check failed on its last run.
+	StatusFailing Status = "failing"
+	// StatusDegraded means the server is reachable but at least one check failed.
+	StatusDegraded Status = "degraded"
+	// StatusDown means the server failed its ping check.
+	StatusDown Status = "down"
+	// StatusUnknown means the check, or every check of a server, has not run yet.
+	StatusUnknown Status = "unknown"
+)
+
+// pingCheck is the check whose failure marks a server as down.
+const pingCheck = "ping"
+
+// HistoryRetention is how long check results are kept in the history.
+const HistoryRetention = 24 * time.Hour
+
+// CheckStatus is the latest result of one check for one server.
+type CheckStatus struct {
+	Name        string     `json:"name"`
+	Status      Status     `json:"status"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	DurationMs  float64    `json:"duration_ms"`
+	Error       string     `json:"error,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// Sample is one check result in the history of a server.
+type Sample struct {
+	Check    string        `json:"check"`
+	At       time.Time     `json:"at"`
+	Duration time.Duration `json:"duration"`
+	OK       bool          `json:"ok"`
+}
+
+// Store records the latest check results and the results of the last
+// HistoryRetention. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	servers map[int]map[string]CheckStatus
+	history map[int][]Sample
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	return &Store{
+		servers: make(map[int]map[string]CheckStatus),
+		history: make(map[int][]Sample),
+	}
+}
+
+// Record stores the result of a check execution.
+func (s *Store) Record(result scheduler.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checks, ok := s.servers[result.ServerID]
+	if !ok {
+		checks = make(map[string]CheckStatus)
+		s.servers[result.ServerID] = checks
+	}
+
+	previous := checks[result.Check]
+	startedAt := result.StartedAt
+	current := CheckStatus{
+		Name:        result.Check,
+		Status:      StatusOK,
+		LastRun:     &startedAt,
+		DurationMs:  float64(result.Duration) / float64(time.Millisecond),
+		LastSuccess: previous.LastSuccess,
+	}
+	if result.Err != nil {
+		current.Status = StatusFailing
+		current.Error = result.Err.Error()
+	} else {
+		current.LastSuccess = &startedAt
+	}
+
+	checks[result.Check] = current
+
+	history := append(s.history[result.ServerID], Sample{
+		Check:    result.Check,
+		At:       result.StartedAt,
+		Duration: result.Duration,
+		OK:       result.Err == nil,
+	})
+	s.history[result.ServerID] = pruneHistory(history, result.StartedAt.Add(-HistoryRetention))
+}
+
+// History returns the samples of a server recorded at or after since, oldest first.
+func (s *Store) History(serverID int, since time.Time) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var samples []Sample
+	for _, sample := range s.history[serverID] {
+		if !sample.At.Before(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// pruneHistory drops samples older than cutoff. Samples are appended in roughly
+// chronological order, so only the front of the slice needs to be inspected.
+func pruneHistory(history []Sample, cutoff time.Time) []Sample {
+	i := 0
+	for i < len(history) && history[i].At.Before(cutoff) {
+		i++
+	}
+	if i == 0 {
+		return history
+	}
+	return append(history[:0:0], history[i:]...)
+}
+
+// Checks returns the status of the named checks of a server, in the given order.
+// Checks without a result are reported as StatusUnknown.
+func (s *Store) Checks(serverID int, names []string) []CheckStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]CheckStatus, 0, len(names))
+	for _, name := range names {
+		check, ok := s.servers[serverID][name]
+		if !ok {
+			check = CheckStatus{Name: name, Status: StatusUnknown}
+		}
+		statuses = append(statuses, check)
+	}
+	return statuses
+}
+
+// Retain forgets the results of servers that are not listed.
+func (s *Store) Retain(serverIDs []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	models.RetainServers(s.servers, serverIDs)
+	models.RetainServers(s.history, serverIDs)
+}
+
+// Overall derives the status of a server from the status of its checks.
+func Overall(checks []CheckStatus) Status {
+	overall := StatusUnknown
+	for _, check := range checks {
+		switch check.Status {
+		case StatusFailing:
+			if check.Name == pingCheck {
+				return StatusDown
+			}
+			overall = StatusDegraded
+		case StatusOK:
+			if overall == StatusUnknown {
+				overall = StatusOK
+			}
+		}
+	}
+	return overall
+}
+
+// Bucket summarizes the samples recorded in one slice of time.
+type Bucket struct {
+	Start  time.Time
+	Total  int
+	Failed int
+}
+
+// SuccessRatio returns the share of successful samples in the bucket, or -1 if it is empty.
+func (b Bucket) SuccessRatio() float64 {
+	if b.Total == 0 {
+		return -1
+	}
+	return float64(b.Total-b.Failed) / float64(b.Total)
+}
+
+// Buckets divides [start, end) into n equal buckets and counts the samples in each.
+func Buckets(samples []Sample, start, end time.Time, n int) []Bucket {
+	if n <= 0 || !end.After(start) {
+		return nil
+	}
+
+	width := end.Sub(start) / time.Duration(n)
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].Start = start.Add(time.Duration(i) * width)
+	}
+
+	for _, sample := range samples {
+		if sample.At.Before(start) || !sample.At.Before(end) {
+			continue
+		}
+		i := min(int(sample.At.Sub(start)/width), n-1)
+		buckets[i].Total++
+		if !sample.OK {
+			buckets[i].Failed++
+		}
+	}
+
+	return buckets
+}
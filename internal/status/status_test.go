@@ -0,0 +1,125 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/scheduler"
+)
+
+func TestStoreRecord(t *testing.T) {
+	store := NewStore()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: start, Duration: 250 * time.Millisecond})
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: start.Add(time.Minute), Err: errors.New("timeout")})
+
+	checks := store.Checks(1, []string{"ping", "vehicle_count"})
+	if len(checks) != 2 {
+		t.Fatalf("Expected 2 checks, got %d", len(checks))
+	}
+
+	ping := checks[0]
+	if ping.Status != StatusFailing || ping.Error != "timeout" {
+		t.Errorf("Expected failing ping with error, got %+v", ping)
+	}
+	if ping.LastRun == nil || !ping.LastRun.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected last run to be the latest result, got %v", ping.LastRun)
+	}
+	if ping.LastSuccess == nil || !ping.LastSuccess.Equal(start) {
+		t.Errorf("Expected last success to be kept from the earlier run, got %v", ping.LastSuccess)
+	}
+
+	if checks[1].Status != StatusUnknown {
+		t.Errorf("Expected a check without results to be unknown, got %+v", checks[1])
+	}
+}
+
+func TestStoreRetain(t *testing.T) {
+	store := NewStore()
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping"})
+	store.Record(scheduler.Result{ServerID: 2, Check: "ping"})
+
+	store.Retain([]int{2})
+
+	if got := store.Checks(1, []string{"ping"})[0].Status; got != StatusUnknown {
+		t.Errorf("Expected results of server 1 to be dropped, got %s", got)
+	}
+	if got := store.Checks(2, []string{"ping"})[0].Status; got != StatusOK {
+		t.Errorf("Expected results of server 2 to be kept, got %s", got)
+	}
+}
+
+func TestOverall(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []CheckStatus
+		want   Status
+	}{
+		{"NoChecks", nil, StatusUnknown},
+		{"AllUnknown", []CheckStatus{{Name: "ping", Status: StatusUnknown}}, StatusUnknown},
+		{"AllOK", []CheckStatus{{Name: "ping", Status: StatusOK}, {Name: "vehicle_count", Status: StatusOK}}, StatusOK},
+		{"CheckFailing", []CheckStatus{{Name: "ping", Status: StatusOK}, {Name: "vehicle_count", Status: StatusFailing}}, StatusDegraded},
+		{"PingFailing", []CheckStatus{{Name: "vehicle_count", Status: StatusFailing}, {Name: "ping", Status: StatusFailing}}, StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Overall(tt.checks); got != tt.want {
+				t.Errorf("Overall() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	store := NewStore()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: start})
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: start.Add(time.Hour), Err: errors.New("timeout")})
+
+	history := store.History(1, start)
+	if len(history) != 2 || history[1].OK {
+		t.Fatalf("Expected 2 samples with the second failing, got %+v", history)
+	}
+
+	if got := store.History(1, start.Add(30*time.Minute)); len(got) != 1 {
+		t.Errorf("Expected 1 sample since the cutoff, got %d", len(got))
+	}
+
+	// Recording a result a day later drops samples older than the retention.
+	store.Record(scheduler.Result{ServerID: 1, Check: "ping", StartedAt: start.Add(HistoryRetention + 30*time.Minute)})
+	if got := store.History(1, time.Time{}); len(got) != 2 {
+		t.Errorf("Expected the first sample to be pruned, got %+v", got)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{At: start.Add(10 * time.Minute), OK: true},
+		{At: start.Add(20 * time.Minute), OK: false},
+		{At: start.Add(3 * time.Hour), OK: true},
+		{At: start.Add(5 * time.Hour), OK: true}, // outside the range
+	}
+
+	buckets := Buckets(samples, start, start.Add(4*time.Hour), 4)
+	if len(buckets) != 4 {
+		t.Fatalf("Expected 4 buckets, got %d", len(buckets))
+	}
+
+	if buckets[0].Total != 2 || buckets[0].Failed != 1 || buckets[0].SuccessRatio() != 0.5 {
+		t.Errorf("Unexpected first bucket %+v", buckets[0])
+	}
+	if buckets[1].SuccessRatio() != -1 {
+		t.Errorf("Expected an empty second bucket, got %+v", buckets[1])
+	}
+	if buckets[3].Total != 1 || buckets[3].SuccessRatio() != 1 {
+		t.Errorf("Unexpected last bucket %+v", buckets[3])
+	}
+	if !buckets[2].Start.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Unexpected bucket start %v", buckets[2].Start)
+	}
+}
@@ -0,0 +1,151 @@
+// Package uptime computes availability figures of a server from its ping history.
+package uptime
+
+import (
+	"sort"
+	"time"
+
+	"watchdog.onebusaway.org/internal/history"
+)
+
+// Check is the check whose results define whether a server is available.
+const Check = "ping"
+
+// SampleSpan returns the longest time a single ping result is assumed to hold, for
+// pings that start every interval and take up to timeout. It leaves room for one late
+// ping. Longer gaps, e.g. while the watchdog itself was down, count neither as up nor
+// as down.
+func SampleSpan(interval, timeout time.Duration) time.Duration {
+	return 2*interval + timeout
+}
+
+// Window is a rolling period availability is reported for.
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Windows are the rolling periods reported for every server.
+var Windows = []Window{
+	{Name: "1h", Duration: time.Hour},
+	{Name: "24h", Duration: 24 * time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour},
+	{Name: "30d", Duration: 30 * 24 * time.Hour},
+}
+
+// Report summarizes the availability of a server over a period.
+type Report struct {
+	Window string    `json:"window,omitempty"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// AvailabilityPercent is nil when no results were recorded in the period.
+	AvailabilityPercent *float64 `json:"availability_percent"`
+	// MonitoredSeconds is the part of the period covered by results.
+	MonitoredSeconds float64 `json:"monitored_seconds"`
+	DowntimeSeconds  float64 `json:"downtime_seconds"`
+	Outages          int     `json:"outages"`
+	// OngoingOutage is true if the server was down at the end of the period.
+	OngoingOutage bool `json:"ongoing_outage"`
+	// MeanTimeToRecoverySeconds averages the outages that ended within the period.
+	MeanTimeToRecoverySeconds *float64 `json:"mean_time_to_recovery_seconds"`
+	LongestOutageSeconds      float64  `json:"longest_outage_seconds"`
+}
+
+// Compute builds the report for [from, to) from ping records, each holding for at most
+// maxSpan, see SampleSpan. Records outside the period are ignored. An outage followed
+// by a gap in the records ends with its last result and has no recovery time.
+func Compute(records []history.Record, from, to time.Time, maxSpan time.Duration) Report {
+	report := Report{From: from, To: to}
+
+	var samples []history.Record
+	for _, record := range records {
+		if record.Check == Check && !record.At.Before(from) && record.At.Before(to) {
+			samples = append(samples, record)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].At.Before(samples[j].At) })
+
+	var up, down time.Duration
+	var recovered []time.Duration
+	var outageStart, outageEnd time.Time
+	inOutage := false
+
+	for i, sample := range samples {
+		end := to
+		gap := false
+		if i+1 < len(samples) {
+			end = samples[i+1].At
+			gap = end.Sub(sample.At) > maxSpan
+		}
+		span := min(end.Sub(sample.At), maxSpan)
+
+		if sample.OK {
+			up += span
+			if inOutage {
+				outage := sample.At.Sub(outageStart)
+				recovered = append(recovered, outage)
+				report.LongestOutageSeconds = max(report.LongestOutageSeconds, outage.Seconds())
+				inOutage = false
+			}
+			continue
+		}
+
+		down += span
+		if !inOutage {
+			inOutage = true
+			outageStart = sample.At
+			report.Outages++
+		}
+		outageEnd = sample.At.Add(span)
+
+		// Whether the server recovered during the gap is unknown.
+		if gap {
+			report.LongestOutageSeconds = max(report.LongestOutageSeconds, outageEnd.Sub(outageStart).Seconds())
+			inOutage = false
+		}
+	}
+
+	if inOutage {
+		report.OngoingOutage = true
+		report.LongestOutageSeconds = max(report.LongestOutageSeconds, outageEnd.Sub(outageStart).Seconds())
+	}
+
+	report.MonitoredSeconds = (up + down).Seconds()
+	report.DowntimeSeconds = down.Seconds()
+	if up+down > 0 {
+		availability := 100 * float64(up) / float64(up+down)
+		report.AvailabilityPercent = &availability
+	}
+
+	if len(recovered) > 0 {
+		var total time.Duration
+		for _, outage := range recovered {
+			total += outage
+		}
+		mttr := (total / time.Duration(len(recovered))).Seconds()
+		report.MeanTimeToRecoverySeconds = &mttr
+	}
+
+	return report
+}
+
+// Rolling computes a report for every window ending at now. records must cover the
+// longest window.
+func Rolling(records []history.Record, now time.Time, maxSpan time.Duration) []Report {
+	reports := make([]Report, 0, len(Windows))
+	for _, window := range Windows {
+		report := Compute(records, now.Add(-window.Duration), now, maxSpan)
+		report.Window = window.Name
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// LongestWindow returns the duration of the longest rolling window.
+func LongestWindow() time.Duration {
+	longest := time.Duration(0)
+	for _, window := range Windows {
+		longest = max(longest, window.Duration)
+	}
+	return longest
+}
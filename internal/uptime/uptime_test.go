@@ -0,0 +1,153 @@
+package uptime
+
+import (
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/history"
+)
+
+// span is the sample span of pings started every minute.
+var span = SampleSpan(time.Minute, 10*time.Second)
+
+// pings returns one ping record per minute starting at start, failing where states is false.
+func pings(start time.Time, states ...bool) []history.Record {
+	records := make([]history.Record, 0, len(states))
+	for i, ok := range states {
+		records = append(records, history.Record{ServerID: 1, Check: Check, At: start.Add(time.Duration(i) * time.Minute), OK: ok})
+	}
+	return records
+}
+
+func TestComputeAllUp(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Compute(pings(start, true, true, true, true), start, start.Add(4*time.Minute), span)
+
+	if report.AvailabilityPercent == nil || *report.AvailabilityPercent != 100 {
+		t.Errorf("Expected 100%% availability, got %v", report.AvailabilityPercent)
+	}
+	if report.Outages != 0 || report.MeanTimeToRecoverySeconds != nil {
+		t.Errorf("Expected no outages, got %+v", report)
+	}
+}
+
+func TestComputeOutages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// up, down, down, up, up, down, up, up, up, up
+	records := pings(start, true, false, false, true, true, false, true, true, true, true)
+	report := Compute(records, start, start.Add(10*time.Minute), span)
+
+	if report.Outages != 2 {
+		t.Errorf("Expected 2 outages, got %d", report.Outages)
+	}
+	if report.DowntimeSeconds != 180 {
+		t.Errorf("Expected 180s downtime, got %v", report.DowntimeSeconds)
+	}
+	if *report.AvailabilityPercent != 70 {
+		t.Errorf("Expected 70%% availability, got %v", *report.AvailabilityPercent)
+	}
+	if report.MeanTimeToRecoverySeconds == nil || *report.MeanTimeToRecoverySeconds != 90 {
+		t.Errorf("Expected a 90s MTTR, got %v", report.MeanTimeToRecoverySeconds)
+	}
+	if report.LongestOutageSeconds != 120 {
+		t.Errorf("Expected a 120s longest outage, got %v", report.LongestOutageSeconds)
+	}
+	if report.OngoingOutage {
+		t.Error("Expected no ongoing outage")
+	}
+}
+
+func TestComputeOngoingOutage(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Compute(pings(start, true, false, false), start, start.Add(3*time.Minute), span)
+
+	if !report.OngoingOutage || report.Outages != 1 {
+		t.Errorf("Expected one ongoing outage, got %+v", report)
+	}
+	if report.MeanTimeToRecoverySeconds != nil {
+		t.Errorf("Expected no MTTR without a recovery, got %v", *report.MeanTimeToRecoverySeconds)
+	}
+	if report.LongestOutageSeconds != 120 {
+		t.Errorf("Expected the ongoing outage to count as longest, got %v", report.LongestOutageSeconds)
+	}
+}
+
+func TestComputeIgnoresGapsAndOtherChecks(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []history.Record{
+		{Check: Check, At: start, OK: true},
+		// The watchdog was not running for an hour.
+		{Check: Check, At: start.Add(time.Hour), OK: false},
+		{Check: "vehicle_count", At: start.Add(time.Hour + time.Minute), OK: true},
+	}
+	report := Compute(records, start, start.Add(time.Hour+time.Minute), span)
+
+	if report.MonitoredSeconds != (span + time.Minute).Seconds() {
+		t.Errorf("Expected gaps to be capped, monitored %v seconds", report.MonitoredSeconds)
+	}
+	if report.DowntimeSeconds != 60 {
+		t.Errorf("Expected 60s downtime, got %v", report.DowntimeSeconds)
+	}
+}
+
+func TestComputeEndsOutagesAtGaps(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []history.Record{
+		{Check: Check, At: start, OK: false},
+		{Check: Check, At: start.Add(time.Minute), OK: false},
+		// The watchdog was not running until the server was back up.
+		{Check: Check, At: start.Add(time.Hour), OK: true},
+	}
+	report := Compute(records, start, start.Add(time.Hour+time.Minute), span)
+
+	if report.Outages != 1 || report.OngoingOutage {
+		t.Errorf("Expected one ended outage, got %+v", report)
+	}
+	if report.LongestOutageSeconds != (span + time.Minute).Seconds() {
+		t.Errorf("Expected the outage to end at the gap, got %v seconds", report.LongestOutageSeconds)
+	}
+	if report.MeanTimeToRecoverySeconds != nil {
+		t.Errorf("Expected no MTTR for an outage ended by a gap, got %v", *report.MeanTimeToRecoverySeconds)
+	}
+}
+
+func TestSampleSpan(t *testing.T) {
+	if got := SampleSpan(15*time.Second, 10*time.Second); got != 40*time.Second {
+		t.Errorf("Expected a 40s span, got %v", got)
+	}
+}
+
+func TestComputeNoData(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Compute(nil, start, start.Add(time.Hour), span)
+
+	if report.AvailabilityPercent != nil {
+		t.Errorf("Expected no availability without data, got %v", *report.AvailabilityPercent)
+	}
+}
+
+func TestRolling(t *testing.T) {
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	records := []history.Record{
+		{Check: Check, At: now.Add(-10 * 24 * time.Hour), OK: false},
+		{Check: Check, At: now.Add(-10*24*time.Hour + time.Minute), OK: true},
+		{Check: Check, At: now.Add(-time.Minute), OK: true},
+	}
+
+	reports := Rolling(records, now, span)
+	if len(reports) != len(Windows) {
+		t.Fatalf("Expected %d reports, got %d", len(Windows), len(reports))
+	}
+	if reports[0].Window != "1h" || *reports[0].AvailabilityPercent != 100 {
+		t.Errorf("Unexpected 1h report %+v", reports[0])
+	}
+	if reports[2].Window != "7d" || reports[2].Outages != 0 {
+		t.Errorf("Expected no outage in the last 7 days, got %+v", reports[2])
+	}
+	if reports[3].Window != "30d" || reports[3].Outages != 1 {
+		t.Errorf("Expected one outage in the last 30 days, got %+v", reports[3])
+	}
+	if LongestWindow() != 30*24*time.Hour {
+		t.Errorf("Unexpected longest window %v", LongestWindow())
+	}
+}
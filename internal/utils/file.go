@@ -20,7 +20,8 @@ func GetLastCachedFile(cacheDir string, serverID int) (string, error) {
 	serverPrefix := fmt.Sprintf("server_%d_", serverID)
 
 	for _, file := range files {
-		if !file.IsDir() && strings.HasPrefix(file.Name(), serverPrefix) {
+		// Only complete bundles count, not partial downloads or their metadata.
+		if !file.IsDir() && strings.HasPrefix(file.Name(), serverPrefix) && strings.HasSuffix(file.Name(), ".zip") {
 			fileInfo, err := file.Info()
 			if err != nil {
 				return "", err
@@ -1,36 +1,203 @@
 package utils
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 )
 
-func DownloadGTFSBundle(url string, cacheDir string, serverID int, hashStr string) (string, error) {
-	resp, err := http.Get(url)
+// requiredGTFSFiles must be present in every downloaded bundle. In addition a bundle
+// needs at least one of calendar.txt and calendar_dates.txt.
+var requiredGTFSFiles = []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt"}
+
+var downloadClient = &http.Client{Timeout: 15 * time.Minute}
+
+// DownloadResult describes the outcome of a GTFS bundle download.
+type DownloadResult struct {
+	Path string
+	// NotModified is true when the server confirmed that the cached bundle is current.
+	NotModified bool
+	// Resumed is true when an interrupted download was continued instead of restarted.
+	Resumed  bool
+	Size     int64
+	Duration time.Duration
+}
+
+// bundleMeta is stored next to a cached bundle to make conditional and resumed requests.
+type bundleMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// PartialETag is the ETag of the response an interrupted download was reading.
+	PartialETag string `json:"partial_etag,omitempty"`
+}
+
+// DownloadGTFSBundle downloads the GTFS bundle at url into cacheDir. The request is
+// conditional on the ETag / Last-Modified of the cached copy, and a previously
+// interrupted transfer is resumed when the server supports it. The bundle is written
+// to a temporary file and only replaces the cached copy once it has been verified to
+// be a zip archive containing the required GTFS files, so a failed download always
+// leaves the previous bundle in place.
+func DownloadGTFSBundle(url string, cacheDir string, serverID int, hashStr string) (*DownloadResult, error) {
+	start := time.Now()
+
+	cacheFileName := fmt.Sprintf("server_%d_%s.zip", serverID, hashStr)
+	cachePath := filepath.Join(cacheDir, cacheFileName)
+	partPath := cachePath + ".part"
+	metaPath := cachePath + ".meta"
+
+	meta := readBundleMeta(metaPath)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(cachePath); err == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	var partialSize int64
+	if info, err := os.Stat(partPath); err == nil && meta.PartialETag != "" {
+		partialSize = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", partialSize))
+		req.Header.Set("If-Range", meta.PartialETag)
+	}
+
+	resp, err := downloadClient.Do(req)
 	if err != nil {
 		sentry.CaptureException(err)
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	cacheFileName := fmt.Sprintf("server_%d_%s.zip", serverID, hashStr)
-	cachePath := filepath.Join(cacheDir, cacheFileName)
+	result := &DownloadResult{Path: cachePath}
 
-	out, err := os.Create(cachePath)
+	var out *os.File
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		info, err := os.Stat(cachePath)
+		if err != nil {
+			return nil, err
+		}
+		result.NotModified = true
+		result.Size = info.Size()
+		result.Duration = time.Since(start)
+		return result, nil
+	case http.StatusPartialContent:
+		if partialSize == 0 || !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", partialSize)) {
+			return nil, fmt.Errorf("unexpected partial response for GTFS bundle: %q", resp.Header.Get("Content-Range"))
+		}
+		out, err = os.OpenFile(partPath, os.O_WRONLY|os.O_APPEND, 0o644)
+		result.Resumed = true
+	case http.StatusOK:
+		out, err = os.Create(partPath)
+	default:
+		return nil, fmt.Errorf("GTFS bundle download returned status: %d", resp.StatusCode)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	_, copyErr := io.Copy(out, resp.Body)
+	closeErr := out.Close()
+	if copyErr != nil || closeErr != nil {
+		// Keep the partial file for the next attempt if the server identified the
+		// content with a strong validator, otherwise start from scratch next time.
+		if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			meta.PartialETag = etag
+		} else {
+			meta.PartialETag = ""
+			os.Remove(partPath)
+		}
+		writeBundleMeta(metaPath, meta)
+		return nil, errors.Join(copyErr, closeErr)
+	}
+
+	if err := ValidateGTFSBundle(partPath); err != nil {
+		os.Remove(partPath)
+		meta.PartialETag = ""
+		writeBundleMeta(metaPath, meta)
+		return nil, fmt.Errorf("downloaded GTFS bundle is invalid: %w", err)
+	}
+
+	if err := os.Rename(partPath, cachePath); err != nil {
+		return nil, err
+	}
+
+	writeBundleMeta(metaPath, bundleMeta{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	result.Size = info.Size()
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// ValidateGTFSBundle checks that path is a readable zip archive containing the files
+// every GTFS bundle needs.
+func ValidateGTFSBundle(path string) error {
+	reader, err := zip.OpenReader(path)
 	if err != nil {
-		return "", err
+		return err
 	}
+	defer reader.Close()
 
-	return cachePath, nil
+	files := make(map[string]bool, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = true
+	}
+
+	var missing []string
+	for _, name := range requiredGTFSFiles {
+		if !files[name] {
+			missing = append(missing, name)
+		}
+	}
+	if !files["calendar.txt"] && !files["calendar_dates.txt"] {
+		missing = append(missing, "calendar.txt or calendar_dates.txt")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required files: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func readBundleMeta(path string) bundleMeta {
+	var meta bundleMeta
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return meta
+	}
+	// A corrupt metadata file only costs us an unconditional download.
+	_ = json.Unmarshal(data, &meta)
+	return meta
+}
+
+func writeBundleMeta(path string, meta bundleMeta) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0o644)
 }
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"archive/zip"
+	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -58,6 +60,22 @@ func TestGetLastCachedFile(t *testing.T) {
 		}
 	})
 
+	t.Run("Ignores Partial Downloads", func(t *testing.T) {
+		dir := t.TempDir()
+		bundlePath := filepath.Join(dir, "server_7_hash.zip")
+		createFileWithModTime(t, bundlePath, time.Now().Add(-time.Hour))
+		createFileWithModTime(t, bundlePath+".part", time.Now())
+		createFileWithModTime(t, bundlePath+".meta", time.Now())
+
+		lastFile, err := GetLastCachedFile(dir, 7)
+		if err != nil {
+			t.Fatalf("GetLastCachedFile failed: %v", err)
+		}
+		if lastFile != bundlePath {
+			t.Errorf("Expected %s, got %s", bundlePath, lastFile)
+		}
+	})
+
 	t.Run("Empty Cache Directory", func(t *testing.T) {
 		emptyDir, err := os.MkdirTemp("", "emptycache")
 		if err != nil {
@@ -86,6 +104,30 @@ func createFileWithModTime(t *testing.T, path string, modTime time.Time) {
 	}
 }
 
+// buildGTFSZip returns a zip archive containing the given file names with placeholder content.
+func buildGTFSZip(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s to zip: %v", name, err)
+		}
+		fmt.Fprintf(w, "content of %s\n", name)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to finish zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func validGTFSZip(t *testing.T) []byte {
+	return buildGTFSZip(t, "agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar.txt")
+}
+
 func TestDownloadGTFSBundle(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "cache")
 	if err != nil {
@@ -93,47 +135,55 @@ func TestDownloadGTFSBundle(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
+	bundleData := validGTFSZip(t)
+
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("mock GTFS data"))
+		w.Write(bundleData)
 	}))
 	defer mockServer.Close()
 
 	serverID := 1
 	hash := sha1.Sum([]byte(mockServer.URL))
 	hashStr := hex.EncodeToString(hash[:])
-	cachePath, err := DownloadGTFSBundle(mockServer.URL, tmpDir, serverID, hashStr)
+	result, err := DownloadGTFSBundle(mockServer.URL, tmpDir, serverID, hashStr)
 	if err != nil {
 		t.Fatalf("DownloadGTFSBundle failed: %v", err)
 	}
 
 	expectedFileName := fmt.Sprintf("server_%d_%s.zip", serverID, hashStr)
 	expectedFilePath := filepath.Join(tmpDir, expectedFileName)
-	if cachePath != expectedFilePath {
-		t.Errorf("Expected cache path to be %s, got %s", expectedFilePath, cachePath)
+	if result.Path != expectedFilePath {
+		t.Errorf("Expected cache path to be %s, got %s", expectedFilePath, result.Path)
+	}
+	if result.Size != int64(len(bundleData)) {
+		t.Errorf("Expected size to be %d, got %d", len(bundleData), result.Size)
 	}
 
-	fileContent, err := os.ReadFile(cachePath)
+	fileContent, err := os.ReadFile(result.Path)
 	if err != nil {
 		t.Fatalf("Failed to read downloaded file: %v", err)
 	}
 
-	expectedContent := "mock GTFS data"
-	if string(fileContent) != expectedContent {
-		t.Errorf("Expected file content to be %s, got %s", expectedContent, string(fileContent))
+	if !bytes.Equal(fileContent, bundleData) {
+		t.Errorf("Expected downloaded file to match the served bundle")
+	}
+
+	if _, err := os.Stat(result.Path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary file to be renamed, got %v", err)
 	}
 
 	serverID = 2
 	hash = sha1.Sum([]byte(mockServer.URL))
 	hashStr = hex.EncodeToString(hash[:])
-	cachePath, err = DownloadGTFSBundle(mockServer.URL, tmpDir, serverID, hashStr)
+	result, err = DownloadGTFSBundle(mockServer.URL, tmpDir, serverID, hashStr)
 	if err != nil {
 		t.Fatalf("DownloadGTFSBundle failed: %v", err)
 	}
 
 	expectedFileName = fmt.Sprintf("server_%d_%s.zip", serverID, hashStr)
 	expectedFilePath = filepath.Join(tmpDir, expectedFileName)
-	if cachePath != expectedFilePath {
-		t.Errorf("Expected cache path to be %s, got %s", expectedFilePath, cachePath)
+	if result.Path != expectedFilePath {
+		t.Errorf("Expected cache path to be %s, got %s", expectedFilePath, result.Path)
 	}
 
 	t.Run("Invalid URL", func(t *testing.T) {
@@ -163,3 +213,173 @@ func TestDownloadGTFSBundle(t *testing.T) {
 		}
 	})
 }
+
+func TestDownloadGTFSBundleKeepsPreviousBundle(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundleData := validGTFSZip(t)
+
+	responses := []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) { w.Write(bundleData) },
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html>Not Found</html>"))
+		},
+		func(w http.ResponseWriter) { w.Write([]byte("<html>Maintenance</html>")) },
+		func(w http.ResponseWriter) { w.Write(buildGTFSZip(t, "agency.txt", "stops.txt")) },
+	}
+	var request int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responses[request](w)
+		request++
+	}))
+	defer mockServer.Close()
+
+	result, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash")
+	if err != nil {
+		t.Fatalf("DownloadGTFSBundle failed: %v", err)
+	}
+
+	for _, name := range []string{"error status", "HTML page", "missing required files"} {
+		if _, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash"); err == nil {
+			t.Errorf("Expected an error for a download with %s, got none", name)
+		}
+
+		fileContent, err := os.ReadFile(result.Path)
+		if err != nil {
+			t.Fatalf("Failed to read cached bundle: %v", err)
+		}
+		if !bytes.Equal(fileContent, bundleData) {
+			t.Errorf("Expected previous bundle to be kept after a download with %s", name)
+		}
+	}
+}
+
+func TestDownloadGTFSBundleConditionalRequest(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundleData := validGTFSZip(t)
+	const etag = `"v1"`
+	const lastModified = "Wed, 08 Jan 2025 10:00:00 GMT"
+
+	var conditionalRequests int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write(bundleData)
+	}))
+	defer mockServer.Close()
+
+	first, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash")
+	if err != nil {
+		t.Fatalf("DownloadGTFSBundle failed: %v", err)
+	}
+	if first.NotModified {
+		t.Error("Expected the first download not to be conditional")
+	}
+
+	second, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash")
+	if err != nil {
+		t.Fatalf("DownloadGTFSBundle failed: %v", err)
+	}
+	if !second.NotModified || conditionalRequests != 1 {
+		t.Errorf("Expected the second download to be answered with 304, got %+v", second)
+	}
+	if second.Path != first.Path || second.Size != int64(len(bundleData)) {
+		t.Errorf("Expected the not modified result to describe the cached bundle, got %+v", second)
+	}
+}
+
+func TestDownloadGTFSBundleResumesInterruptedDownload(t *testing.T) {
+	tmpDir := t.TempDir()
+	bundleData := validGTFSZip(t)
+	const etag = `"bundle-v1"`
+	half := len(bundleData) / 2
+
+	var rangeHeader string
+	var request int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request++
+		w.Header().Set("ETag", etag)
+		if request == 1 {
+			// Announce the full bundle but only send half of it.
+			w.Header().Set("Content-Length", fmt.Sprint(len(bundleData)))
+			w.Write(bundleData[:half])
+			return
+		}
+
+		rangeHeader = r.Header.Get("Range")
+		if r.Header.Get("If-Range") != etag {
+			w.Write(bundleData)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(bundleData)-1, len(bundleData)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(bundleData[half:])
+	}))
+	defer mockServer.Close()
+
+	if _, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash"); err == nil {
+		t.Fatal("Expected the interrupted download to fail")
+	}
+
+	result, err := DownloadGTFSBundle(mockServer.URL, tmpDir, 1, "hash")
+	if err != nil {
+		t.Fatalf("DownloadGTFSBundle failed: %v", err)
+	}
+
+	if rangeHeader != fmt.Sprintf("bytes=%d-", half) {
+		t.Errorf("Expected a range request for the missing bytes, got %q", rangeHeader)
+	}
+	if !result.Resumed {
+		t.Error("Expected the download to be resumed")
+	}
+
+	fileContent, err := os.ReadFile(result.Path)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(fileContent, bundleData) {
+		t.Error("Expected the resumed download to match the served bundle")
+	}
+}
+
+func TestValidateGTFSBundle(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		expectErr bool
+	}{
+		{"Complete with calendar", []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar.txt"}, false},
+		{"Complete with calendar_dates", []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt", "calendar_dates.txt"}, false},
+		{"Missing calendars", []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt"}, true},
+		{"Missing stop_times", []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "calendar.txt"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gtfs.zip")
+			if err := os.WriteFile(path, buildGTFSZip(t, tt.files...), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := ValidateGTFSBundle(path)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error: %v, got: %v", tt.expectErr, err)
+			}
+		})
+	}
+
+	t.Run("Not a zip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "gtfs.zip")
+		if err := os.WriteFile(path, []byte("mock GTFS data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateGTFSBundle(path); err == nil {
+			t.Error("Expected an error for a file that is not a zip archive")
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// Realtime finding codes. Unknown stops share CodeUnknownStop with static bundles.
+const (
+	CodeMissingHeaderTimestamp    = "missing_header_timestamp"
+	CodeDuplicateEntityID         = "duplicate_entity_id"
+	CodeVehicleWithoutTrip        = "vehicle_without_trip"
+	CodeStopSequenceNotIncreasing = "stop_sequence_not_increasing"
+	CodeStopTimeNotIncreasing     = "stop_time_not_increasing"
+	CodeUnknownRoute              = "unknown_route"
+	CodeUnknownTrip               = "unknown_trip"
+	CodeFutureTimestamp           = "future_timestamp"
+)
+
+// FutureTimestampTolerance is how far ahead of the watchdog's clock a feed timestamp
+// may be before it is reported, to allow for clock skew between the two hosts.
+const FutureTimestampTolerance = time.Minute
+
+// RealtimeReport is the result of validating one GTFS-RT feed.
+type RealtimeReport struct {
+	// Feed is the URL of the feed, with credentials redacted.
+	Feed        string    `json:"feed"`
+	ValidatedAt time.Time `json:"validated_at"`
+	Entities    int       `json:"entities"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Count returns the number of occurrences of findings with the given severity.
+func (r *RealtimeReport) Count(severity Severity) int {
+	return countFindings(r.Findings, severity)
+}
+
+// Codes returns the distinct codes of findings with the given severity, sorted.
+func (r *RealtimeReport) Codes(severity Severity) []string {
+	return findingCodes(r.Findings, severity)
+}
+
+// ParseRealtime decodes a GTFS-RT feed. Unlike gtfs.ParseRealtime it keeps the
+// feed as sent, which the validation needs to see duplicate entities and missing fields.
+func ParseRealtime(data []byte) (*gtfsrt.FeedMessage, error) {
+	message := &gtfsrt.FeedMessage{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to parse GTFS-RT feed: %v", err)
+	}
+	return message, nil
+}
+
+// ValidateRealtime checks message against the GTFS-RT specification and the static
+// bundle it belongs to: a header timestamp must be present, entity IDs must be unique,
+// vehicles should have a trip descriptor, stop time updates must have increasing stop
+// sequences and times, referenced routes, trips and stops must exist in staticData and
+// no timestamp may be in the future. feed names the feed in the report.
+func ValidateRealtime(feed string, message *gtfsrt.FeedMessage, staticData *gtfs.Static, now time.Time) *RealtimeReport {
+	v := newRealtimeValidator(staticData, now)
+	v.validateHeader(message.GetHeader())
+
+	seen := make(map[string]bool, len(message.GetEntity()))
+	for _, entity := range message.GetEntity() {
+		id := entity.GetId()
+		if seen[id] {
+			v.findings.add(SeverityError, CodeDuplicateEntityID, "", "entity ID is used by more than one entity", id)
+		}
+		seen[id] = true
+
+		if entity.GetIsDeleted() {
+			continue
+		}
+		if entity.TripUpdate != nil {
+			v.validateTripUpdate(id, entity.TripUpdate)
+		}
+		if entity.Vehicle != nil {
+			v.validateVehicle(id, entity.Vehicle)
+		}
+		if entity.Alert != nil {
+			v.validateAlert(entity.Alert)
+		}
+	}
+
+	return &RealtimeReport{
+		Feed:        feed,
+		ValidatedAt: now,
+		Entities:    len(message.GetEntity()),
+		Findings:    v.findings.list(),
+	}
+}
+
+type realtimeValidator struct {
+	now      time.Time
+	routeIDs map[string]bool
+	tripIDs  map[string]bool
+	stopIDs  map[string]bool
+	findings findings
+}
+
+func newRealtimeValidator(staticData *gtfs.Static, now time.Time) *realtimeValidator {
+	v := &realtimeValidator{
+		now:      now,
+		routeIDs: make(map[string]bool, len(staticData.Routes)),
+		tripIDs:  make(map[string]bool, len(staticData.Trips)),
+		stopIDs:  make(map[string]bool, len(staticData.Stops)),
+	}
+	for _, route := range staticData.Routes {
+		v.routeIDs[route.Id] = true
+	}
+	for _, trip := range staticData.Trips {
+		v.tripIDs[trip.ID] = true
+	}
+	for _, stop := range staticData.Stops {
+		v.stopIDs[stop.Id] = true
+	}
+	return v
+}
+
+func (v *realtimeValidator) validateHeader(header *gtfsrt.FeedHeader) {
+	if header.GetTimestamp() == 0 {
+		v.findings.add(SeverityError, CodeMissingHeaderTimestamp, "", "feed header has no timestamp", "header")
+		return
+	}
+	v.checkTimestamp("header", header.GetTimestamp())
+}
+
+func (v *realtimeValidator) validateTripUpdate(entityID string, tripUpdate *gtfsrt.TripUpdate) {
+	v.checkTrip(tripUpdate.Trip)
+	v.checkTimestamp(entityID, tripUpdate.GetTimestamp())
+
+	var lastSequence uint32
+	var hasSequence bool
+	var lastTime int64
+	for _, update := range tripUpdate.GetStopTimeUpdate() {
+		if update.StopId != nil && !v.stopIDs[update.GetStopId()] {
+			v.findings.add(SeverityError, CodeUnknownStop, "", "entity references a stop missing from the static bundle", update.GetStopId())
+		}
+
+		if update.StopSequence != nil {
+			if hasSequence && update.GetStopSequence() <= lastSequence {
+				v.findings.add(SeverityError, CodeStopSequenceNotIncreasing, "",
+					"stop time updates are not sorted by increasing stop_sequence", entityID)
+			}
+			lastSequence, hasSequence = update.GetStopSequence(), true
+		}
+
+		// Skipped stops and stops without data carry no times to compare.
+		relationship := update.GetScheduleRelationship()
+		if relationship == gtfsrt.TripUpdate_StopTimeUpdate_SKIPPED || relationship == gtfsrt.TripUpdate_StopTimeUpdate_NO_DATA {
+			continue
+		}
+		for _, event := range []*gtfsrt.TripUpdate_StopTimeEvent{update.Arrival, update.Departure} {
+			t := event.GetTime()
+			if t == 0 {
+				continue
+			}
+			if t < lastTime {
+				v.findings.add(SeverityError, CodeStopTimeNotIncreasing, "",
+					"stop time update times go back in time along the trip", entityID)
+			}
+			lastTime = t
+		}
+	}
+}
+
+func (v *realtimeValidator) validateVehicle(entityID string, vehicle *gtfsrt.VehiclePosition) {
+	if vehicle.Trip == nil {
+		v.findings.add(SeverityWarning, CodeVehicleWithoutTrip, "", "vehicle position has no trip descriptor", entityID)
+	} else {
+		v.checkTrip(vehicle.Trip)
+	}
+	if vehicle.StopId != nil && !v.stopIDs[vehicle.GetStopId()] {
+		v.findings.add(SeverityError, CodeUnknownStop, "", "entity references a stop missing from the static bundle", vehicle.GetStopId())
+	}
+	v.checkTimestamp(entityID, vehicle.GetTimestamp())
+}
+
+func (v *realtimeValidator) validateAlert(alert *gtfsrt.Alert) {
+	for _, entity := range alert.GetInformedEntity() {
+		if entity.RouteId != nil && !v.routeIDs[entity.GetRouteId()] {
+			v.findings.add(SeverityError, CodeUnknownRoute, "", "entity references a route missing from the static bundle", entity.GetRouteId())
+		}
+		if entity.StopId != nil && !v.stopIDs[entity.GetStopId()] {
+			v.findings.add(SeverityError, CodeUnknownStop, "", "entity references a stop missing from the static bundle", entity.GetStopId())
+		}
+		if entity.Trip != nil {
+			v.checkTrip(entity.Trip)
+		}
+	}
+}
+
+// checkTrip reports route and trip IDs of the descriptor that are missing from the
+// static bundle. Added and unscheduled trips are not in the bundle by definition.
+func (v *realtimeValidator) checkTrip(trip *gtfsrt.TripDescriptor) {
+	if trip == nil {
+		return
+	}
+	if routeID := trip.GetRouteId(); routeID != "" && !v.routeIDs[routeID] {
+		v.findings.add(SeverityError, CodeUnknownRoute, "", "entity references a route missing from the static bundle", routeID)
+	}
+	relationship := trip.GetScheduleRelationship()
+	if relationship == gtfsrt.TripDescriptor_ADDED || relationship == gtfsrt.TripDescriptor_UNSCHEDULED {
+		return
+	}
+	if tripID := trip.GetTripId(); tripID != "" && !v.tripIDs[tripID] {
+		v.findings.add(SeverityError, CodeUnknownTrip, "", "entity references a trip missing from the static bundle", tripID)
+	}
+}
+
+// checkTimestamp reports a POSIX timestamp more than FutureTimestampTolerance after now.
+// Zero means the timestamp is not set.
+func (v *realtimeValidator) checkTimestamp(id string, timestamp uint64) {
+	if timestamp == 0 {
+		return
+	}
+	if time.Unix(int64(timestamp), 0).After(v.now.Add(FutureTimestampTolerance)) {
+		v.findings.add(SeverityError, CodeFutureTimestamp, "", "timestamp is in the future", id)
+	}
+}
@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jamespfennell/gtfs"
+	gtfsrt "github.com/jamespfennell/gtfs/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateRealtime(t *testing.T) {
+	now := time.Date(2025, 1, 12, 12, 0, 0, 0, time.UTC)
+	staticData := &gtfs.Static{
+		Routes: []gtfs.Route{{Id: "R1"}},
+		Trips:  []gtfs.ScheduledTrip{{ID: "T1"}, {ID: "T2"}},
+		Stops:  []gtfs.Stop{{Id: "S1"}, {Id: "S2"}, {Id: "S3"}},
+	}
+	header := &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0"), Timestamp: proto.Uint64(uint64(now.Unix()))}
+	stopTimeUpdate := func(sequence uint32, stopID string, arrival int64) *gtfsrt.TripUpdate_StopTimeUpdate {
+		return &gtfsrt.TripUpdate_StopTimeUpdate{
+			StopSequence: proto.Uint32(sequence),
+			StopId:       proto.String(stopID),
+			Arrival:      &gtfsrt.TripUpdate_StopTimeEvent{Time: proto.Int64(arrival)},
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		message := &gtfsrt.FeedMessage{
+			Header: header,
+			Entity: []*gtfsrt.FeedEntity{
+				{
+					Id: proto.String("trip-1"),
+					TripUpdate: &gtfsrt.TripUpdate{
+						Trip: &gtfsrt.TripDescriptor{TripId: proto.String("T1"), RouteId: proto.String("R1")},
+						StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{
+							stopTimeUpdate(1, "S1", now.Unix()),
+							stopTimeUpdate(2, "S2", now.Unix()+60),
+						},
+					},
+				},
+				{
+					Id: proto.String("vehicle-1"),
+					Vehicle: &gtfsrt.VehiclePosition{
+						Trip:      &gtfsrt.TripDescriptor{TripId: proto.String("T1")},
+						Timestamp: proto.Uint64(uint64(now.Unix() - 30)),
+					},
+				},
+				{
+					// Added trips are not in the static bundle.
+					Id: proto.String("added"),
+					TripUpdate: &gtfsrt.TripUpdate{
+						Trip: &gtfsrt.TripDescriptor{TripId: proto.String("EXTRA"), ScheduleRelationship: gtfsrt.TripDescriptor_ADDED.Enum()},
+					},
+				},
+			},
+		}
+
+		report := ValidateRealtime("https://rt.example.com/feed", message, staticData, now)
+		if len(report.Findings) != 0 {
+			t.Errorf("Expected no findings, got %+v", report.Findings)
+		}
+		if report.Feed != "https://rt.example.com/feed" || report.Entities != 3 || !report.ValidatedAt.Equal(now) {
+			t.Errorf("Unexpected report %+v", report)
+		}
+	})
+
+	t.Run("Findings", func(t *testing.T) {
+		future := uint64(now.Add(10 * time.Minute).Unix())
+		message := &gtfsrt.FeedMessage{
+			Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+			Entity: []*gtfsrt.FeedEntity{
+				{
+					Id: proto.String("trip-1"),
+					TripUpdate: &gtfsrt.TripUpdate{
+						Trip: &gtfsrt.TripDescriptor{TripId: proto.String("T9"), RouteId: proto.String("R9")},
+						StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{
+							stopTimeUpdate(1, "S1", now.Unix()),
+							stopTimeUpdate(3, "S3", now.Unix()+120),
+							stopTimeUpdate(2, "S2", now.Unix()+60),
+							stopTimeUpdate(4, "S9", now.Unix()+180),
+						},
+					},
+				},
+				{
+					Id:      proto.String("trip-1"),
+					Vehicle: &gtfsrt.VehiclePosition{Timestamp: proto.Uint64(future)},
+				},
+				{
+					// Deleted entities only count towards unique IDs.
+					Id:        proto.String("deleted"),
+					IsDeleted: proto.Bool(true),
+					Vehicle:   &gtfsrt.VehiclePosition{},
+				},
+			},
+		}
+
+		report := ValidateRealtime("feed", message, staticData, now)
+
+		expected := map[string]struct {
+			severity Severity
+			count    int
+			examples []string
+		}{
+			CodeMissingHeaderTimestamp:    {SeverityError, 1, []string{"header"}},
+			CodeDuplicateEntityID:         {SeverityError, 1, []string{"trip-1"}},
+			CodeVehicleWithoutTrip:        {SeverityWarning, 1, []string{"trip-1"}},
+			CodeStopSequenceNotIncreasing: {SeverityError, 1, []string{"trip-1"}},
+			CodeStopTimeNotIncreasing:     {SeverityError, 1, []string{"trip-1"}},
+			CodeUnknownRoute:              {SeverityError, 1, []string{"R9"}},
+			CodeUnknownTrip:               {SeverityError, 1, []string{"T9"}},
+			CodeUnknownStop:               {SeverityError, 1, []string{"S9"}},
+			CodeFutureTimestamp:           {SeverityError, 1, []string{"trip-1"}},
+		}
+		if len(report.Findings) != len(expected) {
+			t.Errorf("Expected %d findings, got %+v", len(expected), report.Findings)
+		}
+		for code, want := range expected {
+			finding, ok := findRealtimeFinding(report, code)
+			if !ok {
+				t.Errorf("Expected a %s finding", code)
+				continue
+			}
+			if finding.Severity != want.severity || finding.Count != want.count || !reflect.DeepEqual(finding.Examples, want.examples) {
+				t.Errorf("Unexpected %s finding %+v", code, finding)
+			}
+		}
+
+		if report.Count(SeverityError) != 8 || report.Count(SeverityWarning) != 1 {
+			t.Errorf("Expected 8 errors and 1 warning, got %d and %d", report.Count(SeverityError), report.Count(SeverityWarning))
+		}
+	})
+
+	t.Run("SkippedStopsHaveNoTimes", func(t *testing.T) {
+		skipped := stopTimeUpdate(2, "S2", now.Unix()-600)
+		skipped.ScheduleRelationship = gtfsrt.TripUpdate_StopTimeUpdate_SKIPPED.Enum()
+		message := &gtfsrt.FeedMessage{
+			Header: header,
+			Entity: []*gtfsrt.FeedEntity{{
+				Id: proto.String("trip-1"),
+				TripUpdate: &gtfsrt.TripUpdate{
+					Trip: &gtfsrt.TripDescriptor{TripId: proto.String("T1")},
+					StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{
+						stopTimeUpdate(1, "S1", now.Unix()),
+						skipped,
+						stopTimeUpdate(3, "S3", now.Unix()+120),
+					},
+				},
+			}},
+		}
+
+		if report := ValidateRealtime("feed", message, staticData, now); len(report.Findings) != 0 {
+			t.Errorf("Expected no findings, got %+v", report.Findings)
+		}
+	})
+}
+
+func findRealtimeFinding(report *RealtimeReport, code string) (Finding, bool) {
+	for _, finding := range report.Findings {
+		if finding.Code == code {
+			return finding, true
+		}
+	}
+	return Finding{}, false
+}
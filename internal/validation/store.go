@@ -0,0 +1,200 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"watchdog.onebusaway.org/internal/models"
+)
+
+const (
+	// BaselineAge is how old the bundle a new bundle's counts are compared with should be.
+	BaselineAge = 7 * 24 * time.Hour
+	// DropThreshold is the share of trips or stops a bundle may lose compared with its
+	// baseline before a drop is reported.
+	DropThreshold = 0.2
+)
+
+// CountSample is the number of trips and stops of a bundle, from the time it was validated.
+type CountSample struct {
+	At    time.Time `json:"at"`
+	Trips int       `json:"trips"`
+	Stops int       `json:"stops"`
+}
+
+type serverReports struct {
+	current *Report
+	samples []CountSample
+}
+
+// Store keeps the latest validation report of every server and the counts of earlier
+// bundles that new bundles are compared with. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	servers map[int]*serverReports
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	return &Store{servers: make(map[int]*serverReports)}
+}
+
+// Current returns the latest report of the server.
+func (s *Store) Current(serverID int) (*Report, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.servers[serverID]
+	if !ok || state.current == nil {
+		return nil, false
+	}
+	return state.current, true
+}
+
+// Add makes report the current report of the server. Before storing it, the trip and
+// stop counts are compared with the bundle the server had BaselineAge ago, or with its
+// oldest known bundle, and drops beyond DropThreshold are added as warnings.
+func (s *Store) Add(serverID int, report *Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.servers[serverID]
+	if !ok {
+		state = &serverReports{}
+		s.servers[serverID] = state
+	}
+
+	if baseline, ok := state.baseline(report.ValidatedAt); ok {
+		if finding, dropped := countDrop(CodeTripCountDrop, "trips.txt", "trips", baseline.Trips, report.Trips); dropped {
+			report.Findings = append(report.Findings, finding)
+		}
+		if finding, dropped := countDrop(CodeStopCountDrop, "stops.txt", "stops", baseline.Stops, report.Stops); dropped {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	state.current = report
+	state.samples = append(state.samples, CountSample{At: report.ValidatedAt, Trips: report.Trips, Stops: report.Stops})
+	state.prune(report.ValidatedAt)
+}
+
+// Samples returns the counts of the bundles of the server that new bundles are
+// compared with, oldest first, so that they can be persisted.
+func (s *Store) Samples(serverID int) []CountSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.servers[serverID]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(state.samples)
+}
+
+// RestoreSamples sets the counts new bundles of the server are compared with, for
+// example after a restart. Samples must be oldest first. Servers that were already
+// validated keep their samples.
+func (s *Store) RestoreSamples(serverID int, samples []CountSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.servers[serverID]
+	if !ok {
+		state = &serverReports{}
+		s.servers[serverID] = state
+	}
+	if len(state.samples) == 0 {
+		state.samples = slices.Clone(samples)
+	}
+}
+
+// Retain removes the reports of all servers not listed in serverIDs.
+func (s *Store) Retain(serverIDs []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	models.RetainServers(s.servers, serverIDs)
+}
+
+// baseline returns the sample that was current BaselineAge before now, or the oldest
+// sample if the server has not been validated for that long.
+func (r *serverReports) baseline(now time.Time) (CountSample, bool) {
+	if len(r.samples) == 0 {
+		return CountSample{}, false
+	}
+	cutoff := now.Add(-BaselineAge)
+	for i := len(r.samples) - 1; i >= 0; i-- {
+		if !r.samples[i].At.After(cutoff) {
+			return r.samples[i], true
+		}
+	}
+	return r.samples[0], true
+}
+
+// prune drops the samples that can no longer be a baseline.
+func (r *serverReports) prune(now time.Time) {
+	cutoff := now.Add(-BaselineAge)
+	keepFrom := 0
+	for i, sample := range r.samples {
+		if !sample.At.After(cutoff) {
+			keepFrom = i
+		}
+	}
+	r.samples = r.samples[keepFrom:]
+}
+
+func countDrop(code, file, what string, before, after int) (Finding, bool) {
+	if before == 0 || after >= before {
+		return Finding{}, false
+	}
+	drop := float64(before-after) / float64(before)
+	if drop <= DropThreshold {
+		return Finding{}, false
+	}
+	return Finding{
+		Severity: SeverityWarning,
+		Code:     code,
+		File:     file,
+		Message:  fmt.Sprintf("number of %s dropped by %.0f%% from %d to %d", what, drop*100, before, after),
+		Count:    before - after,
+	}, true
+}
+
+// RealtimeStore keeps the latest GTFS-RT validation reports of every server, one per
+// feed. It is safe for concurrent use.
+type RealtimeStore struct {
+	mu      sync.RWMutex
+	servers map[int][]*RealtimeReport
+}
+
+// NewRealtimeStore creates an empty RealtimeStore.
+func NewRealtimeStore() *RealtimeStore {
+	return &RealtimeStore{servers: make(map[int][]*RealtimeReport)}
+}
+
+// Current returns the latest reports of the server.
+func (s *RealtimeStore) Current(serverID int) ([]*RealtimeReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reports, ok := s.servers[serverID]
+	return reports, ok
+}
+
+// Set replaces the reports of the server.
+func (s *RealtimeStore) Set(serverID int, reports []*RealtimeReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.servers[serverID] = reports
+}
+
+// Retain removes the reports of all servers not listed in serverIDs.
+func (s *RealtimeStore) Retain(serverIDs []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	models.RetainServers(s.servers, serverIDs)
+}
@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := func(at time.Time, trips, stops int) *Report {
+		return &Report{Hash: at.String(), ValidatedAt: at, Trips: trips, Stops: stops}
+	}
+
+	t.Run("Drops", func(t *testing.T) {
+		store := NewStore()
+		if _, ok := store.Current(1); ok {
+			t.Fatal("Expected no report before the first validation")
+		}
+
+		store.Add(1, report(start, 100, 50))
+		// A 10% drop in trips is within the threshold.
+		store.Add(1, report(start.Add(24*time.Hour), 90, 50))
+		current, _ := store.Current(1)
+		if len(current.Findings) != 0 {
+			t.Errorf("Expected no drop findings, got %+v", current.Findings)
+		}
+
+		store.Add(1, report(start.Add(48*time.Hour), 70, 30))
+		current, _ = store.Current(1)
+		trips, ok := findFinding(current, CodeTripCountDrop)
+		if !ok || trips.Severity != SeverityWarning || trips.Count != 30 {
+			t.Errorf("Expected a trip count drop of 30, got %+v", current.Findings)
+		}
+		if _, ok := findFinding(current, CodeStopCountDrop); !ok {
+			t.Errorf("Expected a stop count drop, got %+v", current.Findings)
+		}
+	})
+
+	t.Run("Baseline", func(t *testing.T) {
+		store := NewStore()
+		store.Add(1, report(start, 100, 50))
+		store.Add(1, report(start.Add(5*24*time.Hour), 70, 50))
+		store.Add(1, report(start.Add(13*24*time.Hour), 65, 50))
+
+		// The bundle of day 5 was current a week before day 13, so the earlier drop
+		// from 100 trips is not reported again.
+		current, _ := store.Current(1)
+		if len(current.Findings) != 0 {
+			t.Errorf("Expected the baseline to be the bundle of a week ago, got %+v", current.Findings)
+		}
+	})
+
+	t.Run("RestoreSamples", func(t *testing.T) {
+		before := NewStore()
+		before.Add(1, report(start, 100, 50))
+		samples := before.Samples(1)
+		if len(samples) != 1 || samples[0].Trips != 100 {
+			t.Fatalf("Expected the sample of the first bundle, got %+v", samples)
+		}
+
+		// A restarted store compares new bundles with the restored samples.
+		store := NewStore()
+		store.RestoreSamples(1, samples)
+		store.Add(1, report(start.Add(24*time.Hour), 70, 50))
+		current, _ := store.Current(1)
+		if _, ok := findFinding(current, CodeTripCountDrop); !ok {
+			t.Errorf("Expected a trip count drop from the restored baseline, got %+v", current.Findings)
+		}
+
+		// Samples of a server that was validated since are kept.
+		store.RestoreSamples(1, nil)
+		if len(store.Samples(1)) != 2 {
+			t.Errorf("Expected restoring not to replace existing samples, got %+v", store.Samples(1))
+		}
+	})
+
+	t.Run("Retain", func(t *testing.T) {
+		store := NewStore()
+		store.Add(1, report(start, 100, 50))
+		store.Add(2, report(start, 100, 50))
+		store.Retain([]int{2})
+
+		if _, ok := store.Current(1); ok {
+			t.Error("Expected the report of server 1 to be removed")
+		}
+		if _, ok := store.Current(2); !ok {
+			t.Error("Expected the report of server 2 to be kept")
+		}
+	})
+}
+
+func TestRealtimeStore(t *testing.T) {
+	store := NewRealtimeStore()
+	if _, ok := store.Current(1); ok {
+		t.Fatal("Expected no reports before the first validation")
+	}
+
+	store.Set(1, []*RealtimeReport{{Feed: "a"}, {Feed: "b"}})
+	store.Set(2, []*RealtimeReport{{Feed: "c"}})
+	if reports, ok := store.Current(1); !ok || len(reports) != 2 {
+		t.Errorf("Expected 2 reports for server 1, got %+v", reports)
+	}
+
+	store.Retain([]int{2})
+	if _, ok := store.Current(1); ok {
+		t.Error("Expected the reports of server 1 to be removed")
+	}
+	if _, ok := store.Current(2); !ok {
+		t.Error("Expected the reports of server 2 to be kept")
+	}
+}
@@ -0,0 +1,313 @@
+// Package validation checks the quality of static GTFS bundles and reports what it
+// finds as structured findings.
+package validation
+
+import (
+	"archive/zip"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"watchdog.onebusaway.org/internal/bundle"
+)
+
+// Severity ranks how serious a finding is.
+type Severity string
+
+const (
+	// SeverityError marks data OBA cannot use correctly.
+	SeverityError Severity = "error"
+	// SeverityWarning marks suspicious data that is usable.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks missing optional data.
+	SeverityInfo Severity = "info"
+)
+
+// Finding codes.
+const (
+	CodeMissingRequiredFile    = "missing_required_file"
+	CodeMissingRecommendedFile = "missing_recommended_file"
+	CodeDuplicateID            = "duplicate_id"
+	CodeOrphanStopTime         = "orphan_stop_time"
+	CodeUnknownStop            = "unknown_stop"
+	CodeTripUnknownRoute       = "trip_unknown_route"
+	CodeTripUnknownService     = "trip_unknown_service"
+	CodeInvalidCoordinates     = "invalid_coordinates"
+	CodeTripCountDrop          = "trip_count_drop"
+	CodeStopCountDrop          = "stop_count_drop"
+)
+
+// maxExamples is the number of offending IDs kept per finding.
+const maxExamples = 5
+
+// Finding is one kind of problem found in a bundle, with the number of occurrences
+// and a few of the offending IDs.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	File     string   `json:"file,omitempty"`
+	Message  string   `json:"message"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// Report is the result of validating one bundle.
+type Report struct {
+	// Hash identifies the bundle content, see bundle.Bundle.Hash.
+	Hash        string    `json:"hash"`
+	ValidatedAt time.Time `json:"validated_at"`
+	Trips       int       `json:"trips"`
+	Stops       int       `json:"stops"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Count returns the number of occurrences of findings with the given severity.
+func (r *Report) Count(severity Severity) int {
+	return countFindings(r.Findings, severity)
+}
+
+// Codes returns the distinct codes of findings with the given severity, sorted.
+func (r *Report) Codes(severity Severity) []string {
+	return findingCodes(r.Findings, severity)
+}
+
+func countFindings(findings []Finding, severity Severity) int {
+	n := 0
+	for _, finding := range findings {
+		if finding.Severity == severity {
+			n += finding.Count
+		}
+	}
+	return n
+}
+
+func findingCodes(findings []Finding, severity Severity) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, finding := range findings {
+		if finding.Severity == severity && !seen[finding.Code] {
+			seen[finding.Code] = true
+			codes = append(codes, finding.Code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// collector accumulates occurrences of one finding.
+type collector struct {
+	finding Finding
+}
+
+func (c *collector) add(id string) {
+	c.finding.Count++
+	if len(c.finding.Examples) < maxExamples && !slices.Contains(c.finding.Examples, id) {
+		c.finding.Examples = append(c.finding.Examples, id)
+	}
+}
+
+// findings accumulates the findings of a validation run in the order they are first seen.
+type findings struct {
+	order      []string
+	collectors map[string]*collector
+}
+
+func (f *findings) add(severity Severity, code, file, message, id string) {
+	key := code + "\x00" + file
+	c, ok := f.collectors[key]
+	if !ok {
+		if f.collectors == nil {
+			f.collectors = make(map[string]*collector)
+		}
+		c = &collector{finding: Finding{Severity: severity, Code: code, File: file, Message: message}}
+		f.collectors[key] = c
+		f.order = append(f.order, key)
+	}
+	c.add(id)
+}
+
+func (f *findings) list() []Finding {
+	list := make([]Finding, 0, len(f.order))
+	for _, key := range f.order {
+		list = append(list, f.collectors[key].finding)
+	}
+	return list
+}
+
+// requiredFiles must be present in every bundle. A bundle also needs calendar.txt or calendar_dates.txt.
+var requiredFiles = []string{"agency.txt", "stops.txt", "routes.txt", "trips.txt", "stop_times.txt"}
+
+// ValidateFile validates the GTFS zip at path. Count drops are not part of the report;
+// see Store.Add.
+func ValidateFile(path, hash string, now time.Time) (*Report, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GTFS bundle: %v", err)
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	v := &validator{files: files}
+	if err := v.run(); err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		Hash:        hash,
+		ValidatedAt: now,
+		Trips:       len(v.tripIDs),
+		Stops:       len(v.stopIDs),
+		Findings:    v.findings.list(),
+	}, nil
+}
+
+type validator struct {
+	files    map[string]*zip.File
+	findings findings
+
+	stopIDs    map[string]bool
+	routeIDs   map[string]bool
+	serviceIDs map[string]bool
+	tripIDs    map[string]bool
+}
+
+func (v *validator) run() error {
+	for _, name := range requiredFiles {
+		if v.files[name] == nil {
+			v.findings.add(SeverityError, CodeMissingRequiredFile, name, "required file is missing", name)
+		}
+	}
+	if v.files["calendar.txt"] == nil && v.files["calendar_dates.txt"] == nil {
+		v.findings.add(SeverityError, CodeMissingRequiredFile, "calendar.txt", "neither calendar.txt nor calendar_dates.txt is present", "calendar.txt")
+	}
+	if v.files["feed_info.txt"] == nil {
+		v.findings.add(SeverityInfo, CodeMissingRecommendedFile, "feed_info.txt", "recommended file is missing", "feed_info.txt")
+	}
+
+	steps := []func() error{v.agencies, v.stops, v.routes, v.services, v.trips, v.stopTimes}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) agencies() error {
+	seen := make(map[string]bool)
+	return v.each("agency.txt", func(row map[string]string) {
+		// agency_id is optional for bundles with a single agency.
+		if id := row["agency_id"]; id != "" {
+			v.checkDuplicate(seen, "agency.txt", id)
+		}
+	})
+}
+
+func (v *validator) stops() error {
+	v.stopIDs = make(map[string]bool)
+	return v.each("stops.txt", func(row map[string]string) {
+		id := row["stop_id"]
+		v.checkDuplicate(v.stopIDs, "stops.txt", id)
+
+		// Generic nodes and boarding areas do not need coordinates.
+		switch row["location_type"] {
+		case "3", "4":
+			return
+		}
+		if !validCoordinates(row["stop_lat"], row["stop_lon"]) {
+			v.findings.add(SeverityError, CodeInvalidCoordinates, "stops.txt", "stop has missing or out of range coordinates", id)
+		}
+	})
+}
+
+func (v *validator) routes() error {
+	v.routeIDs = make(map[string]bool)
+	return v.each("routes.txt", func(row map[string]string) {
+		v.checkDuplicate(v.routeIDs, "routes.txt", row["route_id"])
+	})
+}
+
+func (v *validator) services() error {
+	v.serviceIDs = make(map[string]bool)
+	calendarIDs := make(map[string]bool)
+	if err := v.each("calendar.txt", func(row map[string]string) {
+		id := row["service_id"]
+		v.checkDuplicate(calendarIDs, "calendar.txt", id)
+		v.serviceIDs[id] = true
+	}); err != nil {
+		return err
+	}
+	return v.each("calendar_dates.txt", func(row map[string]string) {
+		v.serviceIDs[row["service_id"]] = true
+	})
+}
+
+func (v *validator) trips() error {
+	v.tripIDs = make(map[string]bool)
+	return v.each("trips.txt", func(row map[string]string) {
+		id := row["trip_id"]
+		v.checkDuplicate(v.tripIDs, "trips.txt", id)
+
+		if !v.routeIDs[row["route_id"]] {
+			v.findings.add(SeverityError, CodeTripUnknownRoute, "trips.txt", "trip references a route missing from routes.txt", id)
+		}
+		if !v.serviceIDs[row["service_id"]] {
+			v.findings.add(SeverityError, CodeTripUnknownService, "trips.txt", "trip references a service missing from calendar.txt and calendar_dates.txt", id)
+		}
+	})
+}
+
+func (v *validator) stopTimes() error {
+	return v.each("stop_times.txt", func(row map[string]string) {
+		tripID := row["trip_id"]
+		if !v.tripIDs[tripID] {
+			v.findings.add(SeverityError, CodeOrphanStopTime, "stop_times.txt", "stop time references a trip missing from trips.txt", tripID)
+		}
+		if stopID := row["stop_id"]; !v.stopIDs[stopID] {
+			v.findings.add(SeverityError, CodeUnknownStop, "stop_times.txt", "stop time references a stop missing from stops.txt", stopID)
+		}
+	})
+}
+
+func (v *validator) checkDuplicate(seen map[string]bool, file, id string) {
+	if seen[id] {
+		v.findings.add(SeverityError, CodeDuplicateID, file, "ID is defined more than once", id)
+	}
+	seen[id] = true
+}
+
+// each calls fn for every row of the CSV file name, keyed by column name. Missing
+// files are skipped; they are reported by run.
+func (v *validator) each(name string, fn func(row map[string]string)) error {
+	file := v.files[name]
+	if file == nil {
+		return nil
+	}
+
+	if err := bundle.EachCSVRow(file, fn); err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	return nil
+}
+
+// validCoordinates reports whether lat and lon are WGS-84 coordinates other than 0,0.
+func validCoordinates(lat, lon string) bool {
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return false
+	}
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return false
+	}
+	if latitude == 0 && longitude == 0 {
+		return false
+	}
+	return latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
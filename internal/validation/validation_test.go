@@ -0,0 +1,125 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"watchdog.onebusaway.org/internal/gtfstest"
+)
+
+// validFeed is the content of a small static GTFS bundle without findings.
+var validFeed = gtfstest.WithFiles(gtfstest.MinimalFeed(), map[string]string{
+	"feed_info.txt": "feed_publisher_name,feed_publisher_url,feed_lang\n" +
+		"Agency One,https://agency.example.com,en\n",
+})
+
+func findFinding(report *Report, code string) (Finding, bool) {
+	for _, finding := range report.Findings {
+		if finding.Code == code {
+			return finding, true
+		}
+	}
+	return Finding{}, false
+}
+
+func TestValidateFile(t *testing.T) {
+	now := time.Date(2025, 1, 12, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Valid", func(t *testing.T) {
+		report, err := ValidateFile(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", validFeed), "abc", now)
+		if err != nil {
+			t.Fatalf("ValidateFile failed: %v", err)
+		}
+		if len(report.Findings) != 0 {
+			t.Errorf("Expected no findings, got %+v", report.Findings)
+		}
+		if report.Hash != "abc" || report.Trips != 1 || report.Stops != 2 || !report.ValidatedAt.Equal(now) {
+			t.Errorf("Unexpected report %+v", report)
+		}
+	})
+
+	t.Run("Findings", func(t *testing.T) {
+		files := gtfstest.WithFiles(validFeed, map[string]string{
+			"feed_info.txt": "",
+			"stops.txt": "stop_id,stop_name,stop_lat,stop_lon,location_type\n" +
+				"S1,First,47.6,-122.3,0\n" +
+				"S2,Second,0,0,0\n" +
+				"S3,Third,95,-122.3,0\n" +
+				"S1,Duplicate,47.6,-122.3,0\n" +
+				"N1,Node,,,3\n",
+			"trips.txt": "route_id,service_id,trip_id\n" +
+				"R1,WK,T1\n" +
+				"R9,WK,T2\n" +
+				"R1,XX,T3\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T1,08:00:00,08:00:00,S1,1\n" +
+				"T1,08:10:00,08:10:00,S9,2\n" +
+				"T9,08:00:00,08:00:00,S1,1\n" +
+				"T9,08:10:00,08:10:00,S2,2\n",
+		})
+
+		report, err := ValidateFile(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", files), "abc", now)
+		if err != nil {
+			t.Fatalf("ValidateFile failed: %v", err)
+		}
+
+		expected := map[string]struct {
+			severity Severity
+			count    int
+			examples []string
+		}{
+			CodeMissingRecommendedFile: {SeverityInfo, 1, []string{"feed_info.txt"}},
+			CodeDuplicateID:            {SeverityError, 1, []string{"S1"}},
+			CodeInvalidCoordinates:     {SeverityError, 2, []string{"S2", "S3"}},
+			CodeTripUnknownRoute:       {SeverityError, 1, []string{"T2"}},
+			CodeTripUnknownService:     {SeverityError, 1, []string{"T3"}},
+			CodeOrphanStopTime:         {SeverityError, 2, []string{"T9"}},
+			CodeUnknownStop:            {SeverityError, 1, []string{"S9"}},
+		}
+		for code, want := range expected {
+			finding, ok := findFinding(report, code)
+			if !ok {
+				t.Errorf("Expected a %s finding", code)
+				continue
+			}
+			if finding.Severity != want.severity || finding.Count != want.count || !reflect.DeepEqual(finding.Examples, want.examples) {
+				t.Errorf("Unexpected %s finding %+v", code, finding)
+			}
+		}
+		if len(report.Findings) != len(expected) {
+			t.Errorf("Expected %d findings, got %+v", len(expected), report.Findings)
+		}
+
+		if n := report.Count(SeverityError); n != 8 {
+			t.Errorf("Expected 8 errors, got %d", n)
+		}
+		if codes := report.Codes(SeverityError); len(codes) != 6 || codes[0] != CodeDuplicateID {
+			t.Errorf("Expected 6 sorted error codes, got %v", codes)
+		}
+	})
+
+	t.Run("MissingRequiredFiles", func(t *testing.T) {
+		files := gtfstest.WithFiles(validFeed, map[string]string{"stop_times.txt": "", "calendar.txt": ""})
+
+		report, err := ValidateFile(gtfstest.WriteBundle(t, t.TempDir(), "gtfs.zip", files), "abc", now)
+		if err != nil {
+			t.Fatalf("ValidateFile failed: %v", err)
+		}
+
+		finding, ok := findFinding(report, CodeMissingRequiredFile)
+		if !ok || finding.Count != 1 || finding.File != "stop_times.txt" {
+			t.Errorf("Expected stop_times.txt to be reported missing, got %+v", report.Findings)
+		}
+		// Without a calendar, trip T1 also references an unknown service.
+		if n := report.Count(SeverityError); n != 3 {
+			t.Errorf("Expected 3 errors, got %d: %+v", n, report.Findings)
+		}
+	})
+
+	t.Run("NotAZip", func(t *testing.T) {
+		if _, err := ValidateFile("invalid/path/gtfs.zip", "abc", now); err == nil {
+			t.Error("Expected an error for a missing bundle")
+		}
+	})
+}
@@ -0,0 +1,7 @@
+// Package ui holds the templates of the pages served by the watchdog.
+package ui
+
+import "embed"
+
+//go:embed "html"
+var Files embed.FS